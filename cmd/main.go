@@ -5,8 +5,13 @@ import (
 	"Qwen/internal/bot"
 	"Qwen/internal/config"
 	"Qwen/internal/database"
+	"Qwen/internal/knowledge"
 	"Qwen/internal/memory"
+	"Qwen/internal/prompt"
+	"Qwen/internal/quota"
 	"Qwen/internal/server"
+	"Qwen/internal/vector"
+	"context"
 	"log"
 	"os"
 	"os/signal"
@@ -18,11 +23,110 @@ func main() {
 	cfg := config.Load()
 
 	// Initialize AI client
-	aiClient := ai.NewClient(cfg.DashScopeAPIKey, cfg.DashScopeBaseURL, cfg.AIModel)
+	apiKey, baseURL := cfg.AICredentials()
+	aiClient, err := ai.NewLLM(cfg.AIProvider, apiKey, baseURL, cfg.AIModel)
+	if err != nil {
+		log.Fatal("Failed to create AI client:", err)
+	}
+	log.Printf("🤖 Using %s provider with model %s", cfg.AIProvider, cfg.AIModel)
+	if client, ok := aiClient.(*ai.Client); ok {
+		client.SetRetryPolicy(ai.RetryPolicy{
+			MaxRetries: cfg.AIMaxRetries,
+			BaseDelay:  cfg.AIRetryBaseDelay,
+			MaxDelay:   cfg.AIRetryMaxDelay,
+		})
+		client.SetAudioDefaults(ai.AudioOptions{
+			Voice:      cfg.AIVoice,
+			Format:     cfg.AIAudioFormat,
+			SampleRate: cfg.AIAudioSampleRate,
+		})
+		if cfg.AITranslationModel != "" {
+			client.SetTranslationModel(cfg.AITranslationModel)
+		}
+		if cfg.AIEmbeddingModel != "" {
+			client.SetEmbeddingModel(cfg.AIEmbeddingModel)
+		}
+		client.SetEmbeddingDimensions(cfg.AIEmbeddingDimensions)
+		if cfg.AISpeechModel != "" {
+			client.SetSpeechModel(cfg.AISpeechModel)
+		}
+		routes, err := ai.ParseRoutes(cfg.AIRoutes)
+		if err != nil {
+			log.Fatal("Invalid AI_ROUTES:", err)
+		}
+		for task, model := range map[ai.Task]string{
+			ai.TaskMemory:    cfg.AIMemoryModel,
+			ai.TaskReasoning: cfg.AIReasoningModel,
+			ai.TaskMedia:     cfg.AIMediaModel,
+			ai.TaskSummary:   cfg.AISummaryModel,
+		} {
+			if model != "" {
+				routes[task] = model
+			}
+		}
+		router := ai.NewRouter(routes)
+		for task, model := range router.Routes() {
+			log.Printf("🧭 Routing %s to %s", task, model)
+		}
+		client.SetRouter(router)
+
+		fallbacks, err := ai.ParseEndpoints(cfg.AIFallbacks, cfg.AIFallbackAPIKey)
+		if err != nil {
+			log.Fatal("Invalid AI_FALLBACKS:", err)
+		}
+		if len(fallbacks) > 0 {
+			client.SetFailover(ai.NewFailover(ai.BreakerPolicy{
+				Threshold: cfg.AIBreakerThreshold,
+				Cooldown:  cfg.AIBreakerCooldown,
+			}, fallbacks...))
+			log.Printf("↪️ Failing over to %d fallback endpoint(s)", len(fallbacks))
+		}
+		videoOpts := ai.DefaultVideoOptions
+		videoOpts.Mode = cfg.VideoMode
+		videoOpts.FPS = cfg.VideoFPS
+		videoOpts.MaxFrames = cfg.VideoMaxFrames
+		videoOpts.MaxDuration = cfg.VideoMaxDuration
+		client.SetVideoOptions(videoOpts)
+		client.SetFrameExtractor(ai.FFmpegExtractor{Path: cfg.FFmpegPath})
+	}
+
+	// Quotas are enforced before any AI call; tokens are fed back through the usage recorder
+	limiter := quota.New(quota.Config{
+		PerUser: quota.Limit{
+			Messages: cfg.QuotaUserMessages,
+			Tokens:   cfg.QuotaUserTokens,
+			Window:   cfg.QuotaUserWindow,
+		},
+		Global: quota.Limit{
+			Messages: cfg.QuotaGlobalMessages,
+			Tokens:   cfg.QuotaGlobalTokens,
+			Window:   cfg.QuotaGlobalWindow,
+		},
+		Allowlist: cfg.QuotaAllowlist,
+	})
+	if limiter.Enabled() {
+		log.Printf("🚦 Quotas enabled (%d allowlisted users)", len(cfg.QuotaAllowlist))
+	}
+	var usageRecorder ai.UsageRecorder = limiter
+
+	// System prompts are rendered from templates; a PROMPT_DIR overrides the built-ins and is reloaded on change
+	prompts, err := prompt.New(cfg.PromptDir, cfg.PromptDefaultPersona)
+	if err != nil {
+		log.Fatal("Invalid prompt templates:", err)
+	}
+	if cfg.PromptDir != "" {
+		log.Printf("📝 Prompt templates loaded from %s (%d personas)", cfg.PromptDir, len(prompts.Personas()))
+		go prompts.Watch(context.Background(), cfg.PromptReloadInterval)
+	}
 
 	// Initialize database connection (optional)
 	var convService *database.ConversationService
 	var memoryService *memory.MemoryService
+	var glossaryService *database.GlossaryService
+	var cacheStore ai.CacheStore
+	var vectorStore vector.Store
+	var documentService *database.DocumentService
+	var usageService *database.UsageService
 	if cfg.DatabaseDSN != "" {
 		db, err := database.NewConnection(cfg.DatabaseDSN)
 		if err != nil {
@@ -30,7 +134,16 @@ func main() {
 			log.Println("Bot will continue without conversation history and memory")
 		} else {
 			convService = database.NewConversationService(db)
-			memoryService = memory.NewMemoryService(db.GetConnection(), aiClient)
+			memoryService = memory.NewMemoryService(db.GetConnection(), aiClient, prompts)
+			glossaryService = database.NewGlossaryService(db)
+			vectorStore = database.NewVectorService(db)
+			documentService = database.NewDocumentService(db)
+			if cfg.AICache == "database" {
+				cacheStore = database.NewCacheService(db, cfg.AICacheSize)
+			}
+			usageService = database.NewUsageService(db)
+			usageRecorder = ai.MultiUsageRecorder(usageService, limiter)
+			log.Println("📊 Token usage is recorded per user")
 			log.Println("✅ Database connection established")
 			log.Println("🧠 Memory service initialized with LLM integration")
 		}
@@ -38,14 +151,92 @@ func main() {
 		log.Println("🔄 No database configured - running without conversation history and memory")
 	}
 
+	if client, ok := aiClient.(*ai.Client); ok {
+		client.SetUsageRecorder(usageRecorder)
+
+		switch cfg.AICache {
+		case "memory", "database":
+			where := "database"
+			if cacheStore == nil {
+				cacheStore, where = ai.NewMemoryCacheStore(cfg.AICacheSize), "memory"
+			}
+			client.SetCache(ai.NewResponseCache(cacheStore, cfg.AICacheTTL))
+			log.Printf("💾 Response cache enabled in %s (TTL %s, %d entries)", where, cfg.AICacheTTL, cfg.AICacheSize)
+		case "", "off":
+		default:
+			log.Printf("Warning: unknown AI_CACHE %q, response cache disabled", cfg.AICache)
+		}
+	}
+
+	// Chat history is trimmed to the token budget; rolling summaries live in the session when a database is configured
+	var summaries ai.SummaryStore = ai.NewSummaryCache()
+	if convService != nil {
+		summaries = convService
+	}
+	contextOpts := ai.DefaultContextOptions
+	contextOpts.Budget = cfg.AIContextBudget
+	contextBuilder := ai.NewContextBuilder(aiClient, cfg.AIModel, summaries, contextOpts)
+	log.Printf("📏 Chat context limited to %d tokens", contextBuilder.Budget())
+
+	// Relevant past turns are recalled from the whole stored history, so retrieval needs the database
+	var retriever *ai.Retriever
+	switch {
+	case cfg.AIRetrievalTopK <= 0:
+	case convService == nil:
+		log.Println("🔎 Retrieval of past conversations disabled - no database configured")
+	default:
+		// A flat index: searches are filtered to one user, which defeats LSH buckets
+		collection, err := vector.Open(vectorStore, database.ConversationVectors, vector.NewFlat())
+		if err != nil {
+			log.Printf("Warning: Failed to load conversation vectors: %v", err)
+			break
+		}
+		retriever = ai.NewRetriever(aiClient, collection, ai.RetrievalOptions{
+			TopK:     cfg.AIRetrievalTopK,
+			MinScore: float32(cfg.AIRetrievalMinScore),
+		})
+		log.Printf("🔎 Recalling up to %d past turn(s) per message (%d indexed)", cfg.AIRetrievalTopK, collection.Len())
+		go func() {
+			added, err := retriever.Backfill(context.Background(), convService)
+			if err != nil {
+				log.Printf("Warning: Failed to index past conversations: %v", err)
+			}
+			if added > 0 {
+				log.Printf("🔎 Indexed %d past turn(s)", added)
+			}
+		}()
+	}
+
+	// Uploaded documents are chunked into their own vector collection; their list lives in the documents table
+	var knowledgeBase *knowledge.Base
+	switch {
+	case cfg.KnowledgeTopK <= 0:
+	case documentService == nil:
+		log.Println("📚 Knowledge base disabled - no database configured")
+	default:
+		collection, err := vector.Open(vectorStore, database.DocumentVectors, vector.NewFlat())
+		if err != nil {
+			log.Printf("Warning: Failed to load document vectors: %v", err)
+			break
+		}
+		knowledgeOpts := knowledge.DefaultOptions
+		knowledgeOpts.TopK = cfg.KnowledgeTopK
+		knowledgeOpts.MaxDocuments = cfg.KnowledgeMaxDocuments
+		knowledgeBase = knowledge.New(aiClient, collection, documentService, knowledgeOpts)
+		log.Printf("📚 Knowledge base enabled (%d chunks indexed)", collection.Len())
+	}
+
 	// Initialize bot handler
-	botHandler, err := bot.NewHandler(cfg.TelegramBotToken, aiClient, convService, memoryService)
+	botHandler, err := bot.NewHandler(cfg.TelegramBotToken, aiClient, convService, memoryService, glossaryService, limiter, contextBuilder, retriever, knowledgeBase, prompts)
 	if err != nil {
 		log.Fatal("Failed to create bot handler:", err)
 	}
 
 	// Initialize HTTP server for WebSocket
-	httpServer := server.NewServer(aiClient, cfg.HTTPPort)
+	httpServer := server.NewServer(aiClient, convService, glossaryService, limiter, contextBuilder, retriever, knowledgeBase, prompts, cfg.WebAuthSecret, cfg.HTTPPort)
+	if cfg.WebAuthSecret == "" {
+		log.Println("🔓 WEB_AUTH_SECRET not set - web chats are not stored and cannot use per-user data")
+	}
 
 	// Start bot in a goroutine
 	go func() {
@@ -72,5 +263,9 @@ func main() {
 
 	log.Println("Shutting down bot...")
 	botHandler.Stop()
+	if usageService != nil {
+		// Write the usage still queued
+		usageService.Close()
+	}
 	log.Println("Bot stopped successfully.")
 }
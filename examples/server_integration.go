@@ -7,9 +7,11 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"Qwen/internal/ai"
 	"Qwen/internal/config"
+	"Qwen/internal/prompt"
 )
 
 // ChatRequest represents a chat request from the client
@@ -69,16 +71,16 @@ func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
 	switch strings.ToLower(req.Mode) {
 	case "thinking":
 		// Use thinking mode
-		s.handleThinkingChat(w, req.Message)
+		s.handleThinkingChat(r.Context(), w, req.Message)
 	case "regular":
 		// Use regular chat
-		s.handleRegularChat(w, req.Message)
+		s.handleRegularChat(r.Context(), w, req.Message)
 	case "auto", "":
 		// Auto-detect based on model capabilities
 		if s.aiClient.IsQwenModel() {
-			s.handleThinkingChat(w, req.Message)
+			s.handleThinkingChat(r.Context(), w, req.Message)
 		} else {
-			s.handleRegularChat(w, req.Message)
+			s.handleRegularChat(r.Context(), w, req.Message)
 		}
 	default:
 		http.Error(w, "Invalid mode", http.StatusBadRequest)
@@ -86,44 +88,43 @@ func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
 }
 
 // handleThinkingChat handles chat with thinking mode
-func (s *Server) handleThinkingChat(w http.ResponseWriter, message string) {
+func (s *Server) handleThinkingChat(ctx context.Context, w http.ResponseWriter, message string) {
 	// Ensure thinking mode is enabled
 	s.aiClient.SetThinkingMode(true)
 
-	s.aiClient.ChatStreamWithThinking(message, func(stage string, content string, isComplete bool) {
-		response := ChatResponse{
-			Stage:    stage,
-			Content:  content,
-			Complete: isComplete,
-		}
-
-		// Send response as JSON
-		jsonData, err := json.Marshal(response)
-		if err != nil {
-			log.Printf("Error marshaling response: %v", err)
-			return
-		}
-
-		// Write response with newline for streaming
-		fmt.Fprintf(w, "%s\n", string(jsonData))
-
-		// Flush to ensure streaming works
-		if flusher, ok := w.(http.Flusher); ok {
-			flusher.Flush()
-		}
-	})
+	writeStream(w, s.aiClient.ChatStreamWithThinking(ctx, ai.NewConversation(systemPrompt(), nil, message)))
 }
 
 // handleRegularChat handles regular chat without thinking mode
-func (s *Server) handleRegularChat(w http.ResponseWriter, message string) {
+func (s *Server) handleRegularChat(ctx context.Context, w http.ResponseWriter, message string) {
 	// Disable thinking mode
 	s.aiClient.SetThinkingMode(false)
 
-	s.aiClient.ChatStream(message, func(chunk string, isComplete bool) {
-		response := ChatResponse{
-			Stage:    "streaming",
-			Content:  chunk,
-			Complete: isComplete,
+	writeStream(w, s.aiClient.ChatStream(ctx, ai.NewConversation(systemPrompt(), nil, message)))
+}
+
+// systemPrompt renders the built-in default persona
+func systemPrompt() string {
+	return prompt.Default().System(prompt.DefaultPersona, prompt.Data{ChatType: "web", Now: time.Now()})
+}
+
+// writeStream writes every stream event as a JSON line in the stage format used by demo.html
+func writeStream(w http.ResponseWriter, events <-chan ai.StreamEvent) {
+	var answer string
+	for event := range events {
+		var response ChatResponse
+		switch event.Type {
+		case ai.EventReasoning:
+			response = ChatResponse{Stage: "thinking", Content: event.Delta}
+		case ai.EventAnswer:
+			answer += event.Delta
+			response = ChatResponse{Stage: "streaming", Content: event.Delta}
+		case ai.EventDone:
+			response = ChatResponse{Stage: "complete", Content: answer, Complete: true}
+		case ai.EventError:
+			response = ChatResponse{Stage: "error", Content: event.Err.Error(), Complete: true, Error: event.Err.Error()}
+		default:
+			continue
 		}
 
 		// Send response as JSON
@@ -140,7 +141,7 @@ func (s *Server) handleRegularChat(w http.ResponseWriter, message string) {
 		if flusher, ok := w.(http.Flusher); ok {
 			flusher.Flush()
 		}
-	})
+	}
 }
 
 // handleStatus returns server status and model information
@@ -180,7 +181,7 @@ func (s *Server) handleCompleteThinking(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Get complete thinking response
-	response, err := s.aiClient.ChatWithThinking(context.Background(), messages)
+	response, err := s.aiClient.ChatWithThinking(r.Context(), messages)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("AI error: %v", err), http.StatusInternalServerError)
 		return
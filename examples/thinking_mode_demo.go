@@ -17,6 +17,8 @@ func main() {
 	// Create AI client with thinking mode enabled by default
 	client := ai.NewClient(cfg.DashScopeAPIKey, cfg.DashScopeBaseURL, cfg.AIModel)
 
+	ctx := context.Background()
+
 	// Check if the model supports thinking mode
 	if client.IsQwenModel() {
 		fmt.Printf("✅ Using Qwen model '%s' with thinking mode enabled by default\n", cfg.AIModel)
@@ -32,20 +34,7 @@ func main() {
 	userMessage := "Explain how photosynthesis works in simple terms"
 	fmt.Printf("User: %s\n", userMessage)
 
-	client.ChatStreamWithThinking(userMessage, func(stage string, content string, isComplete bool) {
-		switch stage {
-		case "thinking":
-			fmt.Printf("🤔 Thinking: %s", content)
-		case "thinking_complete":
-			fmt.Println("\n" + strings.Repeat("=", 30) + " THINKING COMPLETE " + strings.Repeat("=", 30))
-		case "streaming":
-			fmt.Printf("%s", content)
-		case "complete":
-			fmt.Println("\n" + strings.Repeat("=", 30) + " RESPONSE COMPLETE " + strings.Repeat("=", 30))
-		case "error":
-			fmt.Printf("❌ Error: %s\n", content)
-		}
-	})
+	printStream(client.ChatStreamWithThinking(ctx, ai.NewConversation("", nil, userMessage)))
 
 	// Example 2: Disable thinking mode with /no_think
 	fmt.Println("\n\n" + strings.Repeat("=", 50))
@@ -55,20 +44,7 @@ func main() {
 	userMessage2 := "What is the capital of France?/no_think"
 	fmt.Printf("User: %s\n", userMessage2)
 
-	client.ChatStreamWithThinking(userMessage2, func(stage string, content string, isComplete bool) {
-		switch stage {
-		case "thinking":
-			fmt.Printf("🤔 Thinking: %s", content)
-		case "thinking_complete":
-			fmt.Println("\n" + strings.Repeat("=", 30) + " THINKING COMPLETE " + strings.Repeat("=", 30))
-		case "streaming":
-			fmt.Printf("%s", content)
-		case "complete":
-			fmt.Println("\n" + strings.Repeat("=", 30) + " RESPONSE COMPLETE " + strings.Repeat("=", 30))
-		case "error":
-			fmt.Printf("❌ Error: %s\n", content)
-		}
-	})
+	printStream(client.ChatStreamWithThinking(ctx, ai.NewConversation("", nil, userMessage2)))
 
 	// Example 3: Explicitly enable thinking mode with /think
 	fmt.Println("\n\n" + strings.Repeat("=", 50))
@@ -78,20 +54,7 @@ func main() {
 	userMessage3 := "Solve this math problem step by step: 2x + 5 = 13/think"
 	fmt.Printf("User: %s\n", userMessage3)
 
-	client.ChatStreamWithThinking(userMessage3, func(stage string, content string, isComplete bool) {
-		switch stage {
-		case "thinking":
-			fmt.Printf("🤔 Thinking: %s", content)
-		case "thinking_complete":
-			fmt.Println("\n" + strings.Repeat("=", 30) + " THINKING COMPLETE " + strings.Repeat("=", 30))
-		case "streaming":
-			fmt.Printf("%s", content)
-		case "complete":
-			fmt.Println("\n" + strings.Repeat("=", 30) + " RESPONSE COMPLETE " + strings.Repeat("=", 30))
-		case "error":
-			fmt.Printf("❌ Error: %s\n", content)
-		}
-	})
+	printStream(client.ChatStreamWithThinking(ctx, ai.NewConversation("", nil, userMessage3)))
 
 	// Example 4: Complete thinking response with both reasoning and answer
 	fmt.Println("\n\n" + strings.Repeat("=", 50))
@@ -105,7 +68,7 @@ func main() {
 		{Role: "user", Content: userMessage4},
 	}
 
-	thinkingResponse, err := client.ChatWithThinking(context.Background(), messages)
+	thinkingResponse, err := client.ChatWithThinking(ctx, messages)
 	if err != nil {
 		log.Printf("Error: %v", err)
 		return
@@ -135,20 +98,7 @@ func main() {
 	userMessage5 := "What is the weather like today?"
 	fmt.Printf("User: %s\n", userMessage5)
 
-	client.ChatStreamWithThinking(userMessage5, func(stage string, content string, isComplete bool) {
-		switch stage {
-		case "thinking":
-			fmt.Printf("🤔 Thinking: %s", content)
-		case "thinking_complete":
-			fmt.Println("\n" + strings.Repeat("=", 30) + " THINKING COMPLETE " + strings.Repeat("=", 30))
-		case "streaming":
-			fmt.Printf("%s", content)
-		case "complete":
-			fmt.Println("\n" + strings.Repeat("=", 30) + " RESPONSE COMPLETE " + strings.Repeat("=", 30))
-		case "error":
-			fmt.Printf("❌ Error: %s\n", content)
-		}
-	})
+	printStream(client.ChatStreamWithThinking(ctx, ai.NewConversation("", nil, userMessage5)))
 
 	// Re-enable thinking mode
 	client.SetThinkingMode(true)
@@ -162,27 +112,36 @@ func main() {
 	userMessage6 := "What time is it right now?/think"
 	fmt.Printf("User: %s\n", userMessage6)
 
-	client.ChatStreamWithThinking(userMessage6, func(stage string, content string, isComplete bool) {
-		switch stage {
-		case "thinking":
-			fmt.Printf("🤔 Thinking: %s", content)
-		case "thinking_complete":
-			fmt.Println("\n" + strings.Repeat("=", 30) + " THINKING COMPLETE " + strings.Repeat("=", 30))
-		case "streaming":
-			fmt.Printf("%s", content)
-		case "tool_call":
-			fmt.Printf("🔧 Tool Call: %s\n", content)
-		case "usage":
-			fmt.Printf("📊 Usage: %s\n", content)
-		case "complete":
-			fmt.Println("\n" + strings.Repeat("=", 30) + " RESPONSE COMPLETE " + strings.Repeat("=", 30))
-		case "error":
-			fmt.Printf("❌ Error: %s\n", content)
-		}
-	})
+	printStream(client.ChatStreamWithThinking(ctx, ai.NewConversation("", nil, userMessage6)))
 
 	fmt.Println("\n🎉 Demo completed! Thinking mode is now enabled by default for Qwen models.")
 	fmt.Println("Use /think to explicitly enable thinking mode")
 	fmt.Println("Use /no_think to disable thinking mode for a specific query")
 	fmt.Println("Use SetThinkingMode() to control thinking mode globally")
 }
+
+// printStream prints every event of a thinking stream as it arrives
+func printStream(events <-chan ai.StreamEvent) {
+	answering := false
+	for event := range events {
+		switch event.Type {
+		case ai.EventReasoning:
+			fmt.Printf("🤔 Thinking: %s", event.Delta)
+		case ai.EventAnswer:
+			if !answering {
+				fmt.Println("\n" + strings.Repeat("=", 30) + " THINKING COMPLETE " + strings.Repeat("=", 30))
+				answering = true
+			}
+			fmt.Printf("%s", event.Delta)
+		case ai.EventToolCall:
+			fmt.Printf("🔧 Tool Call: %s %s\n", event.ToolCall.Name, event.ToolCall.Arguments)
+		case ai.EventUsage:
+			fmt.Printf("📊 Usage: %d prompt, %d completion, %d total\n",
+				event.Usage.PromptTokens, event.Usage.CompletionTokens, event.Usage.TotalTokens)
+		case ai.EventDone:
+			fmt.Println("\n" + strings.Repeat("=", 30) + " RESPONSE COMPLETE " + strings.Repeat("=", 30))
+		case ai.EventError:
+			fmt.Printf("❌ Error: %v\n", event.Err)
+		}
+	}
+}
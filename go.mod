@@ -9,6 +9,7 @@ require (
 	github.com/gorilla/websocket v1.5.1
 	github.com/joho/godotenv v1.5.1
 	github.com/sashabaranov/go-openai v1.17.9
+	golang.org/x/image v0.18.0
 )
 
 require filippo.io/edwards25519 v1.1.0 // indirect
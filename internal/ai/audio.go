@@ -0,0 +1,263 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Supported output audio formats
+const (
+	AudioFormatMP3   = "mp3"
+	AudioFormatWAV   = "wav"
+	AudioFormatPCM16 = "pcm16"
+)
+
+// omniSampleRate is the rate of the 16-bit mono PCM that DashScope omni models stream
+const omniSampleRate = 24000
+
+// AudioOptions selects the voice and encoding of spoken output
+type AudioOptions struct {
+	Voice  string `json:"voice,omitempty"`
+	Format string `json:"format,omitempty"`
+	// SampleRate applies to wav and pcm16 output (0 = model default)
+	SampleRate int `json:"sample_rate,omitempty"`
+}
+
+// DefaultAudioOptions uses a DashScope omni voice with playable wav output
+var DefaultAudioOptions = AudioOptions{
+	Voice:      "Cherry",
+	Format:     AudioFormatWAV,
+	SampleRate: omniSampleRate,
+}
+
+// Validate reports an unsupported format or a negative sample rate
+func (o AudioOptions) Validate() error {
+	switch o.Format {
+	case AudioFormatMP3, AudioFormatWAV, AudioFormatPCM16:
+	default:
+		return fmt.Errorf("%w: unsupported audio format %q (use mp3, wav or pcm16)", ErrBadRequest, o.Format)
+	}
+	if o.SampleRate < 0 {
+		return fmt.Errorf("%w: invalid sample rate %d", ErrBadRequest, o.SampleRate)
+	}
+	return nil
+}
+
+// validateOmni is Validate for DashScope omni models, which only stream wav/PCM audio
+func (o AudioOptions) validateOmni() error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	if o.Format == AudioFormatMP3 {
+		return fmt.Errorf("%w: omni models do not output mp3 audio (use wav or pcm16)", ErrNotSupported)
+	}
+	return nil
+}
+
+// ValidateAudio reports audio options the client cannot produce: omni models only output
+// wav/PCM, while OpenAI-compatible servers also return mp3
+func (c *Client) ValidateAudio(opts AudioOptions) error {
+	if c.compatible {
+		return opts.Validate()
+	}
+	return opts.validateOmni()
+}
+
+// merge returns o with the non-zero fields of override applied
+func (o AudioOptions) merge(override AudioOptions) AudioOptions {
+	if override.Voice != "" {
+		o.Voice = override.Voice
+	}
+	if override.Format != "" {
+		o.Format = strings.ToLower(override.Format)
+	}
+	if override.SampleRate != 0 {
+		o.SampleRate = override.SampleRate
+	}
+	return o
+}
+
+// resolveAudio applies the audio overrides carried by ctx on top of base
+func resolveAudio(ctx context.Context, base AudioOptions) AudioOptions {
+	if opts, ok := requestOptionsFrom(ctx); ok && opts.Audio != nil {
+		return base.merge(*opts.Audio)
+	}
+	return base
+}
+
+// Speech is synthesized audio
+type Speech struct {
+	Audio      []byte
+	Format     string
+	SampleRate int
+}
+
+// speechPrompt makes an omni model read the user text verbatim
+const speechPrompt = "You are a text-to-speech engine. Read the user's text aloud exactly as written, in its original language. Do not add, answer or comment on anything."
+
+// TextToSpeech reads text aloud. An empty voice uses the per-request or client default.
+// DashScope clients use the speech (omni) model; OpenAI-compatible servers use /audio/speech.
+func (c *Client) TextToSpeech(ctx context.Context, text, voice string) (*Speech, error) {
+	opts := resolveAudio(ctx, c.audio).merge(AudioOptions{Voice: voice})
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("%w: empty text", ErrBadRequest)
+	}
+
+	ctx, model := c.route(ctx, TaskSpeech, c.speechModel)
+	if c.compatible {
+		var audio []byte
+		err := c.do(ctx, "speech request", model, true, func(ep Endpoint) error {
+			var err error
+			audio, err = c.postSpeech(ctx, ep, text, opts)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &Speech{Audio: audio, Format: opts.Format, SampleRate: opts.SampleRate}, nil
+	}
+
+	if err := c.require(model, CapabilityAudioOutput); err != nil {
+		return nil, err
+	}
+	if err := opts.validateOmni(); err != nil {
+		return nil, err
+	}
+
+	ctx = WithRequestOptions(ctx, withAudio(ctx, opts))
+	body := c.omniRequestBody(ctx, model, speechPrompt, text, nil, nil, nil, true)
+	out, err := CollectOmniStream(c.streamOmniBody(ctx, body))
+	if err != nil {
+		return nil, err
+	}
+	return &Speech{Audio: encodeOmniAudio(out.Audio, opts), Format: opts.Format, SampleRate: outputRate(opts)}, nil
+}
+
+// withAudio returns the request options of ctx with Audio replaced by opts
+func withAudio(ctx context.Context, opts AudioOptions) RequestOptions {
+	reqOpts, _ := requestOptionsFrom(ctx)
+	reqOpts.Audio = &opts
+	return reqOpts
+}
+
+// postSpeech calls the OpenAI-style /audio/speech endpoint
+func (c *Client) postSpeech(ctx context.Context, ep Endpoint, text string, opts AudioOptions) ([]byte, error) {
+	format := opts.Format
+	if format == AudioFormatPCM16 {
+		format = "pcm"
+	}
+	payload, err := json.Marshal(map[string]any{
+		"model":           ep.Model,
+		"input":           text,
+		"voice":           opts.Voice,
+		"response_format": format,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.BaseURL+"/audio/speech", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+ep.APIKey)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request error: %w", classifyError(err))
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", classifyError(err))
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, parseAPIError(resp.StatusCode, resp.Header, body)
+	}
+	return body, nil
+}
+
+// omniWireFormat is the audio format requested from DashScope omni models. They stream raw
+// 24kHz PCM, which encodeOmniAudio converts to the wav or pcm16 output validateOmni allows.
+const omniWireFormat = AudioFormatWAV
+
+// outputRate returns the sample rate of encoded wav/pcm16 output
+func outputRate(opts AudioOptions) int {
+	if opts.SampleRate > 0 {
+		return opts.SampleRate
+	}
+	return omniSampleRate
+}
+
+// encodeOmniAudio converts the PCM collected from an omni stream into the requested format
+func encodeOmniAudio(audio []byte, opts AudioOptions) []byte {
+	if len(audio) == 0 || bytes.HasPrefix(audio, []byte("RIFF")) {
+		return audio
+	}
+
+	rate := outputRate(opts)
+	pcm := resamplePCM16(audio, omniSampleRate, rate)
+	if opts.Format == AudioFormatPCM16 {
+		return pcm
+	}
+	return wavFromPCM16(pcm, rate)
+}
+
+// resamplePCM16 converts 16-bit little-endian mono PCM between sample rates by linear interpolation
+func resamplePCM16(pcm []byte, from, to int) []byte {
+	if from == to || from <= 0 || to <= 0 || len(pcm) < 4 {
+		return pcm
+	}
+
+	in := make([]int16, len(pcm)/2)
+	for i := range in {
+		in[i] = int16(binary.LittleEndian.Uint16(pcm[2*i:]))
+	}
+
+	n := int(int64(len(in)) * int64(to) / int64(from))
+	out := make([]byte, 2*n)
+	for i := 0; i < n; i++ {
+		pos := float64(i) * float64(from) / float64(to)
+		j := int(pos)
+		frac := pos - float64(j)
+		sample := float64(in[j])
+		if j+1 < len(in) {
+			sample += (float64(in[j+1]) - sample) * frac
+		}
+		binary.LittleEndian.PutUint16(out[2*i:], uint16(int16(sample)))
+	}
+	return out
+}
+
+// wavFromPCM16 wraps 16-bit mono PCM in a WAV container
+func wavFromPCM16(pcm []byte, sampleRate int) []byte {
+	var b bytes.Buffer
+	b.Grow(44 + len(pcm))
+
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(36+len(pcm)))
+	b.WriteString("WAVEfmt ")
+	binary.Write(&b, binary.LittleEndian, uint32(16))           // fmt chunk size
+	binary.Write(&b, binary.LittleEndian, uint16(1))            // PCM
+	binary.Write(&b, binary.LittleEndian, uint16(1))            // mono
+	binary.Write(&b, binary.LittleEndian, uint32(sampleRate))   // sample rate
+	binary.Write(&b, binary.LittleEndian, uint32(sampleRate*2)) // byte rate
+	binary.Write(&b, binary.LittleEndian, uint16(2))            // block align
+	binary.Write(&b, binary.LittleEndian, uint16(16))           // bits per sample
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, uint32(len(pcm)))
+	b.Write(pcm)
+
+	return b.Bytes()
+}
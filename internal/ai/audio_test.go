@@ -0,0 +1,170 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAudioOptionsValidate(t *testing.T) {
+	tests := []struct {
+		opts    AudioOptions
+		wantErr bool
+	}{
+		{AudioOptions{Format: AudioFormatMP3}, false},
+		{AudioOptions{Format: AudioFormatWAV, SampleRate: 16000}, false},
+		{AudioOptions{Format: AudioFormatPCM16}, false},
+		{AudioOptions{Format: "ogg"}, true},
+		{AudioOptions{Format: AudioFormatWAV, SampleRate: -1}, true},
+	}
+
+	for _, tt := range tests {
+		err := tt.opts.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) error = %v, wantErr %v", tt.opts, err, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrBadRequest) {
+			t.Errorf("Validate(%+v) should wrap ErrBadRequest, got %v", tt.opts, err)
+		}
+	}
+}
+
+func TestResolveAudioOverridesDefaults(t *testing.T) {
+	ctx := WithRequestOptions(context.Background(), RequestOptions{Audio: &AudioOptions{Voice: "Ethan", Format: "MP3"}})
+
+	got := resolveAudio(ctx, DefaultAudioOptions)
+	want := AudioOptions{Voice: "Ethan", Format: AudioFormatMP3, SampleRate: DefaultAudioOptions.SampleRate}
+	if got != want {
+		t.Errorf("resolveAudio() = %+v, want %+v", got, want)
+	}
+	if got := resolveAudio(context.Background(), DefaultAudioOptions); got != DefaultAudioOptions {
+		t.Errorf("resolveAudio() without overrides = %+v, want defaults", got)
+	}
+}
+
+func TestWavFromPCM16(t *testing.T) {
+	pcm := []byte{1, 0, 2, 0, 3, 0}
+	wav := wavFromPCM16(pcm, 16000)
+
+	if len(wav) != 44+len(pcm) {
+		t.Fatalf("Expected %d bytes, got %d", 44+len(pcm), len(wav))
+	}
+	if string(wav[:4]) != "RIFF" || string(wav[8:16]) != "WAVEfmt " || string(wav[36:40]) != "data" {
+		t.Errorf("Invalid WAV header %q", wav[:44])
+	}
+	if rate := binary.LittleEndian.Uint32(wav[24:]); rate != 16000 {
+		t.Errorf("Expected sample rate 16000, got %d", rate)
+	}
+	if !bytes.Equal(wav[44:], pcm) {
+		t.Error("PCM payload was altered")
+	}
+}
+
+func TestResamplePCM16(t *testing.T) {
+	pcm := make([]byte, 2*2400) // 100ms at 24kHz
+	for i := 0; i < 2400; i++ {
+		binary.LittleEndian.PutUint16(pcm[2*i:], uint16(i))
+	}
+
+	out := resamplePCM16(pcm, 24000, 16000)
+	if len(out) != 2*1600 {
+		t.Fatalf("Expected 1600 samples, got %d", len(out)/2)
+	}
+	// sample 3 at 16kHz sits at sample 4.5 at 24kHz
+	if got := int16(binary.LittleEndian.Uint16(out[6:])); got != 4 {
+		t.Errorf("Expected interpolated sample 4, got %d", got)
+	}
+	if got := resamplePCM16(pcm, 24000, 24000); !bytes.Equal(got, pcm) {
+		t.Error("Same-rate resampling should return the input")
+	}
+}
+
+func TestTextToSpeechOmni(t *testing.T) {
+	bodies := make(chan map[string]any, 1)
+	server := omniServer(t, bodies)
+	client := NewClient("test-key", server.URL, "qwen-plus")
+
+	speech, err := client.TextToSpeech(context.Background(), "Halo semua", "Ethan")
+	if err != nil {
+		t.Fatalf("TextToSpeech() error = %v", err)
+	}
+	if speech.Format != AudioFormatWAV || speech.SampleRate != 24000 {
+		t.Errorf("Expected wav at 24000Hz, got %s at %d", speech.Format, speech.SampleRate)
+	}
+	if !bytes.HasPrefix(speech.Audio, []byte("RIFF")) || !bytes.HasSuffix(speech.Audio, []byte("abcdef")) {
+		t.Errorf("Expected WAV wrapping the streamed PCM, got %q", speech.Audio)
+	}
+
+	body := <-bodies
+	if body["model"] != DefaultSpeechModel {
+		t.Errorf("Expected speech model %s, got %v", DefaultSpeechModel, body["model"])
+	}
+	audio, _ := body["audio"].(map[string]any)
+	if audio["voice"] != "Ethan" || audio["format"] != "wav" {
+		t.Errorf("Unexpected audio request %v", body["audio"])
+	}
+}
+
+func TestTextToSpeechCompatible(t *testing.T) {
+	var got map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/audio/speech" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		raw, _ := io.ReadAll(r.Body)
+		json.Unmarshal(raw, &got)
+		w.Write([]byte("pcm-bytes"))
+	}))
+	defer server.Close()
+
+	client := NewOpenAICompatibleClient("", server.URL, "llama3")
+	ctx := WithRequestOptions(context.Background(), RequestOptions{Audio: &AudioOptions{Format: AudioFormatPCM16}})
+
+	speech, err := client.TextToSpeech(ctx, "hello", "")
+	if err != nil {
+		t.Fatalf("TextToSpeech() error = %v", err)
+	}
+	if string(speech.Audio) != "pcm-bytes" || speech.Format != AudioFormatPCM16 {
+		t.Errorf("Unexpected speech %+v", speech)
+	}
+	if got["voice"] != "alloy" || got["response_format"] != "pcm" || got["input"] != "hello" {
+		t.Errorf("Unexpected request %v", got)
+	}
+}
+
+func TestTextToSpeechRejectsBadFormat(t *testing.T) {
+	client := NewClient("test-key", "http://127.0.0.1:0", "qwen-plus")
+	ctx := WithRequestOptions(context.Background(), RequestOptions{Audio: &AudioOptions{Format: "flac"}})
+
+	if _, err := client.TextToSpeech(ctx, "hi", ""); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Expected ErrBadRequest, got %v", err)
+	}
+}
+
+func TestOmniRejectsMP3(t *testing.T) {
+	client := NewClient("test-key", "http://127.0.0.1:0", "qwen-omni-turbo")
+	ctx := WithRequestOptions(context.Background(), RequestOptions{Audio: &AudioOptions{Format: AudioFormatMP3}})
+
+	if _, err := client.TextToSpeech(ctx, "hi", ""); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("TextToSpeech() expected ErrNotSupported, got %v", err)
+	}
+	if _, err := client.ChatOmni(ctx, "", "hi", nil, nil, nil, true); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("ChatOmni() expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestValidateAudioPerProvider(t *testing.T) {
+	mp3 := AudioOptions{Format: AudioFormatMP3}
+	if err := NewClient("test-key", "http://127.0.0.1:0", "qwen-plus").ValidateAudio(mp3); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("DashScope ValidateAudio(mp3) expected ErrNotSupported, got %v", err)
+	}
+	if err := NewOpenAICompatibleClient("", "http://127.0.0.1:0", "llama3").ValidateAudio(mp3); err != nil {
+		t.Errorf("OpenAI-compatible ValidateAudio(mp3) error = %v", err)
+	}
+}
@@ -0,0 +1,332 @@
+package ai
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// CachedResponse is a complete answer stored by a ResponseCache
+type CachedResponse struct {
+	Model     string    `json:"model"`
+	Answer    string    `json:"answer"`
+	Reasoning string    `json:"reasoning,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CacheStore keeps cached responses by key. Stores bound their own size by evicting
+// the least recently used entries; expiry is checked by the ResponseCache.
+type CacheStore interface {
+	GetResponse(key string) (CachedResponse, bool, error)
+	SaveResponse(key string, response CachedResponse) error
+	DeleteResponse(key string) error
+}
+
+// DefaultCacheEntries bounds the in-memory store
+const DefaultCacheEntries = 1000
+
+// DefaultCacheTTL is how long a cached answer is served
+const DefaultCacheTTL = time.Hour
+
+// MemoryCacheStore is an in-memory LRU CacheStore
+type MemoryCacheStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	// lru holds *memoryCacheEntry, most recently used first
+	lru *list.List
+}
+
+type memoryCacheEntry struct {
+	key      string
+	response CachedResponse
+}
+
+// NewMemoryCacheStore creates a store holding at most maxEntries responses (0 = DefaultCacheEntries)
+func NewMemoryCacheStore(maxEntries int) *MemoryCacheStore {
+	if maxEntries <= 0 {
+		maxEntries = DefaultCacheEntries
+	}
+	return &MemoryCacheStore{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// GetResponse returns the response stored under key and marks it as recently used
+func (s *MemoryCacheStore) GetResponse(key string) (CachedResponse, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[key]
+	if !ok {
+		return CachedResponse{}, false, nil
+	}
+	s.lru.MoveToFront(elem)
+	return elem.Value.(*memoryCacheEntry).response, true, nil
+}
+
+// SaveResponse stores response under key, evicting the least recently used entry when full
+func (s *MemoryCacheStore) SaveResponse(key string, response CachedResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		elem.Value.(*memoryCacheEntry).response = response
+		s.lru.MoveToFront(elem)
+		return nil
+	}
+
+	s.entries[key] = s.lru.PushFront(&memoryCacheEntry{key: key, response: response})
+	for s.lru.Len() > s.maxEntries {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryCacheEntry).key)
+	}
+	return nil
+}
+
+// DeleteResponse removes the response stored under key
+func (s *MemoryCacheStore) DeleteResponse(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		s.lru.Remove(elem)
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+// Len returns the number of stored responses
+func (s *MemoryCacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+// CacheStats counts cache lookups
+type CacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// ResponseCache answers repeated chat requests without calling the model. Requests are keyed
+// by model, sampling params, thinking mode, system prompt and messages, compared after
+// collapsing whitespace and case. Requests with tools are never cached.
+// A nil *ResponseCache caches nothing.
+type ResponseCache struct {
+	store  CacheStore
+	ttl    time.Duration
+	hits   atomic.Int64
+	misses atomic.Int64
+	now    func() time.Time
+}
+
+// NewResponseCache creates a cache serving answers for ttl (0 = DefaultCacheTTL);
+// a nil store keeps them in memory
+func NewResponseCache(store CacheStore, ttl time.Duration) *ResponseCache {
+	if store == nil {
+		store = NewMemoryCacheStore(0)
+	}
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &ResponseCache{store: store, ttl: ttl, now: time.Now}
+}
+
+// Stats returns the hits and misses so far
+func (rc *ResponseCache) Stats() CacheStats {
+	if rc == nil {
+		return CacheStats{}
+	}
+	return CacheStats{Hits: rc.hits.Load(), Misses: rc.misses.Load()}
+}
+
+// get returns the unexpired response stored under key and counts the lookup
+func (rc *ResponseCache) get(key string) (CachedResponse, bool) {
+	response, ok, err := rc.store.GetResponse(key)
+	if err != nil {
+		log.Printf("⚠️ Failed to read response cache: %v", err)
+	}
+	if ok && !rc.now().Before(response.ExpiresAt) {
+		if err := rc.store.DeleteResponse(key); err != nil {
+			log.Printf("⚠️ Failed to expire cached response: %v", err)
+		}
+		ok = false
+	}
+
+	if !ok {
+		rc.misses.Add(1)
+		return CachedResponse{}, false
+	}
+	hits := rc.hits.Add(1)
+	log.Printf("💾 Cache hit for %s (%d hits, %d misses)", response.Model, hits, rc.misses.Load())
+	return response, true
+}
+
+// put stores a complete answer under key
+func (rc *ResponseCache) put(key, model, answer, reasoning string) {
+	if strings.TrimSpace(answer) == "" {
+		return
+	}
+	response := CachedResponse{Model: model, Answer: answer, Reasoning: reasoning, ExpiresAt: rc.now().Add(rc.ttl)}
+	if err := rc.store.SaveResponse(key, response); err != nil {
+		log.Printf("⚠️ Failed to write response cache: %v", err)
+	}
+}
+
+// normalizeText collapses whitespace and case so trivially different prompts share an entry
+func normalizeText(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
+
+// cacheKey identifies a chat request
+func cacheKey(model string, p ModelParams, thinking bool, messages []Message) string {
+	type keyMessage struct {
+		Role    string `json:"r"`
+		Content string `json:"c"`
+	}
+	key := struct {
+		Model    string       `json:"model"`
+		Params   ModelParams  `json:"params"`
+		Thinking bool         `json:"thinking"`
+		System   string       `json:"system"`
+		Messages []keyMessage `json:"messages"`
+	}{Model: model, Params: p, Thinking: thinking}
+
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			key.System += normalizeText(msg.Content) + "\n"
+			continue
+		}
+		key.Messages = append(key.Messages, keyMessage{Role: msg.Role, Content: normalizeText(msg.Content)})
+	}
+
+	raw, _ := json.Marshal(key)
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// SetCache sets the response cache; nil disables caching
+func (c *Client) SetCache(rc *ResponseCache) {
+	c.cache = rc
+}
+
+// Cache returns the client's response cache
+func (c *Client) Cache() *ResponseCache {
+	return c.cache
+}
+
+// cacheKey returns the key of a chat request and whether it may be cached: a cache is set,
+// no tools are involved and the request did not opt out with RequestOptions.NoCache
+func (c *Client) cacheKey(ctx context.Context, model string, thinking bool, messages []Message) (string, bool) {
+	if c.cache == nil || c.tools.Len() > 0 {
+		return "", false
+	}
+	if opts, ok := requestOptionsFrom(ctx); ok && opts.NoCache {
+		return "", false
+	}
+	for _, msg := range messages {
+		if msg.Role == "tool" || len(msg.ToolCalls) > 0 {
+			return "", false
+		}
+	}
+	return cacheKey(model, resolveParams(ctx, c.params), thinking, messages), true
+}
+
+// cachedStream looks up a streaming request. A hit is replayed; a miss streams from open
+// and caches the answer once the stream completes.
+func (c *Client) cachedStream(ctx context.Context, model string, thinking bool, messages []Message, open func() <-chan StreamEvent) <-chan StreamEvent {
+	key, ok := c.cacheKey(ctx, model, thinking, messages)
+	if !ok {
+		return open()
+	}
+	if response, hit := c.cache.get(key); hit {
+		return replayStream(ctx, response)
+	}
+
+	upstream := open()
+	events := make(chan StreamEvent, streamBuffer)
+	go func() {
+		defer close(events)
+
+		var answer, reasoning strings.Builder
+		for event := range upstream {
+			switch event.Type {
+			case EventAnswer:
+				answer.WriteString(event.Delta)
+			case EventReasoning:
+				reasoning.WriteString(event.Delta)
+			case EventDone:
+				// Truncated answers are not worth repeating
+				if event.FinishReason == "" || event.FinishReason == "stop" {
+					c.cache.put(key, model, answer.String(), reasoning.String())
+				}
+			}
+			if !emit(ctx, events, event) {
+				// Drain so the producer can finish
+				for range upstream {
+				}
+				return
+			}
+		}
+	}()
+	return events
+}
+
+// replayChunk is the size of the pieces a cached answer is streamed in
+const replayChunk = 32
+
+// replayStream streams a cached response in chunks, ending with an EventDone marked Cached
+func replayStream(ctx context.Context, response CachedResponse) <-chan StreamEvent {
+	events := make(chan StreamEvent, streamBuffer)
+	go func() {
+		defer close(events)
+
+		for _, part := range chunkText(response.Reasoning, replayChunk) {
+			if !emit(ctx, events, StreamEvent{Type: EventReasoning, Delta: part}) {
+				return
+			}
+		}
+		for _, part := range chunkText(response.Answer, replayChunk) {
+			if !emit(ctx, events, StreamEvent{Type: EventAnswer, Delta: part}) {
+				return
+			}
+		}
+		emit(ctx, events, StreamEvent{Type: EventDone, FinishReason: "stop", Cached: true})
+	}()
+	return events
+}
+
+// chunkText splits text into pieces of about size bytes, cut after whitespace where possible
+func chunkText(text string, size int) []string {
+	var chunks []string
+	for len(text) > size {
+		cut := strings.IndexAny(text[size:], " \n\t")
+		if cut >= 0 && cut < size {
+			cut += size + 1
+		} else {
+			// No whitespace nearby (CJK text, long URLs): cut at a character boundary
+			cut = size
+			for cut < len(text) && !utf8.RuneStart(text[cut]) {
+				cut++
+			}
+		}
+		chunks = append(chunks, text[:cut])
+		text = text[cut:]
+	}
+	if text != "" {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
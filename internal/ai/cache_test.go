@@ -0,0 +1,124 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	store := NewMemoryCacheStore(2)
+	store.SaveResponse("a", CachedResponse{Answer: "A"})
+	store.SaveResponse("b", CachedResponse{Answer: "B"})
+	store.GetResponse("a")
+	store.SaveResponse("c", CachedResponse{Answer: "C"})
+
+	if _, ok, _ := store.GetResponse("b"); ok {
+		t.Error("Expected the least recently used entry to be evicted")
+	}
+	if response, ok, _ := store.GetResponse("a"); !ok || response.Answer != "A" {
+		t.Error("Expected the recently used entry to be kept")
+	}
+	if store.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", store.Len())
+	}
+}
+
+func TestClientCachesRepeatedChat(t *testing.T) {
+	var calls int32
+	server := modelServer(t, &calls)
+
+	client := NewOpenAICompatibleClient("", server.URL, "llama3")
+	client.SetCache(NewResponseCache(nil, time.Hour))
+
+	ctx := context.Background()
+	for _, question := range []string{"Jam berapa kantor buka?", "  jam berapa KANTOR buka? "} {
+		answer, err := client.Chat(ctx, NewConversation("You are helpful.", nil, question))
+		if err != nil || answer != "llama3" {
+			t.Fatalf("Chat() = %q, %v", answer, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected the repeated question to be served from the cache, got %d calls", calls)
+	}
+
+	// Streams replay the cached answer in chunks
+	var answer string
+	var done StreamEvent
+	for event := range client.ChatStream(ctx, NewConversation("You are helpful.", nil, "jam berapa kantor buka?")) {
+		switch event.Type {
+		case EventAnswer:
+			answer += event.Delta
+		case EventDone:
+			done = event
+		}
+	}
+	if answer != "llama3" || !done.Cached || calls != 1 {
+		t.Errorf("Expected a cached replay, got %q (%+v) after %d calls", answer, done, calls)
+	}
+
+	// Other params, system prompts and opted-out requests go upstream
+	temperature := 0.1
+	client.Chat(WithRequestOptions(ctx, RequestOptions{Temperature: &temperature}), NewConversation("You are helpful.", nil, "jam berapa kantor buka?"))
+	client.Chat(ctx, NewConversation("You are terse.", nil, "jam berapa kantor buka?"))
+	client.Chat(WithRequestOptions(ctx, RequestOptions{NoCache: true}), NewConversation("You are helpful.", nil, "jam berapa kantor buka?"))
+	if calls != 4 {
+		t.Errorf("Expected 3 more upstream calls, got %d", calls-1)
+	}
+
+	if got, want := client.Status().Cache, (CacheStats{Hits: 2, Misses: 3}); got == nil || *got != want {
+		t.Errorf("Status().Cache = %+v, want %+v", got, want)
+	}
+}
+
+func TestCachedStreamStoresCompletedAnswers(t *testing.T) {
+	var calls int32
+	server := modelServer(t, &calls)
+
+	client := NewClient("test-key", server.URL, "qwen-plus")
+	client.SetRetryPolicy(RetryPolicy{})
+	cache := NewResponseCache(nil, time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	client.SetCache(cache)
+
+	messages := NewConversation("", nil, "hi")
+	for i := 0; i < 2; i++ {
+		if response, err := CollectStream(client.ChatStreamWithThinking(context.Background(), messages)); err != nil || response.AnswerContent != "qwen-plus" {
+			t.Fatalf("ChatStreamWithThinking() = %+v, %v", response, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected the second stream to be cached, got %d calls", calls)
+	}
+
+	now = now.Add(2 * time.Minute)
+	CollectStream(client.ChatStreamWithThinking(context.Background(), messages))
+	if calls != 2 {
+		t.Error("Expired answers must not be served")
+	}
+
+	// Requests with tools are never cached
+	tools := NewToolRegistry()
+	tools.Register(Tool{Name: "noop", Func: func(ctx context.Context, args json.RawMessage) (string, error) { return "", nil }})
+	client.SetTools(tools)
+	CollectStream(client.ChatStreamWithThinking(context.Background(), messages))
+	if calls != 3 {
+		t.Error("Requests with tools must go upstream")
+	}
+}
+
+func TestChunkText(t *testing.T) {
+	text := strings.Repeat("kata ", 30) + strings.Repeat("你好", 40)
+	chunks := chunkText(text, 32)
+	if len(chunks) < 4 || strings.Join(chunks, "") != text {
+		t.Fatalf("chunkText() lost text or did not split: %d chunks", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if !strings.HasPrefix(chunk, "kata") && !strings.HasPrefix(chunk, "你") && !strings.HasPrefix(chunk, "好") {
+			t.Errorf("Chunk %q should start at a word or character boundary", chunk)
+		}
+	}
+}
@@ -3,7 +3,6 @@ package ai
 import (
 	"context"
 	"fmt"
-	"io"
 	"strings"
 	"time"
 
@@ -11,13 +10,36 @@ import (
 )
 
 type Client struct {
-	client  *openai.Client
 	Model   string
 	APIKey  string
 	BaseURL string
 	params  ModelParams
 	// Add Qwen thinking client for models that support it
 	qwenThinking *QwenThinkingClient
+	// compatible marks a generic OpenAI-compatible server without DashScope extensions
+	compatible bool
+	retry      RetryPolicy
+	// tools are offered to the model and executed until it produces a final answer
+	tools             *ToolRegistry
+	maxToolIterations int
+	usage             UsageRecorder
+	// audio is the default spoken output; speechModel serves TextToSpeech and transcriptionModel Transcribe
+	audio              AudioOptions
+	speechModel        string
+	transcriptionModel string
+	translationModel   string
+	// embeddingModel serves Embed; embeddingDimensions selects the vector size (0 = model default)
+	embeddingModel      string
+	embeddingDimensions int
+	// images bounds the images embedded in omni requests; video and frames handle uploaded video
+	images ImageOptions
+	video  VideoOptions
+	frames FrameExtractor
+	// router picks the model of each request by task; failover moves unavailable requests down a fallback chain
+	router   *Router
+	failover *Failover
+	// cache answers repeated chat requests; nil disables caching
+	cache *ResponseCache
 }
 
 // ModelParams controls sampling behavior
@@ -27,6 +49,12 @@ type ModelParams struct {
 	TopP        float64 `json:"top_p"`
 	// Enable thinking mode by default for Qwen models
 	EnableThinking bool `json:"enable_thinking"`
+	// ThinkingBudget caps the reasoning tokens when thinking is enabled (0 = model default)
+	ThinkingBudget int `json:"thinking_budget,omitempty"`
+	// MaxTokens caps the answer length (0 = model default)
+	MaxTokens int      `json:"max_tokens,omitempty"`
+	Seed      *int     `json:"seed,omitempty"`
+	Stop      []string `json:"stop,omitempty"`
 }
 
 var defaultParams = ModelParams{
@@ -39,6 +67,21 @@ var defaultParams = ModelParams{
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// ToolCalls is set on assistant messages that requested tools
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID links a "tool" message to the call it answers
+	ToolCallID string `json:"tool_call_id,omitempty"`
+}
+
+// NewConversation builds the message list for a request: an optional system prompt,
+// the previous turns in chronological order and the new user message
+func NewConversation(systemPrompt string, history []Message, userMessage string) []Message {
+	messages := make([]Message, 0, len(history)+2)
+	if systemPrompt != "" {
+		messages = append(messages, Message{Role: "system", Content: systemPrompt})
+	}
+	messages = append(messages, history...)
+	return append(messages, Message{Role: "user", Content: userMessage})
 }
 
 // ThinkingResponse represents the complete thinking process and final response
@@ -50,25 +93,67 @@ type ThinkingResponse struct {
 
 // NewClient creates a new AI client with thinking mode enabled by default
 func NewClient(apiKey, baseURL, model string) *Client {
-	config := openai.DefaultConfig(apiKey)
-	config.BaseURL = baseURL
+	return &Client{
+		Model:              model,
+		APIKey:             apiKey,
+		BaseURL:            baseURL,
+		params:             defaultParams,
+		qwenThinking:       NewQwenThinkingClient(apiKey, baseURL, model),
+		retry:              DefaultRetryPolicy,
+		maxToolIterations:  DefaultMaxToolIterations,
+		audio:              DefaultAudioOptions,
+		speechModel:        DefaultSpeechModel,
+		transcriptionModel: DefaultSpeechModel,
+		translationModel:   DefaultTranslationModel,
+		embeddingModel:     DefaultEmbeddingModel,
+		images:             DefaultImageOptions,
+		video:              DefaultVideoOptions,
+		frames:             FFmpegExtractor{},
+		router:             NewRouter(nil),
+	}
+}
 
-	client := openai.NewClientWithConfig(config)
+// DefaultSpeechModel is the DashScope omni model used by TextToSpeech and Transcribe
+const DefaultSpeechModel = "qwen-omni-turbo"
 
-	// Initialize Qwen thinking client if using a Qwen model
-	var qwenThinking *QwenThinkingClient
-	if strings.Contains(strings.ToLower(model), "qwen") {
-		qwenThinking = NewQwenThinkingClient(apiKey, baseURL, model)
-	}
+// DefaultMediaModel serves image, audio and video requests when TaskMedia is not routed
+// and the chat model cannot read media
+const DefaultMediaModel = "qwen-omni-turbo"
 
-	return &Client{
-		client:       client,
-		Model:        model,
-		APIKey:       apiKey,
-		BaseURL:      baseURL,
-		params:       defaultParams,
-		qwenThinking: qwenThinking,
-	}
+// SetAudioDefaults sets the voice, format and sample rate used when a request does not override them.
+// Empty fields keep the current value.
+func (c *Client) SetAudioDefaults(opts AudioOptions) {
+	c.audio = c.audio.merge(opts)
+}
+
+// SetSpeechModel sets the model used by TextToSpeech
+func (c *Client) SetSpeechModel(model string) {
+	c.speechModel = model
+}
+
+// SetImageOptions sets how images are normalized before omni upload
+func (c *Client) SetImageOptions(opts ImageOptions) {
+	c.images = opts
+}
+
+// SetVideoOptions sets how uploaded videos are sent and sampled
+func (c *Client) SetVideoOptions(opts VideoOptions) {
+	c.video = opts
+}
+
+// SetFrameExtractor replaces the ffmpeg-based frame sampler
+func (c *Client) SetFrameExtractor(extractor FrameExtractor) {
+	c.frames = extractor
+}
+
+// SetTranslationModel sets the model used by Translate
+func (c *Client) SetTranslationModel(model string) {
+	c.translationModel = model
+}
+
+// SetTranscriptionModel sets the model used by Transcribe
+func (c *Client) SetTranscriptionModel(model string) {
+	c.transcriptionModel = model
 }
 
 // SetParams allows overriding default model params at runtime
@@ -88,171 +173,287 @@ func (c *Client) SetThinkingMode(enabled bool) {
 	}
 }
 
-// IsQwenModel checks if the current model supports thinking mode
+// SetRetryPolicy changes how transient upstream failures are retried
+func (c *Client) SetRetryPolicy(p RetryPolicy) {
+	c.retry = p
+	if c.qwenThinking != nil {
+		c.qwenThinking.SetRetryPolicy(p)
+	}
+}
+
+// SetUsageRecorder sets where token usage of every call is reported; nil disables recording
+func (c *Client) SetUsageRecorder(r UsageRecorder) {
+	c.usage = r
+	if c.qwenThinking != nil {
+		c.qwenThinking.SetUsageRecorder(r)
+	}
+}
+
+// SetTools sets the tools offered to the model; nil disables tool calling
+func (c *Client) SetTools(r *ToolRegistry) {
+	c.tools = r
+	if c.qwenThinking != nil {
+		c.qwenThinking.SetTools(r)
+	}
+}
+
+// SetFailover sets the fallback chain tried when an endpoint is unavailable; nil disables failover
+func (c *Client) SetFailover(f *Failover) {
+	c.failover = f
+	if c.qwenThinking != nil {
+		c.qwenThinking.SetFailover(f)
+	}
+}
+
+// do runs attempt against the client's endpoint for model, retried by the RetryPolicy and failed
+// over along the fallback chain. sameModel skips fallbacks that switch to another model.
+func (c *Client) do(ctx context.Context, op, model string, sameModel bool, attempt func(ep Endpoint) error) error {
+	primary := Endpoint{BaseURL: c.BaseURL, APIKey: c.APIKey, Model: model}
+	return c.failover.run(ctx, op, c.retry, primary, sameModel, attempt)
+}
+
+// SetMaxToolIterations bounds the rounds of tool calls per request
+func (c *Client) SetMaxToolIterations(n int) {
+	c.maxToolIterations = n
+}
+
+// IsQwenModel checks if the current model supports thinking mode (Capabilities().Thinking)
 func (c *Client) IsQwenModel() bool {
-	return c.qwenThinking != nil
+	return c.supportsThinking(c.Model)
 }
 
+// supportsThinking reports whether requests to model go through the Qwen thinking client
+func (c *Client) supportsThinking(model string) bool {
+	if c.qwenThinking == nil {
+		return false
+	}
+	caps, _ := LookupModel(model)
+	return caps.Thinking
+}
+
+// Chat sends a conversation and returns the complete answer.
+// Transient failures are retried according to the client's RetryPolicy.
 func (c *Client) Chat(ctx context.Context, messages []Message) (string, error) {
-	// Use streaming collection to support models that require stream=true (e.g., qwen-omni-turbo)
-	// Convert our Message type to OpenAI's ChatCompletionMessage
-	openaiMessages := make([]openai.ChatCompletionMessage, len(messages))
-	for i, msg := range messages {
-		openaiMessages[i] = openai.ChatCompletionMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
+	ctx, model := c.routeChat(ctx, messages)
+	if err := c.checkChat(model, resolveParams(ctx, c.params)); err != nil {
+		return "", err
+	}
+	key, cacheable := c.cacheKey(ctx, model, false, messages)
+	if cacheable {
+		if response, hit := c.cache.get(key); hit {
+			return response.Answer, nil
 		}
 	}
-
-	// Always stream for compatibility and robustness
-	req := openai.ChatCompletionRequest{
-		Model:       c.Model,
-		Messages:    openaiMessages,
-		Stream:      true,
-		Temperature: float32(c.params.Temperature),
-		TopP:        float32(c.params.TopP),
+	if c.tools.Len() > 0 {
+		response, err := CollectStream(runToolLoop(ctx, c.tools, c.maxToolIterations, messages, func(ctx context.Context, messages []Message) <-chan StreamEvent {
+			return c.streamCompletion(ctx, c.completionRequest(ctx, messages), 0)
+		}))
+		if err != nil {
+			return "", err
+		}
+		return response.AnswerContent, nil
 	}
 
-	stream, err := c.client.CreateChatCompletionStream(ctx, req)
+	// Always stream for compatibility and robustness, and for models that require stream=true (e.g., qwen-omni-turbo)
+	req := c.completionRequest(ctx, messages)
+
+	// Nothing reaches the caller before the answer is complete, so every attempt may be retried
+	var full string
+	err := c.do(ctx, "chat request", model, false, func(ep Endpoint) error {
+		var err error
+		full, err = c.chatOnce(ctx, ep, req)
+		return err
+	})
 	if err != nil {
-		// Improve error for omni models
-		if strings.Contains(strings.ToLower(err.Error()), "only support with stream=true") {
-			return "", fmt.Errorf("model requires streaming; retry later: %w", err)
-		}
-		return "", fmt.Errorf("failed to create chat completion stream: %w", err)
+		return "", err
 	}
-	defer stream.Close()
+	if cacheable {
+		c.cache.put(key, model, full, "")
+	}
+	return full, nil
+}
 
-	var full string
-	for {
-		resp, err := stream.Recv()
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return "", fmt.Errorf("stream recv error: %w", err)
+func (c *Client) chatOnce(ctx context.Context, ep Endpoint, req completionStreamRequest) (string, error) {
+	req.Model = ep.Model
+	var full strings.Builder
+	err := postStream(ctx, ep.BaseURL+"/chat/completions", ep.APIKey, req, func(chunk *QwenThinkingStreamResponse) error {
+		if len(chunk.Choices) > 0 {
+			full.WriteString(chunk.Choices[0].Delta.Content)
 		}
-		if len(resp.Choices) > 0 {
-			full += resp.Choices[0].Delta.Content
+		if chunk.Usage != nil {
+			recordUsage(ctx, c.usage, chunk.usage(req.Model), req.Model)
 		}
+		return nil
+	})
+	if err != nil {
+		return "", err
 	}
 
-	if strings.TrimSpace(full) == "" {
+	if strings.TrimSpace(full.String()) == "" {
 		return "", fmt.Errorf("empty response from stream")
 	}
-	return full, nil
+	return full.String(), nil
 }
 
-// ChatStream streams the AI response with callback for each chunk
-func (c *Client) ChatStream(userMessage string, callback func(chunk string, isComplete bool)) {
-	ctx := context.Background()
+// ChatStream streams the AI response to a conversation as typed events.
+// Cancelling ctx aborts the upstream request.
+func (c *Client) ChatStream(ctx context.Context, messages []Message) <-chan StreamEvent {
+	ctx, model := c.routeChat(ctx, messages)
+	if err := c.checkChat(model, resolveParams(ctx, c.params)); err != nil {
+		return failedStream(err)
+	}
+	turn := func(ctx context.Context, messages []Message) <-chan StreamEvent {
+		// Add small delay to simulate thinking/processing
+		return c.streamCompletion(ctx, c.completionRequest(ctx, messages), 50*time.Millisecond)
+	}
 
-	messages := []openai.ChatCompletionMessage{
-		{
-			Role:    "user",
-			Content: userMessage,
-		},
+	if c.tools.Len() > 0 {
+		return runToolLoop(ctx, c.tools, c.maxToolIterations, messages, turn)
 	}
+	return c.cachedStream(ctx, model, false, messages, func() <-chan StreamEvent {
+		return turn(ctx, messages)
+	})
+}
 
-	req := openai.ChatCompletionRequest{
-		Model:    c.Model,
-		Messages: messages,
-		Stream:   true,
+// ChatStreamWithThinking provides enhanced streaming with actual thinking process.
+// messages is the full conversation, optionally starting with a system prompt.
+// Cancelling ctx aborts the upstream request.
+// When tools are set, requested tools are executed and the model is called again until it answers.
+func (c *Client) ChatStreamWithThinking(ctx context.Context, messages []Message) <-chan StreamEvent {
+	ctx, model := c.routeChat(ctx, messages)
+	if err := c.checkChat(model, resolveParams(ctx, c.params)); err != nil {
+		return failedStream(err)
 	}
+	if c.tools.Len() > 0 {
+		return runToolLoop(ctx, c.tools, c.maxToolIterations, messages, c.thinkingTurn)
+	}
+	thinking := c.supportsThinking(model) && resolveParams(ctx, c.params).EnableThinking
+	return c.cachedStream(ctx, model, thinking, messages, func() <-chan StreamEvent {
+		return c.thinkingTurn(ctx, messages)
+	})
+}
 
-	stream, err := c.client.CreateChatCompletionStream(ctx, req)
-	if err != nil {
-		callback(fmt.Sprintf("Error: %v", err), true)
-		return
+// thinkingTurn performs a single streaming request for ChatStreamWithThinking
+func (c *Client) thinkingTurn(ctx context.Context, messages []Message) <-chan StreamEvent {
+	// Use the Qwen thinking client for models with thinking mode
+	if c.supportsThinking(modelFrom(ctx, c.Model)) && resolveParams(ctx, c.params).EnableThinking {
+		return c.qwenThinking.ChatWithThinkingStream(ctx, convertToQwenMessages(messages))
 	}
-	defer stream.Close()
 
-	var fullResponse string
+	// Fallback to regular streaming for non-Qwen models or when thinking is disabled
+	return c.streamCompletion(ctx, c.completionRequest(ctx, messages), 30*time.Millisecond) // Realistic typing delay
+}
+
+// completionRequest builds a streaming request with the resolved params and tools.
+// DashScope extensions are only sent to DashScope, which has a Qwen thinking client.
+func (c *Client) completionRequest(ctx context.Context, messages []Message) completionStreamRequest {
+	model := modelFrom(ctx, c.Model)
+	req := newCompletionStreamRequest(openai.ChatCompletionRequest{
+		Model:    model,
+		Messages: convertToOpenAIMessages(messages),
+		Tools:    c.tools.openAITools(),
+	})
+	applyParams(&req, resolveParams(ctx, c.params), c.qwenThinking != nil, c.supportsThinking(model))
+	return req
+}
 
-	for {
-		response, err := stream.Recv()
+// streamCompletion runs a go-openai stream and converts its chunks into StreamEvents.
+// delay is slept after every content chunk to produce a typing effect.
+func (c *Client) streamCompletion(ctx context.Context, req completionStreamRequest, delay time.Duration) <-chan StreamEvent {
+	events := make(chan StreamEvent, streamBuffer)
+
+	go func() {
+		defer close(events)
+
+		// Transient failures are retried only until the first event reaches the consumer
+		err := c.do(ctx, "chat stream", req.Model, false, func(ep Endpoint) error {
+			return c.streamOnce(ctx, ep, req, delay, events)
+		})
 		if err != nil {
-			if err == io.EOF {
-				// Stream completed successfully
-				callback(fullResponse, true)
-				return
-			}
-			callback(fmt.Sprintf("Stream error: %v", err), true)
-			return
+			emit(ctx, events, StreamEvent{Type: EventError, Err: err})
 		}
+	}()
 
-		if len(response.Choices) > 0 {
-			chunk := response.Choices[0].Delta.Content
-			if chunk != "" {
-				fullResponse += chunk
-				callback(chunk, false)
+	return events
+}
 
-				// Add small delay to simulate thinking/processing
-				time.Sleep(50 * time.Millisecond)
-			}
+// streamOnce performs a single streaming attempt.
+// Errors after the first delivered event are wrapped in partialError.
+func (c *Client) streamOnce(ctx context.Context, ep Endpoint, req completionStreamRequest, delay time.Duration, events chan<- StreamEvent) error {
+	req.Model = ep.Model
+	emitted := false
+	send := func(event StreamEvent) bool {
+		if !emit(ctx, events, event) {
+			return false
 		}
+		emitted = true
+		return true
 	}
-}
 
-// ChatStreamWithThinking provides enhanced streaming with actual thinking process
-func (c *Client) ChatStreamWithThinking(userMessage string, callback func(stage string, content string, isComplete bool)) {
-	// If we have a Qwen thinking client, use it for enhanced thinking mode
-	if c.qwenThinking != nil && c.params.EnableThinking {
-		// Convert to QwenMessage format
-		qwenMessages := []QwenMessage{
-			{Role: "user", Content: userMessage},
+	var finishReason string
+	err := postStream(ctx, ep.BaseURL+"/chat/completions", ep.APIKey, req, func(chunk *QwenThinkingStreamResponse) error {
+		if chunk.Usage != nil {
+			usage := chunk.usage(req.Model)
+			recordUsage(ctx, c.usage, usage, req.Model)
+			if !send(StreamEvent{Type: EventUsage, Usage: usage}) {
+				return ctx.Err()
+			}
 		}
 
-		err := c.qwenThinking.ChatWithThinkingStream(context.Background(), qwenMessages, callback)
-		if err != nil {
-			callback("error", fmt.Sprintf("Thinking mode error: %v", err), true)
+		if len(chunk.Choices) == 0 {
+			return nil
 		}
-		return
-	}
 
-	// Fallback to regular streaming for non-Qwen models or when thinking is disabled
-	ctx := context.Background()
+		choice := chunk.Choices[0]
+		for _, toolCall := range choice.Delta.ToolCalls {
+			if !send(StreamEvent{Type: EventToolCall, ToolCall: toolCall.toDelta()}) {
+				return ctx.Err()
+			}
+		}
 
-	messages := []openai.ChatCompletionMessage{{Role: "user", Content: userMessage}}
+		if choice.FinishReason != "" {
+			finishReason = choice.FinishReason
+		}
 
-	req := openai.ChatCompletionRequest{
-		Model:       c.Model,
-		Messages:    messages,
-		Stream:      true,
-		Temperature: float32(c.params.Temperature),
-		TopP:        float32(c.params.TopP),
-	}
+		if chunk := choice.Delta.Content; chunk != "" {
+			if !send(StreamEvent{Type: EventAnswer, Delta: chunk}) {
+				return ctx.Err()
+			}
 
-	stream, err := c.client.CreateChatCompletionStream(ctx, req)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		callback("error", fmt.Sprintf("Error: %v", err), true)
-		return
+		if emitted {
+			return partialError{err}
+		}
+		return err
 	}
-	defer stream.Close()
 
-	var fullResponse string
+	// Stream completed successfully
+	send(StreamEvent{Type: EventDone, FinishReason: finishReason})
+	return nil
+}
 
-	for {
-		response, err := stream.Recv()
-		if err != nil {
-			if err == io.EOF {
-				// Stream completed successfully
-				callback("complete", fullResponse, true)
-				return
-			}
-			callback("error", fmt.Sprintf("Stream error: %v", err), true)
-			return
-		}
+// completionStreamRequest adds stream_options and DashScope's top-level extensions to
+// go-openai's request type, which lacks the fields
+type completionStreamRequest struct {
+	openai.ChatCompletionRequest
+	StreamOptions  *QwenStreamOptions `json:"stream_options,omitempty"`
+	TopK           *int               `json:"top_k,omitempty"`
+	EnableThinking *bool              `json:"enable_thinking,omitempty"`
+}
 
-		if len(response.Choices) > 0 {
-			chunk := response.Choices[0].Delta.Content
-			if chunk != "" {
-				fullResponse += chunk
-				// Stream directly without complex parsing
-				callback("streaming", chunk, false)
-				time.Sleep(30 * time.Millisecond) // Realistic typing delay
-			}
-		}
+func newCompletionStreamRequest(req openai.ChatCompletionRequest) completionStreamRequest {
+	req.Stream = true
+	return completionStreamRequest{
+		ChatCompletionRequest: req,
+		StreamOptions:         &QwenStreamOptions{IncludeUsage: true},
 	}
 }
 
@@ -261,21 +462,47 @@ func convertToQwenMessages(messages []Message) []QwenMessage {
 	qwenMessages := make([]QwenMessage, len(messages))
 	for i, msg := range messages {
 		qwenMessages[i] = QwenMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCallID: msg.ToolCallID,
+		}
+		for _, call := range msg.ToolCalls {
+			qwenMessages[i].ToolCalls = append(qwenMessages[i].ToolCalls, QwenToolCall{
+				ID:       call.ID,
+				Type:     "function",
+				Function: QwenFunction{Name: call.Name, Arguments: call.Arguments},
+			})
 		}
 	}
 	return qwenMessages
 }
 
+// convertToOpenAIMessages converts Message slice to go-openai messages
+func convertToOpenAIMessages(messages []Message) []openai.ChatCompletionMessage {
+	openaiMessages := make([]openai.ChatCompletionMessage, len(messages))
+	for i, msg := range messages {
+		openaiMessages[i] = openai.ChatCompletionMessage{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCallID: msg.ToolCallID,
+		}
+		for _, call := range msg.ToolCalls {
+			openaiMessages[i].ToolCalls = append(openaiMessages[i].ToolCalls, openai.ToolCall{
+				ID:       call.ID,
+				Type:     openai.ToolTypeFunction,
+				Function: openai.FunctionCall{Name: call.Name, Arguments: call.Arguments},
+			})
+		}
+	}
+	return openaiMessages
+}
+
 // ChatWithThinking provides a complete thinking response with both reasoning and answer
 func (c *Client) ChatWithThinking(ctx context.Context, messages []Message) (*ThinkingResponse, error) {
-	// If we have a Qwen thinking client, use it for enhanced thinking mode
-	if c.qwenThinking != nil && c.params.EnableThinking {
-		// Convert to QwenMessage format
-		qwenMessages := convertToQwenMessages(messages)
-
-		return c.qwenThinking.ChatWithThinking(ctx, qwenMessages)
+	// Use the Qwen thinking client for models with thinking mode
+	ctx, model := c.routeChat(ctx, messages)
+	if c.supportsThinking(model) && resolveParams(ctx, c.params).EnableThinking {
+		return CollectStream(c.ChatStreamWithThinking(ctx, messages))
 	}
 
 	// Fallback to regular chat for non-Qwen models or when thinking is disabled
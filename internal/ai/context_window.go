@@ -0,0 +1,302 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Turn is one exchange of a conversation. ID is the stored conversation's ID;
+// turns without one (0) are trimmed but never folded into a cached summary.
+type Turn struct {
+	ID        int64
+	User      string
+	Assistant string
+}
+
+// TurnMessages converts turns into user/assistant messages in order
+func TurnMessages(turns []Turn) []Message {
+	messages := make([]Message, 0, len(turns)*2)
+	for _, turn := range turns {
+		messages = append(messages,
+			Message{Role: "user", Content: turn.User},
+			Message{Role: "assistant", Content: turn.Assistant},
+		)
+	}
+	return messages
+}
+
+// messageOverhead approximates the tokens the chat template adds around every message
+const messageOverhead = 4
+
+// charsPerToken approximates how many characters of alphabetic text make up one token
+// for the tokenizer family of model. Unknown models get a conservative ratio.
+func charsPerToken(model string) float64 {
+	name := strings.ToLower(model)
+	switch {
+	case strings.HasPrefix(name, "qwen"), strings.HasPrefix(name, "qwq"), strings.HasPrefix(name, "qvq"):
+		return 3.8
+	case strings.HasPrefix(name, "gpt"), strings.HasPrefix(name, "o1"), strings.HasPrefix(name, "o3"):
+		return 4
+	default:
+		return 3.3
+	}
+}
+
+// EstimateTokens approximates the tokens of text for model without a tokenizer:
+// CJK characters count as one token each, other text by the model family's ratio
+func EstimateTokens(model, text string) int {
+	var cjk, other int
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + int(math.Ceil(float64(other)/charsPerToken(model)))
+}
+
+// EstimateMessageTokens approximates the prompt tokens of messages for model
+func EstimateMessageTokens(model string, messages []Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += messageOverhead + EstimateTokens(model, msg.Content)
+		for _, call := range msg.ToolCalls {
+			total += EstimateTokens(model, call.Name+call.Arguments)
+		}
+	}
+	return total
+}
+
+// ConversationSummary is the rolling summary of the turns that no longer fit the context.
+// Through is the ID of the last turn it covers.
+type ConversationSummary struct {
+	Text    string `json:"text"`
+	Through int64  `json:"through"`
+}
+
+// SummaryStore keeps one rolling summary per user
+type SummaryStore interface {
+	GetSummary(userID string) (ConversationSummary, error)
+	SaveSummary(userID string, summary ConversationSummary) error
+}
+
+// SummaryCache is an in-memory SummaryStore, used when no database is configured
+type SummaryCache struct {
+	mu        sync.Mutex
+	summaries map[string]ConversationSummary
+}
+
+func NewSummaryCache() *SummaryCache {
+	return &SummaryCache{summaries: make(map[string]ConversationSummary)}
+}
+
+// GetSummary returns the user's summary (zero value if none)
+func (s *SummaryCache) GetSummary(userID string) (ConversationSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.summaries[userID], nil
+}
+
+// SaveSummary replaces the user's summary
+func (s *SummaryCache) SaveSummary(userID string, summary ConversationSummary) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.summaries[userID] = summary
+	return nil
+}
+
+// ContextOptions bounds the prompt built by ContextBuilder
+type ContextOptions struct {
+	// Budget caps the prompt tokens; the model's context window minus ReserveOutput always applies (0 = window only)
+	Budget int
+	// ReserveOutput is kept free for the answer (0 = the model's MaxOutput, at most 8192)
+	ReserveOutput int
+	// SummaryWords bounds the length of rolling summaries
+	SummaryWords int
+}
+
+// DefaultContextOptions keeps prompts around 6k tokens with summaries of up to 150 words
+var DefaultContextOptions = ContextOptions{
+	Budget:       6000,
+	SummaryWords: 150,
+}
+
+// summaryHeader introduces the rolling summary in the system prompt
+const summaryHeader = "\n\nSummary of the earlier conversation:\n"
+
+// ContextBuilder assembles chat requests that fit the model's token budget. The system
+// prompt (with memory) and the new message are always kept; the oldest turns are dropped
+// and, when an LLM is set, folded into a rolling per-user summary.
+// A nil *ContextBuilder keeps every turn.
+type ContextBuilder struct {
+	llm   LLM
+	model string
+	store SummaryStore
+	opts  ContextOptions
+}
+
+// NewContextBuilder creates a builder for prompts sent to model. llm writes the summaries
+// (nil only drops turns); a nil store keeps summaries in memory.
+func NewContextBuilder(llm LLM, model string, store SummaryStore, opts ContextOptions) *ContextBuilder {
+	if store == nil {
+		store = NewSummaryCache()
+	}
+	if opts.SummaryWords <= 0 {
+		opts.SummaryWords = DefaultContextOptions.SummaryWords
+	}
+	return &ContextBuilder{llm: llm, model: model, store: store, opts: opts}
+}
+
+// Budget returns the prompt token budget
+func (b *ContextBuilder) Budget() int {
+	caps, _ := LookupModel(b.model)
+
+	reserve := b.opts.ReserveOutput
+	if reserve <= 0 {
+		reserve = min(caps.MaxOutput, 8192)
+	}
+	if reserve <= 0 {
+		reserve = 2048
+	}
+
+	budget := caps.ContextWindow - reserve
+	if b.opts.Budget > 0 && b.opts.Budget < budget {
+		budget = b.opts.Budget
+	}
+	return budget
+}
+
+// ResetSummary forgets the rolling summary of userID
+func (b *ContextBuilder) ResetSummary(userID string) error {
+	if b == nil || userID == "" {
+		return nil
+	}
+	return b.store.SaveSummary(userID, ConversationSummary{})
+}
+
+// Build returns the messages for a request: the system prompt with the user's rolling summary,
+// as many recent turns of history (oldest first) as fit, and userMessage
+func (b *ContextBuilder) Build(ctx context.Context, userID, systemPrompt string, history []Turn, userMessage string) []Message {
+	if b == nil {
+		return NewConversation(systemPrompt, TurnMessages(history), userMessage)
+	}
+
+	var summary ConversationSummary
+	if b.llm != nil && userID != "" {
+		var err error
+		if summary, err = b.store.GetSummary(userID); err != nil {
+			log.Printf("⚠️ Failed to load conversation summary for %s: %v", userID, err)
+		}
+	}
+
+	// Turns already folded into the summary are not sent again
+	pending := history
+	for len(pending) > 0 && pending[0].ID != 0 && pending[0].ID <= summary.Through {
+		pending = pending[1:]
+	}
+
+	available := b.Budget() - b.pinnedTokens(systemPrompt, summary.Text, userMessage)
+	if b.turnTokens(pending) <= available {
+		return NewConversation(withSummary(systemPrompt, summary.Text), TurnMessages(pending), userMessage)
+	}
+
+	if b.llm != nil && userID != "" {
+		// Drop down to half the history budget so the next turns fit without summarizing again
+		keep := b.fit(pending, available/2)
+		dropped := pending[:len(pending)-len(keep)]
+		if n := summarizable(dropped); n > 0 {
+			updated, err := b.summarize(ctx, userID, summary.Text, dropped[:n])
+			if err != nil {
+				log.Printf("⚠️ Failed to summarize conversation for %s: %v", userID, err)
+			} else {
+				summary = ConversationSummary{Text: updated, Through: dropped[n-1].ID}
+				if err := b.store.SaveSummary(userID, summary); err != nil {
+					log.Printf("⚠️ Failed to save conversation summary for %s: %v", userID, err)
+				}
+				pending = pending[n:]
+			}
+		}
+		available = b.Budget() - b.pinnedTokens(systemPrompt, summary.Text, userMessage)
+	}
+
+	kept := b.fit(pending, available)
+	if dropped := len(pending) - len(kept); dropped > 0 {
+		log.Printf("✂️ Dropped %d old turn(s) for %s to fit %d tokens", dropped, userID, b.Budget())
+	}
+	return NewConversation(withSummary(systemPrompt, summary.Text), TurnMessages(kept), userMessage)
+}
+
+// pinnedTokens counts what is always sent: the system prompt, the summary and the new message
+func (b *ContextBuilder) pinnedTokens(systemPrompt, summary, userMessage string) int {
+	return EstimateMessageTokens(b.model, NewConversation(withSummary(systemPrompt, summary), nil, userMessage))
+}
+
+func (b *ContextBuilder) turnTokens(turns []Turn) int {
+	return EstimateMessageTokens(b.model, TurnMessages(turns))
+}
+
+// fit returns the longest suffix of turns within budget tokens
+func (b *ContextBuilder) fit(turns []Turn, budget int) []Turn {
+	used := 0
+	for i := len(turns) - 1; i >= 0; i-- {
+		used += b.turnTokens(turns[i : i+1])
+		if used > budget {
+			return turns[i+1:]
+		}
+	}
+	return turns
+}
+
+// summarizable returns how many leading turns have IDs and can be recorded as summarized
+func summarizable(turns []Turn) int {
+	for i, turn := range turns {
+		if turn.ID == 0 {
+			return i
+		}
+	}
+	return len(turns)
+}
+
+func withSummary(systemPrompt, summary string) string {
+	if summary == "" {
+		return systemPrompt
+	}
+	return systemPrompt + summaryHeader + summary
+}
+
+const summaryPrompt = `You maintain the memory of a chat between a user and an AI assistant.
+Merge the existing summary and the new turns into one updated summary of at most %d words.
+Keep facts about the user, decisions, open questions and what was discussed; drop greetings and small talk.
+Write in the language of the conversation, in the third person, without any preamble.`
+
+// summarize folds turns into the previous summary
+func (b *ContextBuilder) summarize(ctx context.Context, userID, previous string, turns []Turn) (string, error) {
+	var transcript strings.Builder
+	if previous != "" {
+		fmt.Fprintf(&transcript, "Existing summary:\n%s\n\n", previous)
+	}
+	transcript.WriteString("New turns:\n")
+	for _, turn := range turns {
+		fmt.Fprintf(&transcript, "User: %s\nAssistant: %s\n\n", turn.User, turn.Assistant)
+	}
+
+	ctx = WithTask(WithUsageTag(ctx, UsageTag{UserID: userID, Purpose: PurposeSummary}), TaskSummary)
+	text, err := b.llm.Chat(ctx, []Message{
+		{Role: "system", Content: fmt.Sprintf(summaryPrompt, b.opts.SummaryWords)},
+		{Role: "user", Content: transcript.String()},
+	})
+	if err != nil {
+		return "", err
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	return text, nil
+}
@@ -0,0 +1,150 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	if got := EstimateTokens("qwen-plus", ""); got != 0 {
+		t.Errorf("EstimateTokens(empty) = %d, want 0", got)
+	}
+	if got := EstimateTokens("qwen-plus", "你好世界"); got != 4 {
+		t.Errorf("Expected one token per CJK character, got %d", got)
+	}
+
+	text := strings.Repeat("halo apa kabar ", 20)
+	qwen, unknown := EstimateTokens("qwen-plus", text), EstimateTokens("llama3", text)
+	if qwen < 70 || qwen > 90 {
+		t.Errorf("EstimateTokens(qwen-plus, 300 chars) = %d, want about 80", qwen)
+	}
+	if unknown <= qwen {
+		t.Errorf("Unknown models should be estimated conservatively, got %d <= %d", unknown, qwen)
+	}
+
+	messages := NewConversation("system", nil, "hi")
+	if got, want := EstimateMessageTokens("qwen-plus", messages), 2*messageOverhead+EstimateTokens("qwen-plus", "system")+1; got != want {
+		t.Errorf("EstimateMessageTokens() = %d, want %d", got, want)
+	}
+}
+
+func TestContextBuilderBudget(t *testing.T) {
+	tests := []struct {
+		model string
+		opts  ContextOptions
+		want  int
+	}{
+		{"qwen-plus", ContextOptions{Budget: 6000}, 6000},
+		{"qwen-plus", ContextOptions{}, 131072 - 8192},
+		{"qwen-mt-turbo", ContextOptions{Budget: 100000}, 16384 - 8192},
+		{"llama3", ContextOptions{ReserveOutput: 1000}, DefaultCapabilities.ContextWindow - 1000},
+		{"llama3", ContextOptions{}, DefaultCapabilities.ContextWindow - 2048},
+	}
+	for _, tt := range tests {
+		if got := NewContextBuilder(nil, tt.model, nil, tt.opts).Budget(); got != tt.want {
+			t.Errorf("Budget(%s, %+v) = %d, want %d", tt.model, tt.opts, got, tt.want)
+		}
+	}
+}
+
+// turns returns n turns with IDs 1..n of about 30 tokens each
+func turns(n int) []Turn {
+	history := make([]Turn, n)
+	for i := range history {
+		history[i] = Turn{ID: int64(i + 1), User: strings.Repeat("tanya ", 10), Assistant: strings.Repeat("jawab ", 10)}
+	}
+	return history
+}
+
+func TestContextBuilderKeepsHistoryWithinBudget(t *testing.T) {
+	fake := NewFakeClient("summary")
+	builder := NewContextBuilder(fake, "qwen-plus", nil, ContextOptions{Budget: 6000})
+
+	messages := builder.Build(context.Background(), "42", "system", turns(5), "hi")
+	if len(messages) != 12 || messages[0].Content != "system" || messages[11].Content != "hi" {
+		t.Errorf("Expected the full history, got %d messages", len(messages))
+	}
+	if len(fake.Calls()) != 0 {
+		t.Error("History within budget must not be summarized")
+	}
+
+	var none *ContextBuilder
+	if got := none.Build(context.Background(), "42", "system", turns(30), "hi"); len(got) != 62 {
+		t.Errorf("A nil builder should keep every turn, got %d messages", len(got))
+	}
+}
+
+func TestContextBuilderSummarizesOldTurns(t *testing.T) {
+	fake := NewFakeClient("User likes coffee.")
+	store := NewSummaryCache()
+	builder := NewContextBuilder(fake, "qwen-plus", store, ContextOptions{Budget: 400})
+
+	history := turns(20)
+	messages := builder.Build(context.Background(), "42", "system", history, "hi")
+	if got := EstimateMessageTokens("qwen-plus", messages); got > 400 {
+		t.Errorf("Prompt has %d tokens, want at most 400", got)
+	}
+	if !strings.HasPrefix(messages[0].Content, "system") || !strings.Contains(messages[0].Content, summaryHeader+"User likes coffee.") {
+		t.Errorf("Expected the summary in the system prompt, got %q", messages[0].Content)
+	}
+	if last := messages[len(messages)-1]; last.Role != "user" || last.Content != "hi" {
+		t.Errorf("The new message must be kept, got %+v", last)
+	}
+
+	calls := fake.Calls()
+	if len(calls) != 1 || !strings.Contains(calls[0][1].Content, "tanya") {
+		t.Fatalf("Expected one summary request with the dropped turns, got %d", len(calls))
+	}
+	summary, _ := store.GetSummary("42")
+	kept := (len(messages) - 2) / 2
+	if summary.Text != "User likes coffee." || summary.Through != int64(20-kept) {
+		t.Errorf("Stored summary = %+v, want it through turn %d", summary, 20-kept)
+	}
+	if messages[1].Content != history[summary.Through].User {
+		t.Error("History should resume right after the summarized turns")
+	}
+
+	// Summarizing made room, so the next turn reuses the summary
+	history = append(history, Turn{ID: 21, User: "lagi", Assistant: "oke"})
+	messages = builder.Build(context.Background(), "42", "system", history, "hi")
+	if len(fake.Calls()) != 1 {
+		t.Error("Expected the cached summary to be reused")
+	}
+	if !strings.Contains(messages[0].Content, "User likes coffee.") || messages[len(messages)-2].Content != "oke" {
+		t.Error("Expected the summary and the latest turn")
+	}
+
+	if err := builder.ResetSummary("42"); err != nil {
+		t.Fatalf("ResetSummary() error = %v", err)
+	}
+	if summary, _ := store.GetSummary("42"); summary.Text != "" || summary.Through != 0 {
+		t.Errorf("Summary not reset: %+v", summary)
+	}
+}
+
+func TestContextBuilderDropsWhenSummaryFails(t *testing.T) {
+	fake := NewFakeClient()
+	fake.Err = errors.New("boom")
+	builder := NewContextBuilder(fake, "qwen-plus", nil, ContextOptions{Budget: 400})
+
+	messages := builder.Build(context.Background(), "42", "system", turns(20), "hi")
+	if got := EstimateMessageTokens("qwen-plus", messages); got > 400 || len(messages) <= 2 {
+		t.Errorf("Expected the most recent turns within 400 tokens, got %d messages with %d tokens", len(messages), got)
+	}
+	if messages[0].Content != "system" || messages[len(messages)-2].Content != strings.Repeat("jawab ", 10) {
+		t.Error("Expected the system prompt and the latest turn")
+	}
+
+	// Turns without IDs are dropped, never summarized
+	fake.Err = nil
+	live := turns(20)
+	for i := range live {
+		live[i].ID = 0
+	}
+	builder.Build(context.Background(), "43", "system", live, "hi")
+	if len(fake.Calls()) != 1 {
+		t.Error("Turns without IDs must not be summarized")
+	}
+}
@@ -0,0 +1,107 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// DefaultEmbeddingModel is the DashScope text embedding model used by Embed
+const DefaultEmbeddingModel = "text-embedding-v4"
+
+// embeddingBatchSize is the most texts DashScope accepts in one embedding request
+const embeddingBatchSize = 10
+
+type embeddingRequest struct {
+	Model          string   `json:"model"`
+	Input          []string `json:"input"`
+	Dimensions     int      `json:"dimensions,omitempty"`
+	EncodingFormat string   `json:"encoding_format"`
+}
+
+type embeddingResponse struct {
+	Model string `json:"model"`
+	Data  []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Usage *QwenUsage `json:"usage"`
+}
+
+// SetEmbeddingModel sets the model used by Embed
+func (c *Client) SetEmbeddingModel(model string) {
+	c.embeddingModel = model
+}
+
+// SetEmbeddingDimensions sets the size of the vectors returned by Embed (0 = model default).
+// text-embedding-v3 and v4 support 64 to 1024 (v4 up to 2048).
+func (c *Client) SetEmbeddingDimensions(n int) {
+	c.embeddingDimensions = n
+}
+
+// Embed returns one embedding vector per text, in order, using the embedding model
+// (SetEmbeddingModel). Texts are sent in batches of at most 10.
+func (c *Client) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	for i, text := range texts {
+		if strings.TrimSpace(text) == "" {
+			return nil, fmt.Errorf("%w: empty text at index %d", ErrBadRequest, i)
+		}
+	}
+
+	ctx, model := c.route(ctx, TaskEmbedding, c.embeddingModel)
+	if err := c.require(model, CapabilityEmbedding); err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += embeddingBatchSize {
+		end := min(start+embeddingBatchSize, len(texts))
+		batch, err := c.embedBatch(ctx, model, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+func (c *Client) embedBatch(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	body := embeddingRequest{
+		Model:          model,
+		Input:          texts,
+		Dimensions:     c.embeddingDimensions,
+		EncodingFormat: "float",
+	}
+
+	var resp embeddingResponse
+	err := c.do(ctx, "embedding request", model, true, func(ep Endpoint) error {
+		resp = embeddingResponse{}
+		return postJSON(ctx, ep.BaseURL+"/embeddings", ep.APIKey, body, &resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Usage != nil {
+		usage := resp.Usage.toUsage()
+		usage.Model = resp.Model
+		recordUsage(ctx, c.usage, usage, model)
+	}
+
+	// The data array is ordered by index, but nothing requires servers to keep that order
+	vectors := make([][]float32, len(texts))
+	for _, item := range resp.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding response has invalid index %d", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	for i, vector := range vectors {
+		if len(vector) == 0 {
+			return nil, fmt.Errorf("embedding response is missing text %d", i)
+		}
+	}
+	return vectors, nil
+}
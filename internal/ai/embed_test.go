@@ -0,0 +1,90 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEmbedBatchesAndOrders(t *testing.T) {
+	var requests []embeddingRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embeddings" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		var req embeddingRequest
+		raw, _ := io.ReadAll(r.Body)
+		json.Unmarshal(raw, &req)
+		requests = append(requests, req)
+
+		// Answer in reverse order; each vector encodes the text's length
+		var data []string
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			data = append(data, fmt.Sprintf(`{"index":%d,"embedding":[%d,1]}`, i, len(req.Input[i])))
+		}
+		fmt.Fprintf(w, `{"model":%q,"data":[%s],"usage":{"prompt_tokens":3,"total_tokens":3}}`, req.Model, strings.Join(data, ","))
+	}))
+	defer server.Close()
+
+	var usage []Usage
+	client := NewClient("test-key", server.URL, "qwen-plus")
+	client.SetEmbeddingDimensions(512)
+	client.SetUsageRecorder(UsageRecorderFunc(func(ctx context.Context, u Usage) { usage = append(usage, u) }))
+
+	texts := make([]string, 12)
+	for i := range texts {
+		texts[i] = strings.Repeat("a", i+1)
+	}
+	vectors, err := client.Embed(context.Background(), texts)
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if len(requests) != 2 || len(requests[0].Input) != 10 || len(requests[1].Input) != 2 {
+		t.Fatalf("Expected batches of 10 and 2, got %d requests", len(requests))
+	}
+	if requests[0].Model != DefaultEmbeddingModel || requests[0].Dimensions != 512 || requests[0].EncodingFormat != "float" {
+		t.Errorf("Unexpected request %+v", requests[0])
+	}
+	for i, vector := range vectors {
+		if len(vector) != 2 || vector[0] != float32(i+1) {
+			t.Errorf("vectors[%d] = %v, want it to match texts[%d]", i, vector, i)
+		}
+	}
+	if len(usage) != 2 || usage[0].Model != DefaultEmbeddingModel || usage[0].TotalTokens != 3 {
+		t.Errorf("Expected usage per batch, got %+v", usage)
+	}
+}
+
+func TestEmbedRejects(t *testing.T) {
+	client := NewClient("test-key", "http://127.0.0.1:1", "qwen-plus")
+	if _, err := client.Embed(context.Background(), []string{"ok", " "}); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Expected ErrBadRequest for an empty text, got %v", err)
+	}
+
+	client.SetEmbeddingModel("qwen-plus")
+	if _, err := client.Embed(context.Background(), []string{"ok"}); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Expected ErrNotSupported for a chat model, got %v", err)
+	}
+}
+
+func TestFakeEmbedIsSimilarForSharedWords(t *testing.T) {
+	vectors, err := NewFakeClient().Embed(context.Background(), []string{"kopi susu", "Kopi hitam!", "naik kereta"})
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	dot := func(a, b []float32) (sum float32) {
+		for i := range a {
+			sum += a[i] * b[i]
+		}
+		return sum
+	}
+	if dot(vectors[0], vectors[1]) <= dot(vectors[0], vectors[2]) {
+		t.Error("Texts sharing a word should be more similar")
+	}
+}
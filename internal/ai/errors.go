@@ -0,0 +1,208 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Error kinds for failed upstream calls. Use errors.Is to test an error returned by the client.
+var (
+	ErrRateLimited         = errors.New("rate limited")
+	ErrQuotaExceeded       = errors.New("quota exceeded")
+	ErrContentFiltered     = errors.New("content filtered")
+	ErrBadRequest          = errors.New("bad request")
+	ErrUpstreamUnavailable = errors.New("upstream unavailable")
+)
+
+// APIError describes a failed upstream call
+type APIError struct {
+	// Kind is one of the Err* sentinels above, or nil when the failure could not be classified
+	Kind       error
+	StatusCode int
+	Code       string
+	Message    string
+	// RetryAfter is the delay requested by the server, if any
+	RetryAfter time.Duration
+	// Err is the underlying transport error for failures without an HTTP response
+	Err error
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	if e.Kind != nil {
+		b.WriteString(e.Kind.Error())
+	} else {
+		b.WriteString("API error")
+	}
+	if e.StatusCode != 0 {
+		fmt.Fprintf(&b, " (status %d", e.StatusCode)
+		if e.Code != "" {
+			fmt.Fprintf(&b, ", code %s", e.Code)
+		}
+		b.WriteString(")")
+	}
+	if e.Message != "" {
+		b.WriteString(": ")
+		b.WriteString(e.Message)
+	} else if e.Err != nil {
+		b.WriteString(": ")
+		b.WriteString(e.Err.Error())
+	}
+	return b.String()
+}
+
+// Unwrap exposes both the error kind and the transport error to errors.Is/As
+func (e *APIError) Unwrap() []error {
+	var errs []error
+	if e.Kind != nil {
+		errs = append(errs, e.Kind)
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
+// Retryable reports whether repeating the request may succeed
+func (e *APIError) Retryable() bool {
+	return e.Kind == ErrRateLimited || e.Kind == ErrUpstreamUnavailable
+}
+
+// dashScopeErrorBody covers both the OpenAI-compatible and the native DashScope error formats
+type dashScopeErrorBody struct {
+	Error *struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    any    `json:"code"`
+	} `json:"error"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// parseAPIError builds an APIError from a non-2xx response
+func parseAPIError(statusCode int, header http.Header, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode}
+
+	var parsed dashScopeErrorBody
+	if err := json.Unmarshal(body, &parsed); err == nil {
+		if parsed.Error != nil {
+			apiErr.Message = parsed.Error.Message
+			apiErr.Code = codeString(parsed.Error.Code)
+			if apiErr.Code == "" {
+				apiErr.Code = parsed.Error.Type
+			}
+		} else {
+			apiErr.Message = parsed.Message
+			apiErr.Code = parsed.Code
+		}
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+
+	if header != nil {
+		apiErr.RetryAfter = parseRetryAfter(header.Get("Retry-After"))
+	}
+	apiErr.Kind = classify(statusCode, apiErr.Code, apiErr.Message)
+	return apiErr
+}
+
+// classify maps a status code and DashScope error code to an error kind
+func classify(statusCode int, code, message string) error {
+	c := strings.ToLower(code)
+	m := strings.ToLower(message)
+
+	switch {
+	case strings.Contains(c, "datainspection") || strings.Contains(c, "data_inspection") ||
+		strings.Contains(m, "inappropriate content"):
+		return ErrContentFiltered
+	case strings.Contains(c, "arrearage") || strings.Contains(c, "insufficient_quota") ||
+		strings.Contains(c, "allocationquota") || strings.Contains(c, "freetieronly") ||
+		strings.Contains(m, "quota exceeded"):
+		return ErrQuotaExceeded
+	case statusCode == http.StatusTooManyRequests || strings.HasPrefix(c, "throttling"):
+		return ErrRateLimited
+	case statusCode >= 500:
+		return ErrUpstreamUnavailable
+	case statusCode >= 400:
+		return ErrBadRequest
+	}
+	return nil
+}
+
+func codeString(code any) string {
+	switch v := code.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.Itoa(int(v))
+	}
+	return ""
+}
+
+// parseRetryAfter accepts both delay-seconds and HTTP-date values
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// classifyError converts transport and SDK errors into an *APIError where possible.
+// Context cancellation and already-classified errors are returned unchanged.
+func classifyError(err error) error {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return err
+	}
+
+	var sdkErr *openai.APIError
+	if errors.As(err, &sdkErr) {
+		return &APIError{
+			Kind:       classify(sdkErr.HTTPStatusCode, codeString(sdkErr.Code), sdkErr.Message),
+			StatusCode: sdkErr.HTTPStatusCode,
+			Code:       codeString(sdkErr.Code),
+			Message:    sdkErr.Message,
+		}
+	}
+
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return &APIError{
+			Kind:       classify(reqErr.HTTPStatusCode, "", ""),
+			StatusCode: reqErr.HTTPStatusCode,
+			Err:        reqErr.Err,
+		}
+	}
+
+	// Connection resets, refused connections and timeouts
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, net.ErrClosed) ||
+		strings.Contains(err.Error(), "connection reset") || strings.Contains(err.Error(), "unexpected EOF") {
+		return &APIError{Kind: ErrUpstreamUnavailable, Err: err}
+	}
+
+	return err
+}
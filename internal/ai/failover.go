@@ -0,0 +1,374 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Endpoint is one link of a fallback chain. Empty fields inherit from the request it
+// replaces: the client's base URL and API key, and the model chosen for the request.
+type Endpoint struct {
+	// Name labels the endpoint in logs and Status (default: the host of BaseURL)
+	Name    string
+	BaseURL string
+	APIKey  string
+	Model   string
+}
+
+// resolve fills the empty fields of e from primary
+func (e Endpoint) resolve(primary Endpoint) Endpoint {
+	if e.BaseURL == "" {
+		e.BaseURL = primary.BaseURL
+	}
+	if e.APIKey == "" {
+		e.APIKey = primary.APIKey
+	}
+	if e.Model == "" {
+		e.Model = primary.Model
+	}
+	if e.Name == "" {
+		e.Name = endpointName(e.BaseURL)
+	}
+	return e
+}
+
+// key identifies the circuit breaker of an endpoint and model
+func (e Endpoint) key() string {
+	return e.Name + "/" + e.Model
+}
+
+func endpointName(baseURL string) string {
+	if u, err := url.Parse(baseURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return baseURL
+}
+
+// ParseEndpoints parses a comma-separated fallback chain. Each entry is a model
+// ("qwen-turbo"), a base URL ("https://dashscope.aliyuncs.com/compatible-mode/v1")
+// or both ("qwen-turbo@https://dashscope.aliyuncs.com/compatible-mode/v1").
+// apiKey is used for entries with a base URL; empty keeps the client's key.
+func ParseEndpoints(spec, apiKey string) ([]Endpoint, error) {
+	var endpoints []Endpoint
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		var ep Endpoint
+		model, baseURL, found := strings.Cut(entry, "@")
+		switch {
+		case found:
+			ep.Model, ep.BaseURL = strings.TrimSpace(model), strings.TrimSpace(baseURL)
+		case strings.Contains(entry, "://"):
+			ep.BaseURL = entry
+		default:
+			ep.Model = entry
+		}
+		if ep.BaseURL != "" {
+			if u, err := url.Parse(ep.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+				return nil, fmt.Errorf("invalid fallback base URL in %q", entry)
+			}
+			ep.APIKey = apiKey
+		}
+		if ep.BaseURL == "" && ep.Model == "" {
+			return nil, fmt.Errorf("invalid fallback %q, want model, base URL or model@baseURL", entry)
+		}
+		endpoints = append(endpoints, ep)
+	}
+	return endpoints, nil
+}
+
+// BreakerPolicy controls when an endpoint is skipped
+type BreakerPolicy struct {
+	// Threshold is the number of consecutive failures that opens the breaker
+	Threshold int
+	// Cooldown is how long an open breaker skips the endpoint before it is tried again
+	Cooldown time.Duration
+}
+
+// DefaultBreakerPolicy opens after 3 consecutive failures for 30s
+var DefaultBreakerPolicy = BreakerPolicy{
+	Threshold: 3,
+	Cooldown:  30 * time.Second,
+}
+
+// Breaker states reported by Status
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+type breaker struct {
+	endpoint  Endpoint
+	failures  int
+	openUntil time.Time
+	lastError string
+}
+
+func (b *breaker) state(now time.Time, policy BreakerPolicy) string {
+	switch {
+	case b.failures < policy.Threshold:
+		return BreakerClosed
+	case now.Before(b.openUntil):
+		return BreakerOpen
+	default:
+		return BreakerHalfOpen
+	}
+}
+
+// EndpointStatus is the circuit breaker state of an endpoint and model
+type EndpointStatus struct {
+	Name      string     `json:"name"`
+	BaseURL   string     `json:"base_url,omitempty"`
+	Model     string     `json:"model"`
+	State     string     `json:"state"`
+	Failures  int        `json:"failures"`
+	OpenUntil *time.Time `json:"open_until,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// Failover sends a request to the next endpoint of a fallback chain when the current one
+// is unavailable (5xx, timeouts, dropped connections), but only before any output was
+// streamed. Endpoints that keep failing are skipped by a circuit breaker until their
+// cooldown expires. A nil *Failover only uses the primary endpoint.
+type Failover struct {
+	mu        sync.Mutex
+	fallbacks []Endpoint
+	policy    BreakerPolicy
+	breakers  map[string]*breaker
+	// order keeps breakers in the order they were first used
+	order []string
+	now   func() time.Time
+}
+
+// NewFailover creates a failover chain trying fallbacks in order after the primary endpoint
+func NewFailover(policy BreakerPolicy, fallbacks ...Endpoint) *Failover {
+	if policy.Threshold <= 0 {
+		policy.Threshold = DefaultBreakerPolicy.Threshold
+	}
+	if policy.Cooldown <= 0 {
+		policy.Cooldown = DefaultBreakerPolicy.Cooldown
+	}
+	return &Failover{
+		fallbacks: fallbacks,
+		policy:    policy,
+		breakers:  make(map[string]*breaker),
+		now:       time.Now,
+	}
+}
+
+// chain returns the endpoints to try for a request to primary. sameModel drops
+// fallbacks that switch models, for requests only the chosen model can serve.
+func (f *Failover) chain(primary Endpoint, sameModel bool) []Endpoint {
+	primary = primary.resolve(primary)
+	chain := []Endpoint{primary}
+	seen := map[string]bool{primary.key(): true}
+	for _, ep := range f.fallbacks {
+		ep = ep.resolve(primary)
+		if seen[ep.key()] || (sameModel && ep.Model != primary.Model) {
+			continue
+		}
+		seen[ep.key()] = true
+		chain = append(chain, ep)
+	}
+	return chain
+}
+
+// available drops endpoints whose breaker is open. If every breaker is open the
+// whole chain is returned, since trying is better than failing outright.
+func (f *Failover) available(chain []Endpoint) []Endpoint {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.now()
+	var open []Endpoint
+	for _, ep := range chain {
+		if b, ok := f.breakers[ep.key()]; !ok || b.state(now, f.policy) != BreakerOpen {
+			open = append(open, ep)
+		}
+	}
+	if len(open) == 0 {
+		return chain
+	}
+	return open
+}
+
+func (f *Failover) breaker(ep Endpoint) *breaker {
+	b, ok := f.breakers[ep.key()]
+	if !ok {
+		b = &breaker{endpoint: ep}
+		f.breakers[ep.key()] = b
+		f.order = append(f.order, ep.key())
+	}
+	return b
+}
+
+func (f *Failover) success(ep Endpoint) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b := f.breaker(ep)
+	if b.failures >= f.policy.Threshold {
+		log.Printf("✅ %s (%s) recovered", ep.Name, ep.Model)
+	}
+	b.failures = 0
+	b.openUntil = time.Time{}
+}
+
+func (f *Failover) failure(ep Endpoint, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b := f.breaker(ep)
+	b.failures++
+	b.lastError = err.Error()
+	if b.failures >= f.policy.Threshold {
+		b.openUntil = f.now().Add(f.policy.Cooldown)
+		log.Printf("🔌 Circuit open for %s (%s) for %s after %d failures", ep.Name, ep.Model, f.policy.Cooldown, b.failures)
+	}
+}
+
+// Status returns the breaker state of every endpoint and model used so far
+func (f *Failover) Status() []EndpointStatus {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.now()
+	status := make([]EndpointStatus, 0, len(f.order))
+	for _, key := range f.order {
+		b := f.breakers[key]
+		s := EndpointStatus{
+			Name:      b.endpoint.Name,
+			BaseURL:   b.endpoint.BaseURL,
+			Model:     b.endpoint.Model,
+			State:     b.state(now, f.policy),
+			Failures:  b.failures,
+			LastError: b.lastError,
+		}
+		if s.State == BreakerOpen {
+			openUntil := b.openUntil
+			s.OpenUntil = &openUntil
+		}
+		status = append(status, s)
+	}
+	return status
+}
+
+// Fallbacks returns the configured fallback chain
+func (f *Failover) Fallbacks() []Endpoint {
+	if f == nil {
+		return nil
+	}
+	return append([]Endpoint(nil), f.fallbacks...)
+}
+
+// shouldFailOver reports whether another endpoint may succeed where ep failed with err
+func shouldFailOver(ctx context.Context, err error) bool {
+	return ctx.Err() == nil && errors.Is(err, ErrUpstreamUnavailable)
+}
+
+// run calls attempt for primary, retried according to retry, and moves down the chain while
+// endpoints are unavailable. Failures after output reached the caller end the request.
+func (f *Failover) run(ctx context.Context, op string, retry RetryPolicy, primary Endpoint, sameModel bool, attempt func(ep Endpoint) error) error {
+	if f == nil {
+		return retry.do(ctx, op, func() error { return attempt(primary) })
+	}
+
+	chain := f.available(f.chain(primary, sameModel))
+	var err error
+	for i, ep := range chain {
+		partial := false
+		err = retry.do(ctx, op, func() error {
+			err := attempt(ep)
+			var p partialError
+			if errors.As(err, &p) {
+				partial = true
+			}
+			return err
+		})
+		if err == nil {
+			f.success(ep)
+			return nil
+		}
+		if partial || !shouldFailOver(ctx, err) {
+			return err
+		}
+
+		f.failure(ep, err)
+		if i+1 < len(chain) {
+			next := chain[i+1]
+			log.Printf("↪️ %s failed on %s (%s), failing over to %s (%s): %v", op, ep.Name, ep.Model, next.Name, next.Model, err)
+		}
+	}
+	return err
+}
+
+// Status is a snapshot of the client's routing and failover state
+type Status struct {
+	Model string `json:"model"`
+	// Routes are the configured task routes and Requests the decisions made so far
+	Routes   map[Task]string `json:"routes"`
+	Requests []RouteCount    `json:"requests"`
+	// Fallbacks is the configured chain as model@base_url; empty parts inherit from the request
+	Fallbacks []string         `json:"fallbacks"`
+	Endpoints []EndpointStatus `json:"endpoints"`
+	// Cache counts response cache hits and misses; nil when caching is disabled
+	Cache *CacheStats `json:"cache,omitempty"`
+}
+
+// Status reports the router, circuit breakers and response cache. API keys are never included.
+func (c *Client) Status() Status {
+	status := Status{
+		Model:     c.Model,
+		Routes:    c.router.Routes(),
+		Requests:  c.router.Stats(),
+		Fallbacks: []string{},
+		Endpoints: c.failover.Status(),
+	}
+	for _, ep := range c.failover.Fallbacks() {
+		status.Fallbacks = append(status.Fallbacks, ep.Model+"@"+ep.BaseURL)
+	}
+	if status.Requests == nil {
+		status.Requests = []RouteCount{}
+	}
+	if status.Endpoints == nil {
+		status.Endpoints = []EndpointStatus{}
+	}
+	if c.cache != nil {
+		stats := c.cache.Stats()
+		status.Cache = &stats
+	}
+	return status
+}
+
+// Public returns the status without base URLs and upstream error messages, which can reveal
+// internal hosts, for unauthenticated callers
+func (s Status) Public() Status {
+	public := s
+	public.Fallbacks = make([]string, len(s.Fallbacks))
+	for i, fallback := range s.Fallbacks {
+		public.Fallbacks[i], _, _ = strings.Cut(fallback, "@")
+	}
+	public.Endpoints = make([]EndpointStatus, len(s.Endpoints))
+	for i, ep := range s.Endpoints {
+		if ep.Name == endpointName(ep.BaseURL) {
+			// The default name is the host
+			ep.Name = fmt.Sprintf("endpoint-%d", i+1)
+		}
+		ep.BaseURL, ep.LastError = "", ""
+		public.Endpoints[i] = ep
+	}
+	return public
+}
@@ -0,0 +1,234 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseEndpoints(t *testing.T) {
+	got, err := ParseEndpoints("https://dashscope.aliyuncs.com/compatible-mode/v1, qwen-turbo ,qwen-plus@http://backup:8080/v1", "beijing-key")
+	if err != nil {
+		t.Fatalf("ParseEndpoints() error = %v", err)
+	}
+	want := []Endpoint{
+		{BaseURL: "https://dashscope.aliyuncs.com/compatible-mode/v1", APIKey: "beijing-key"},
+		{Model: "qwen-turbo"},
+		{BaseURL: "http://backup:8080/v1", APIKey: "beijing-key", Model: "qwen-plus"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseEndpoints() = %+v, want %+v", got, want)
+	}
+
+	for _, spec := range []string{"@", "qwen-plus@not a url"} {
+		if _, err := ParseEndpoints(spec, ""); err == nil {
+			t.Errorf("ParseEndpoints(%q) should fail", spec)
+		}
+	}
+}
+
+// modelServer answers every request with the requested model's name and counts the calls
+func modelServer(t *testing.T, calls *int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		var body struct {
+			Model string `json:"model"`
+		}
+		raw, _ := io.ReadAll(r.Body)
+		json.Unmarshal(raw, &body)
+		fmt.Fprintf(w, `data: {"choices":[{"delta":{"content":%q}}]}`+"\n\n", body.Model)
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// downServer fails every request with 503 and counts the calls
+func downServer(t *testing.T, calls *int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		http.Error(w, `{"error":{"message":"busy"}}`, http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFailoverToNextEndpoint(t *testing.T) {
+	var primaryCalls, backupCalls int32
+	primary := downServer(t, &primaryCalls)
+	backup := modelServer(t, &backupCalls)
+
+	client := NewClient("test-key", primary.URL, "qwen-plus")
+	client.SetRetryPolicy(RetryPolicy{MaxRetries: 1, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	client.SetFailover(NewFailover(BreakerPolicy{Threshold: 2, Cooldown: time.Minute},
+		Endpoint{BaseURL: backup.URL},
+		Endpoint{Model: "qwen-turbo"},
+	))
+
+	got, err := client.Chat(context.Background(), NewConversation("", nil, "hi"))
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if got != "qwen-plus" {
+		t.Errorf("Expected the backup endpoint to serve qwen-plus, got %q", got)
+	}
+	if primaryCalls != 2 || backupCalls != 1 {
+		t.Errorf("Expected the primary to be retried once before failing over, got %d/%d calls", primaryCalls, backupCalls)
+	}
+
+	// The thinking client shares the chain
+	response, err := client.ChatWithThinking(context.Background(), NewConversation("", nil, "hi"))
+	if err != nil || response.AnswerContent != "qwen-plus" {
+		t.Fatalf("ChatWithThinking() = %+v, %v", response, err)
+	}
+
+	// Two failures opened the primary's breaker, so it is skipped
+	primaryCalls, backupCalls = 0, 0
+	if _, err := client.Chat(context.Background(), NewConversation("", nil, "hi")); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if primaryCalls != 0 || backupCalls != 1 {
+		t.Errorf("Expected the open breaker to skip the primary, got %d/%d calls", primaryCalls, backupCalls)
+	}
+
+	status := client.Status()
+	if len(status.Endpoints) != 2 || status.Endpoints[0].State != BreakerOpen || status.Endpoints[0].OpenUntil == nil ||
+		status.Endpoints[1].State != BreakerClosed {
+		t.Errorf("Unexpected endpoint status %+v", status.Endpoints)
+	}
+	if want := []string{"@" + backup.URL, "qwen-turbo@"}; !reflect.DeepEqual(status.Fallbacks, want) {
+		t.Errorf("Status().Fallbacks = %v, want %v", status.Fallbacks, want)
+	}
+	raw, _ := json.Marshal(status)
+	if strings.Contains(string(raw), "test-key") {
+		t.Error("Status must not expose API keys")
+	}
+
+	public := status.Public()
+	if want := []string{"", "qwen-turbo"}; !reflect.DeepEqual(public.Fallbacks, want) {
+		t.Errorf("Public().Fallbacks = %v, want %v", public.Fallbacks, want)
+	}
+	raw, _ = json.Marshal(public)
+	if strings.Contains(string(raw), "127.0.0.1") || strings.Contains(string(raw), "last_error") {
+		t.Errorf("Public status exposes hosts or errors: %s", raw)
+	}
+	if status.Endpoints[0].LastError == "" {
+		t.Error("Public() must not modify the full status")
+	}
+}
+
+func TestBreakerHalfOpensAfterCooldown(t *testing.T) {
+	var primaryCalls, backupCalls int32
+	primary := downServer(t, &primaryCalls)
+	backup := modelServer(t, &backupCalls)
+
+	now := time.Now()
+	failover := NewFailover(BreakerPolicy{Threshold: 1, Cooldown: 30 * time.Second}, Endpoint{BaseURL: backup.URL})
+	failover.now = func() time.Time { return now }
+
+	client := NewOpenAICompatibleClient("", primary.URL, "llama3")
+	client.SetRetryPolicy(RetryPolicy{})
+	client.SetFailover(failover)
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Chat(context.Background(), NewConversation("", nil, "hi")); err != nil {
+			t.Fatalf("Chat() error = %v", err)
+		}
+	}
+	if primaryCalls != 1 {
+		t.Errorf("Expected the primary to be skipped while open, got %d calls", primaryCalls)
+	}
+
+	now = now.Add(31 * time.Second)
+	if state := failover.Status()[0].State; state != BreakerHalfOpen {
+		t.Errorf("Expected half-open after the cooldown, got %s", state)
+	}
+	if _, err := client.Chat(context.Background(), NewConversation("", nil, "hi")); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if primaryCalls != 2 || failover.Status()[0].State != BreakerOpen {
+		t.Errorf("Expected one trial request that reopens the breaker, got %d calls, %+v", primaryCalls, failover.Status()[0])
+	}
+}
+
+func TestFailoverOnlyBeforeOutput(t *testing.T) {
+	var primaryCalls, backupCalls int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&primaryCalls, 1)
+		fmt.Fprint(w, `data: {"choices":[{"delta":{"content":"partial"}}]}`+"\n\n")
+		w.(http.Flusher).Flush()
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer primary.Close()
+	backup := modelServer(t, &backupCalls)
+
+	client := NewOpenAICompatibleClient("", primary.URL, "llama3")
+	client.SetRetryPolicy(RetryPolicy{})
+	client.SetFailover(NewFailover(DefaultBreakerPolicy, Endpoint{BaseURL: backup.URL}))
+
+	var answer string
+	var streamErr error
+	for event := range client.ChatStream(context.Background(), NewConversation("", nil, "hi")) {
+		switch event.Type {
+		case EventAnswer:
+			answer += event.Delta
+		case EventError:
+			streamErr = event.Err
+		}
+	}
+	if answer != "partial" || !errors.Is(streamErr, ErrUpstreamUnavailable) {
+		t.Errorf("Expected the partial answer and the error, got %q, %v", answer, streamErr)
+	}
+	if backupCalls != 0 {
+		t.Errorf("Must not fail over after output was streamed, got %d backup calls", backupCalls)
+	}
+}
+
+func TestFailoverKeepsModelForMedia(t *testing.T) {
+	var primaryCalls, backupCalls int32
+	primary := downServer(t, &primaryCalls)
+	backup := modelServer(t, &backupCalls)
+
+	client := NewClient("test-key", primary.URL, "qwen-omni-turbo")
+	client.SetRetryPolicy(RetryPolicy{})
+	client.SetFailover(NewFailover(DefaultBreakerPolicy, Endpoint{Model: "qwen-plus", BaseURL: backup.URL}))
+
+	_, err := client.ChatOmni(context.Background(), "", "hi", nil, nil, nil, false)
+	if !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Errorf("Expected the primary's error, got %v", err)
+	}
+	if backupCalls != 0 {
+		t.Error("Omni requests must not fail over to another model")
+	}
+}
+
+func TestNoFailoverForBadRequest(t *testing.T) {
+	var backupCalls int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"message":"invalid","code":"InvalidParameter"}}`, http.StatusBadRequest)
+	}))
+	defer primary.Close()
+	backup := modelServer(t, &backupCalls)
+
+	client := NewOpenAICompatibleClient("", primary.URL, "llama3")
+	client.SetFailover(NewFailover(DefaultBreakerPolicy, Endpoint{BaseURL: backup.URL}))
+
+	if _, err := client.Chat(context.Background(), NewConversation("", nil, "hi")); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Expected ErrBadRequest, got %v", err)
+	}
+	if backupCalls != 0 || len(client.Status().Endpoints) != 0 {
+		t.Error("A bad request is not an endpoint failure")
+	}
+}
@@ -0,0 +1,216 @@
+package ai
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"sync"
+)
+
+// FakeClient is an in-process LLM that returns canned responses.
+// It lets consumers be unit-tested without network access.
+type FakeClient struct {
+	mu sync.Mutex
+
+	// Responses are returned in order; the last one is repeated once exhausted
+	Responses []string
+	// Reasoning is streamed as thinking content before every answer
+	Reasoning string
+	// Audio is returned by ChatOmni, ChatOmniStream and TextToSpeech when audio output is requested
+	Audio []byte
+	// Err, when set, makes every call fail
+	Err error
+
+	calls [][]Message
+}
+
+// NewFakeClient creates a fake client that answers with the given responses
+func NewFakeClient(responses ...string) *FakeClient {
+	return &FakeClient{Responses: responses}
+}
+
+// Calls returns the messages received by every call so far
+func (f *FakeClient) Calls() [][]Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	calls := make([][]Message, len(f.calls))
+	copy(calls, f.calls)
+	return calls
+}
+
+func (f *FakeClient) next(messages []Message) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, messages)
+	if f.Err != nil {
+		return "", f.Err
+	}
+
+	i := len(f.calls) - 1
+	if i >= len(f.Responses) {
+		i = len(f.Responses) - 1
+	}
+	if i < 0 {
+		return "ok", nil
+	}
+	return f.Responses[i], nil
+}
+
+func (f *FakeClient) Chat(ctx context.Context, messages []Message) (string, error) {
+	return f.next(messages)
+}
+
+func (f *FakeClient) ChatStream(ctx context.Context, messages []Message) <-chan StreamEvent {
+	return f.stream(ctx, messages, "", nil)
+}
+
+func (f *FakeClient) ChatStreamWithThinking(ctx context.Context, messages []Message) <-chan StreamEvent {
+	return f.stream(ctx, messages, f.Reasoning, nil)
+}
+
+// stream replays a canned response; audio, when set, is sent as a single EventAudio chunk
+func (f *FakeClient) stream(ctx context.Context, messages []Message, reasoning string, audio []byte) <-chan StreamEvent {
+	response, err := f.next(messages)
+
+	events := make(chan StreamEvent, streamBuffer)
+	go func() {
+		defer close(events)
+
+		if err != nil {
+			emit(ctx, events, StreamEvent{Type: EventError, Err: err})
+			return
+		}
+
+		for _, chunk := range splitChunks(reasoning) {
+			if !emit(ctx, events, StreamEvent{Type: EventReasoning, Delta: chunk}) {
+				return
+			}
+		}
+		for _, chunk := range splitChunks(response) {
+			if ctx.Err() != nil {
+				emit(ctx, events, StreamEvent{Type: EventError, Err: ctx.Err()})
+				return
+			}
+			if !emit(ctx, events, StreamEvent{Type: EventAnswer, Delta: chunk}) {
+				return
+			}
+		}
+		if len(audio) > 0 {
+			if !emit(ctx, events, StreamEvent{Type: EventAudio, Audio: ToBase64(audio)}) {
+				return
+			}
+		}
+		emit(ctx, events, StreamEvent{Type: EventDone, FinishReason: "stop"})
+	}()
+
+	return events
+}
+
+func (f *FakeClient) ChatWithThinking(ctx context.Context, messages []Message) (*ThinkingResponse, error) {
+	response, err := f.next(messages)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ThinkingResponse{
+		ReasoningContent: f.Reasoning,
+		AnswerContent:    response,
+		IsComplete:       true,
+	}, nil
+}
+
+func (f *FakeClient) ChatOmni(ctx context.Context, systemPrompt string, userText string, images []OmniMedia, inputAudio *OmniMedia, video *OmniVideo, wantAudio bool) (OmniResponse, error) {
+	return CollectOmniStream(f.ChatOmniStream(ctx, systemPrompt, userText, images, inputAudio, video, wantAudio))
+}
+
+// TextToSpeech returns Audio as mp3 speech of text
+func (f *FakeClient) TextToSpeech(ctx context.Context, text, voice string) (*Speech, error) {
+	if _, err := f.next([]Message{{Role: "user", Content: text}}); err != nil {
+		return nil, err
+	}
+	return &Speech{Audio: f.Audio, Format: AudioFormatMP3}, nil
+}
+
+// ValidateAudio accepts every supported format
+func (f *FakeClient) ValidateAudio(opts AudioOptions) error {
+	return opts.Validate()
+}
+
+// Transcribe returns the next response as the transcript, tagged with languageHint
+func (f *FakeClient) Transcribe(ctx context.Context, audio OmniMedia, languageHint string) (*Transcription, error) {
+	text, err := f.next([]Message{{Role: "user", Content: "[audio " + audio.Mime + "]"}})
+	if err != nil {
+		return nil, err
+	}
+	return &Transcription{Text: text, Language: languageHint}, nil
+}
+
+// Translate returns the next response as the translation of req.Text
+func (f *FakeClient) Translate(ctx context.Context, req TranslateRequest) (*Translation, error) {
+	text, err := f.next([]Message{{Role: "user", Content: req.Text}})
+	if err != nil {
+		return nil, err
+	}
+	return &Translation{Text: text, SourceLang: LanguageName(req.SourceLang), TargetLang: LanguageName(req.TargetLang)}, nil
+}
+
+// FakeEmbeddingDimensions is the size of the vectors returned by FakeClient.Embed
+const FakeEmbeddingDimensions = 64
+
+// Embed returns a deterministic bag-of-words vector per text: texts sharing words are similar
+func (f *FakeClient) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	f.mu.Lock()
+	err := f.Err
+	f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector := make([]float32, FakeEmbeddingDimensions)
+		for _, word := range strings.Fields(strings.ToLower(text)) {
+			h := fnv.New32a()
+			h.Write([]byte(strings.Trim(word, ".,!?;:\"'()")))
+			vector[h.Sum32()%FakeEmbeddingDimensions]++
+		}
+
+		var norm float64
+		for _, x := range vector {
+			norm += float64(x * x)
+		}
+		if norm > 0 {
+			for j := range vector {
+				vector[j] /= float32(math.Sqrt(norm))
+			}
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+func (f *FakeClient) ChatOmniStream(ctx context.Context, systemPrompt string, userText string, images []OmniMedia, inputAudio *OmniMedia, video *OmniVideo, wantAudio bool) <-chan StreamEvent {
+	var audio []byte
+	if wantAudio {
+		audio = f.Audio
+	}
+	return f.stream(ctx, NewConversation(systemPrompt, nil, userText), "", audio)
+}
+
+// splitChunks splits text into word-sized chunks, keeping whitespace so the chunks concatenate back to text
+func splitChunks(text string) []string {
+	var chunks []string
+	for text != "" {
+		i := strings.IndexByte(text, ' ')
+		if i < 0 {
+			chunks = append(chunks, text)
+			break
+		}
+		chunks = append(chunks, text[:i+1])
+		text = text[i+1:]
+	}
+	return chunks
+}
@@ -0,0 +1,281 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// ErrUnsupportedImage is matched by every *UnsupportedImageError
+var ErrUnsupportedImage = errors.New("unsupported image")
+
+// UnsupportedImageError reports image bytes that are not JPEG, PNG, GIF or WebP, or cannot be decoded
+type UnsupportedImageError struct {
+	// Mime is the sniffed content type
+	Mime   string
+	Reason string
+}
+
+func (e *UnsupportedImageError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("unsupported image (%s): %s", e.Mime, e.Reason)
+	}
+	return fmt.Sprintf("unsupported image (%s)", e.Mime)
+}
+
+// Is makes errors.Is match ErrUnsupportedImage and ErrBadRequest
+func (e *UnsupportedImageError) Is(target error) bool {
+	return target == ErrUnsupportedImage || target == ErrBadRequest
+}
+
+// ImageOptions bounds images before they are embedded in omni requests
+type ImageOptions struct {
+	// MaxDimension caps the longer side in pixels; larger images are scaled down
+	MaxDimension int
+	// JPEGQuality is used when re-encoding opaque images (1-100)
+	JPEGQuality int
+	// MaxInputBytes rejects larger uploads before decoding
+	MaxInputBytes int
+}
+
+// DefaultImageOptions keeps enough detail for vision models at a fraction of a phone photo's size
+var DefaultImageOptions = ImageOptions{
+	MaxDimension:  2048,
+	JPEGQuality:   85,
+	MaxInputBytes: 20 << 20,
+}
+
+// maxDecodePixels guards against decompression bombs with tiny files and huge dimensions
+const maxDecodePixels = 64 << 20
+
+// PrepareImage sniffs the real type of data, decodes JPEG, PNG, GIF (first frame) or WebP,
+// applies the EXIF orientation, scales it to opts.MaxDimension and re-encodes it: opaque images
+// as JPEG, transparent ones as PNG. Re-encoding drops all metadata, including EXIF GPS location.
+func PrepareImage(data []byte, opts ImageOptions) (OmniMedia, error) {
+	if opts.MaxInputBytes > 0 && len(data) > opts.MaxInputBytes {
+		return OmniMedia{}, fmt.Errorf("%w: image of %d bytes exceeds %d", ErrBadRequest, len(data), opts.MaxInputBytes)
+	}
+
+	mime := http.DetectContentType(data)
+	decode, decodeConfig := imageDecoder(mime)
+	if decode == nil {
+		return OmniMedia{}, &UnsupportedImageError{Mime: mime}
+	}
+
+	cfg, err := decodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return OmniMedia{}, &UnsupportedImageError{Mime: mime, Reason: err.Error()}
+	}
+	if cfg.Width*cfg.Height > maxDecodePixels {
+		return OmniMedia{}, fmt.Errorf("%w: image of %dx%d pixels is too large", ErrBadRequest, cfg.Width, cfg.Height)
+	}
+
+	img, err := decode(bytes.NewReader(data))
+	if err != nil {
+		return OmniMedia{}, &UnsupportedImageError{Mime: mime, Reason: err.Error()}
+	}
+
+	opaque := isOpaque(img)
+	img = scaleToFit(img, opts.MaxDimension)
+	if mime == "image/jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	var out bytes.Buffer
+	if opaque {
+		quality := opts.JPEGQuality
+		if quality <= 0 || quality > 100 {
+			quality = DefaultImageOptions.JPEGQuality
+		}
+		err = jpeg.Encode(&out, img, &jpeg.Options{Quality: quality})
+		mime = "image/jpeg"
+	} else {
+		err = png.Encode(&out, img)
+		mime = "image/png"
+	}
+	if err != nil {
+		return OmniMedia{}, fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	return OmniMedia{Mime: mime, DataBase64: base64.StdEncoding.EncodeToString(out.Bytes())}, nil
+}
+
+// prepareImages runs PrepareImage on base64 images with the client's options
+func (c *Client) prepareImages(images []OmniMedia) ([]OmniMedia, error) {
+	prepared := make([]OmniMedia, 0, len(images))
+	for _, img := range images {
+		data, err := base64.StdEncoding.DecodeString(img.DataBase64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid base64 image: %v", ErrBadRequest, err)
+		}
+		media, err := PrepareImage(data, c.images)
+		if err != nil {
+			return nil, err
+		}
+		prepared = append(prepared, media)
+	}
+	return prepared, nil
+}
+
+type (
+	decodeFunc       func(r *bytes.Reader) (image.Image, error)
+	decodeConfigFunc func(r *bytes.Reader) (image.Config, error)
+)
+
+// imageDecoder returns the decoder for a sniffed MIME type, or nils if unsupported
+func imageDecoder(mime string) (decodeFunc, decodeConfigFunc) {
+	switch mime {
+	case "image/jpeg":
+		return func(r *bytes.Reader) (image.Image, error) { return jpeg.Decode(r) },
+			func(r *bytes.Reader) (image.Config, error) { return jpeg.DecodeConfig(r) }
+	case "image/png":
+		return func(r *bytes.Reader) (image.Image, error) { return png.Decode(r) },
+			func(r *bytes.Reader) (image.Config, error) { return png.DecodeConfig(r) }
+	case "image/gif":
+		return func(r *bytes.Reader) (image.Image, error) { return gif.Decode(r) },
+			func(r *bytes.Reader) (image.Config, error) { return gif.DecodeConfig(r) }
+	case "image/webp":
+		return func(r *bytes.Reader) (image.Image, error) { return webp.Decode(r) },
+			func(r *bytes.Reader) (image.Config, error) { return webp.DecodeConfig(r) }
+	}
+	return nil, nil
+}
+
+// isOpaque reports whether img has no transparent pixels
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// scaleToFit scales img down so its longer side is at most maxDimension
+func scaleToFit(img image.Image, maxDimension int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if maxDimension <= 0 || (w <= maxDimension && h <= maxDimension) {
+		return img
+	}
+
+	if w >= h {
+		h = max(1, h*maxDimension/w)
+		w = maxDimension
+	} else {
+		w = max(1, w*maxDimension/h)
+		h = maxDimension
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// jpegOrientation returns the EXIF orientation (1-8) of a JPEG, or 1 if absent
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		// Metadata segments all come before the start of scan
+		if marker == 0xDA || length < 2 || i+2+length > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// exifOrientation reads the orientation tag from IFD0 of a TIFF-structured EXIF block
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for n := 0; n < entries; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if v := int(order.Uint16(tiff[entry+8:])); v >= 1 && v <= 8 {
+				return v
+			}
+			break
+		}
+	}
+	return 1
+}
+
+// applyOrientation rotates/flips img so it displays upright for an EXIF orientation
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored
+				sx, sy = w-1-x, y
+			case 3: // rotated 180
+				sx, sy = w-1-x, h-1-y
+			case 4: // flipped vertically
+				sx, sy = x, h-1-y
+			case 5: // transposed
+				sx, sy = y, x
+			case 6: // rotated 90 clockwise
+				sx, sy = y, h-1-x
+			case 7: // transversed
+				sx, sy = w-1-y, h-1-x
+			case 8: // rotated 90 counter-clockwise
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,120 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	return data
+}
+
+// decodePrepared decodes the output of PrepareImage
+func decodePrepared(t *testing.T, media OmniMedia) ([]byte, image.Image) {
+	t.Helper()
+	data, err := base64.StdEncoding.DecodeString(media.DataBase64)
+	if err != nil {
+		t.Fatalf("invalid base64 output: %v", err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("output is not a decodable image: %v", err)
+	}
+	return data, img
+}
+
+func TestPrepareImageFormats(t *testing.T) {
+	tests := []struct {
+		fixture  string
+		wantMime string
+		wantSize image.Point
+	}{
+		// EXIF orientation 6 turns the 64x32 photo upright
+		{"photo-exif-gps.jpg", "image/jpeg", image.Pt(32, 64)},
+		{"large.png", "image/jpeg", image.Pt(2048, 1024)},
+		{"transparent.png", "image/png", image.Pt(16, 16)},
+		{"animated.gif", "image/jpeg", image.Pt(10, 10)},
+		{"sample.webp", "image/jpeg", image.Pt(150, 100)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			media, err := PrepareImage(readFixture(t, tt.fixture), DefaultImageOptions)
+			if err != nil {
+				t.Fatalf("PrepareImage() error = %v", err)
+			}
+			if media.Mime != tt.wantMime {
+				t.Errorf("Expected %s, got %s", tt.wantMime, media.Mime)
+			}
+			_, img := decodePrepared(t, media)
+			if got := img.Bounds().Size(); got != tt.wantSize {
+				t.Errorf("Expected size %v, got %v", tt.wantSize, got)
+			}
+		})
+	}
+}
+
+func TestPrepareImageStripsExif(t *testing.T) {
+	input := readFixture(t, "photo-exif-gps.jpg")
+	if !bytes.Contains(input, []byte("Exif")) {
+		t.Fatal("fixture should carry EXIF metadata")
+	}
+
+	media, err := PrepareImage(input, DefaultImageOptions)
+	if err != nil {
+		t.Fatalf("PrepareImage() error = %v", err)
+	}
+	data, img := decodePrepared(t, media)
+	if bytes.Contains(data, []byte("Exif")) {
+		t.Error("EXIF metadata (including GPS location) must be removed")
+	}
+
+	// The red left half of the landscape original ends up on top after rotating
+	r, _, b, _ := img.At(16, 4).RGBA()
+	if r < b {
+		t.Errorf("Expected red at the top after rotation, got r=%d b=%d", r>>8, b>>8)
+	}
+}
+
+func TestPrepareImageRejects(t *testing.T) {
+	_, err := PrepareImage([]byte("%PDF-1.4 not an image"), DefaultImageOptions)
+	var unsupported *UnsupportedImageError
+	if !errors.As(err, &unsupported) || !errors.Is(err, ErrUnsupportedImage) || !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Expected *UnsupportedImageError matching ErrBadRequest, got %v", err)
+	}
+
+	// Right magic bytes, broken body
+	truncated := readFixture(t, "large.png")[:64]
+	if _, err := PrepareImage(truncated, DefaultImageOptions); !errors.Is(err, ErrUnsupportedImage) {
+		t.Errorf("Expected ErrUnsupportedImage for a truncated PNG, got %v", err)
+	}
+
+	opts := DefaultImageOptions
+	opts.MaxInputBytes = 100
+	if _, err := PrepareImage(readFixture(t, "large.png"), opts); !errors.Is(err, ErrBadRequest) || errors.Is(err, ErrUnsupportedImage) {
+		t.Errorf("Expected a size ErrBadRequest, got %v", err)
+	}
+}
+
+func TestChatOmniRejectsUnsupportedImage(t *testing.T) {
+	client := NewClient("test-key", "http://127.0.0.1:0", "qwen-omni-turbo")
+	bogus := OmniMedia{Mime: "image/png", DataBase64: ToBase64([]byte("not really a png"))}
+
+	_, err := client.ChatOmni(context.Background(), "", "what is this?", []OmniMedia{bogus}, nil, nil, false)
+	if !errors.Is(err, ErrUnsupportedImage) {
+		t.Errorf("Expected ErrUnsupportedImage before any request, got %v", err)
+	}
+}
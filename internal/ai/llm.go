@@ -0,0 +1,70 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// LLM is the set of model operations the rest of the application depends on.
+// *Client implements it for DashScope and OpenAI-compatible servers, FakeClient for tests.
+type LLM interface {
+	Chat(ctx context.Context, messages []Message) (string, error)
+	ChatStream(ctx context.Context, messages []Message) <-chan StreamEvent
+	ChatStreamWithThinking(ctx context.Context, messages []Message) <-chan StreamEvent
+	ChatWithThinking(ctx context.Context, messages []Message) (*ThinkingResponse, error)
+	ChatOmni(ctx context.Context, systemPrompt string, userText string, images []OmniMedia, inputAudio *OmniMedia, video *OmniVideo, wantAudio bool) (OmniResponse, error)
+	ChatOmniStream(ctx context.Context, systemPrompt string, userText string, images []OmniMedia, inputAudio *OmniMedia, video *OmniVideo, wantAudio bool) <-chan StreamEvent
+	TextToSpeech(ctx context.Context, text, voice string) (*Speech, error)
+	// ValidateAudio reports audio options the backend cannot produce, such as mp3 from DashScope omni models
+	ValidateAudio(opts AudioOptions) error
+	Transcribe(ctx context.Context, audio OmniMedia, languageHint string) (*Transcription, error)
+	Translate(ctx context.Context, req TranslateRequest) (*Translation, error)
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Supported provider names for NewLLM
+const (
+	ProviderDashScope = "dashscope"
+	ProviderOpenAI    = "openai"
+	ProviderFake      = "fake"
+)
+
+// ErrNotSupported is returned when a backend cannot perform the requested operation
+var ErrNotSupported = errors.New("operation not supported by this provider")
+
+var (
+	_ LLM = (*Client)(nil)
+	_ LLM = (*FakeClient)(nil)
+)
+
+// NewLLM creates the backend selected by provider.
+// An empty provider defaults to DashScope.
+func NewLLM(provider, apiKey, baseURL, model string) (LLM, error) {
+	switch strings.ToLower(strings.TrimSpace(provider)) {
+	case "", ProviderDashScope:
+		return NewClient(apiKey, baseURL, model), nil
+	case ProviderOpenAI:
+		return NewOpenAICompatibleClient(apiKey, baseURL, model), nil
+	case ProviderFake:
+		return NewFakeClient(), nil
+	default:
+		return nil, fmt.Errorf("unknown AI provider %q", provider)
+	}
+}
+
+// NewOpenAICompatibleClient creates a client for any OpenAI-compatible server
+// (Ollama, vLLM, llama.cpp, LM Studio, ...). DashScope-specific features such as
+// thinking mode and omni requests are disabled; TextToSpeech and Transcribe use
+// /audio/speech and /audio/transcriptions, Embed uses /embeddings.
+func NewOpenAICompatibleClient(apiKey, baseURL, model string) *Client {
+	c := NewClient(apiKey, baseURL, model)
+	c.qwenThinking = nil
+	c.compatible = true
+	c.speechModel = "tts-1"
+	c.transcriptionModel = "whisper-1"
+	c.embeddingModel = "text-embedding-3-small"
+	c.audio = AudioOptions{Voice: "alloy", Format: AudioFormatMP3}
+	return c
+}
@@ -0,0 +1,145 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNewLLM(t *testing.T) {
+	tests := []struct {
+		name         string
+		provider     string
+		model        string
+		wantThinking bool
+		wantFake     bool
+		wantErr      bool
+	}{
+		{"Default provider", "", "qwen-plus", true, false, false},
+		{"DashScope", "dashscope", "qwen-plus", true, false, false},
+		{"OpenAI-compatible", "openai", "qwen2.5:7b", false, false, false},
+		{"Fake", "fake", "", false, true, false},
+		{"Unknown", "bogus", "qwen-plus", false, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm, err := NewLLM(tt.provider, "test-key", "https://test.com", tt.model)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected error for unknown provider")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewLLM() error = %v", err)
+			}
+
+			if tt.wantFake {
+				if _, ok := llm.(*FakeClient); !ok {
+					t.Errorf("Expected *FakeClient, got %T", llm)
+				}
+				return
+			}
+
+			client, ok := llm.(*Client)
+			if !ok {
+				t.Fatalf("Expected *Client, got %T", llm)
+			}
+			if client.IsQwenModel() != tt.wantThinking {
+				t.Errorf("IsQwenModel() = %t, want %t", client.IsQwenModel(), tt.wantThinking)
+			}
+		})
+	}
+}
+
+func TestOpenAICompatibleClientRejectsOmni(t *testing.T) {
+	client := NewOpenAICompatibleClient("", "http://localhost:11434/v1", "llava")
+
+	_, err := client.ChatOmni(context.Background(), "", "hello", nil, nil, nil, false)
+	if !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestFakeClientStreamWithThinking(t *testing.T) {
+	fake := NewFakeClient("Hello there friend")
+	fake.Reasoning = "Greeting the user"
+
+	var reasoning, answer string
+	var done bool
+	for event := range fake.ChatStreamWithThinking(context.Background(), NewConversation("", nil, "hi")) {
+		switch event.Type {
+		case EventReasoning:
+			reasoning += event.Delta
+		case EventAnswer:
+			answer += event.Delta
+		case EventDone:
+			done = true
+		}
+	}
+
+	if reasoning != "Greeting the user" {
+		t.Errorf("Expected reasoning 'Greeting the user', got '%s'", reasoning)
+	}
+	if answer != "Hello there friend" {
+		t.Errorf("Expected streamed answer 'Hello there friend', got '%s'", answer)
+	}
+	if !done {
+		t.Error("Expected stream to end with EventDone")
+	}
+
+	calls := fake.Calls()
+	if len(calls) != 1 || calls[0][0].Content != "hi" {
+		t.Errorf("Expected one recorded call with 'hi', got %v", calls)
+	}
+}
+
+func TestFakeClientResponsesInOrder(t *testing.T) {
+	fake := NewFakeClient("first", "second")
+	ctx := context.Background()
+
+	for _, want := range []string{"first", "second", "second"} {
+		got, err := fake.Chat(ctx, []Message{{Role: "user", Content: "q"}})
+		if err != nil {
+			t.Fatalf("Chat() error = %v", err)
+		}
+		if got != want {
+			t.Errorf("Chat() = '%s', want '%s'", got, want)
+		}
+	}
+
+	fake.Err = errors.New("boom")
+	if _, err := fake.Chat(ctx, nil); err == nil {
+		t.Error("Expected error when Err is set")
+	}
+}
+
+func TestNewConversation(t *testing.T) {
+	history := []Message{
+		{Role: "user", Content: "Hi, I'm Budi"},
+		{Role: "assistant", Content: "Hello Budi!"},
+	}
+
+	messages := NewConversation("Be nice", history, "What's my name?")
+
+	want := []Message{
+		{Role: "system", Content: "Be nice"},
+		{Role: "user", Content: "Hi, I'm Budi"},
+		{Role: "assistant", Content: "Hello Budi!"},
+		{Role: "user", Content: "What's my name?"},
+	}
+	if len(messages) != len(want) {
+		t.Fatalf("Expected %d messages, got %d", len(want), len(messages))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(messages[i], want[i]) {
+			t.Errorf("Message %d = %+v, want %+v", i, messages[i], want[i])
+		}
+	}
+
+	if got := NewConversation("", nil, "hi"); len(got) != 1 || got[0].Role != "user" {
+		t.Errorf("Expected a single user message without system prompt, got %+v", got)
+	}
+}
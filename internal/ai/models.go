@@ -0,0 +1,206 @@
+package ai
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Capabilities declares what a model can do
+type Capabilities struct {
+	// Thinking models accept enable_thinking and stream reasoning_content
+	Thinking bool
+	// Vision models accept images and frame sequences
+	Vision bool
+	// InlineVideo models accept uploaded video as a base64 data URL
+	InlineVideo bool
+	AudioInput  bool
+	AudioOutput bool
+	// Translation models take translation_options and a single user message
+	Translation bool
+	Tools       bool
+	// Embedding models serve /embeddings and nothing else
+	Embedding bool
+	// ContextWindow is the total token budget of a request (prompt + answer)
+	ContextWindow int
+	// MaxOutput caps max_tokens (0 = unknown)
+	MaxOutput int
+	// StreamOnly models reject stream=false
+	StreamOnly bool
+}
+
+// Capability names used in *CapabilityError
+const (
+	CapabilityChat        = "chat"
+	CapabilityThinking    = "thinking"
+	CapabilityVision      = "image/video input"
+	CapabilityAudioInput  = "audio input"
+	CapabilityAudioOutput = "audio output"
+	CapabilityTranslation = "translation"
+	CapabilityTools       = "tool calling"
+	CapabilityNonStream   = "non-streaming requests"
+	CapabilityEmbedding   = "embeddings"
+)
+
+// CapabilityError reports an operation the model does not support
+type CapabilityError struct {
+	Model      string
+	Capability string
+}
+
+func (e *CapabilityError) Error() string {
+	return fmt.Sprintf("model %s does not support %s", e.Model, e.Capability)
+}
+
+// Is makes errors.Is(err, ErrNotSupported) true
+func (e *CapabilityError) Is(target error) bool {
+	return target == ErrNotSupported
+}
+
+// DefaultCapabilities applies to models missing from the catalog: plain text chat with tools
+var DefaultCapabilities = Capabilities{
+	Tools:         true,
+	ContextWindow: 32768,
+}
+
+// catalog holds the known DashScope models. Keys match a model name exactly or as the prefix
+// of a dated snapshot ("qwen-plus" covers "qwen-plus-2025-04-28"); the longest key wins.
+var catalog = struct {
+	sync.RWMutex
+	models map[string]Capabilities
+}{models: map[string]Capabilities{
+	"qwen-max":   {Tools: true, ContextWindow: 32768, MaxOutput: 8192},
+	"qwen-plus":  {Thinking: true, Tools: true, ContextWindow: 131072, MaxOutput: 16384},
+	"qwen-turbo": {Thinking: true, Tools: true, ContextWindow: 1000000, MaxOutput: 16384},
+	"qwen-flash": {Thinking: true, Tools: true, ContextWindow: 1000000, MaxOutput: 32768},
+	"qwen-long":  {ContextWindow: 10000000, MaxOutput: 8192},
+
+	"qwen3-":      {Thinking: true, Tools: true, ContextWindow: 131072, MaxOutput: 16384},
+	"qwen3-max":   {Tools: true, ContextWindow: 262144, MaxOutput: 65536},
+	"qwen3-coder": {Tools: true, ContextWindow: 1000000, MaxOutput: 65536},
+	"qwen3-vl":    {Thinking: true, Vision: true, Tools: true, ContextWindow: 262144, MaxOutput: 32768},
+	"qwen3-omni":  {Vision: true, InlineVideo: true, AudioInput: true, AudioOutput: true, ContextWindow: 65536, MaxOutput: 16384, StreamOnly: true},
+
+	"qwq":         {Thinking: true, Tools: true, ContextWindow: 131072, MaxOutput: 8192, StreamOnly: true},
+	"deepseek-r1": {Thinking: true, ContextWindow: 65536, MaxOutput: 8192},
+	"deepseek-v3": {Tools: true, ContextWindow: 65536, MaxOutput: 8192},
+
+	"qwen-vl":         {Vision: true, ContextWindow: 131072, MaxOutput: 8192},
+	"qwen2.5-vl":      {Vision: true, ContextWindow: 131072, MaxOutput: 8192},
+	"qvq":             {Thinking: true, Vision: true, ContextWindow: 131072, MaxOutput: 8192, StreamOnly: true},
+	"qwen-omni-turbo": {Vision: true, InlineVideo: true, AudioInput: true, AudioOutput: true, ContextWindow: 32768, MaxOutput: 2048, StreamOnly: true},
+	"qwen2.5-omni":    {Vision: true, InlineVideo: true, AudioInput: true, AudioOutput: true, ContextWindow: 32768, MaxOutput: 2048, StreamOnly: true},
+
+	"qwen-mt": {Translation: true, ContextWindow: 16384, MaxOutput: 8192},
+
+	"text-embedding-v1": {Embedding: true, ContextWindow: 2048},
+	"text-embedding-v2": {Embedding: true, ContextWindow: 2048},
+	"text-embedding-v3": {Embedding: true, ContextWindow: 8192},
+	"text-embedding-v4": {Embedding: true, ContextWindow: 8192},
+}}
+
+// LookupModel returns the capabilities of model and whether it is in the catalog.
+// Unknown models get DefaultCapabilities.
+func LookupModel(model string) (Capabilities, bool) {
+	name := strings.ToLower(strings.TrimSpace(model))
+
+	catalog.RLock()
+	defer catalog.RUnlock()
+
+	if caps, ok := catalog.models[name]; ok {
+		return caps, true
+	}
+
+	best := ""
+	for key := range catalog.models {
+		if strings.HasPrefix(name, key) && len(key) > len(best) {
+			best = key
+		}
+	}
+	if best != "" {
+		return catalog.models[best], true
+	}
+	return DefaultCapabilities, false
+}
+
+// RegisterModel adds or replaces a catalog entry; name may be a prefix as described on LookupModel
+func RegisterModel(name string, caps Capabilities) {
+	catalog.Lock()
+	defer catalog.Unlock()
+	catalog.models[strings.ToLower(strings.TrimSpace(name))] = caps
+}
+
+// Models lists the catalog keys in order
+func Models() []string {
+	catalog.RLock()
+	defer catalog.RUnlock()
+
+	names := make([]string, 0, len(catalog.models))
+	for name := range catalog.models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Capabilities returns the catalog entry of the client's chat model
+func (c *Client) Capabilities() Capabilities {
+	caps, _ := LookupModel(c.Model)
+	return caps
+}
+
+// require returns a *CapabilityError unless model has every capability listed.
+// OpenAI-compatible servers host arbitrary models, so they are not checked.
+func (c *Client) require(model string, capabilities ...string) error {
+	if c.compatible {
+		return nil
+	}
+
+	caps, _ := LookupModel(model)
+	for _, capability := range capabilities {
+		var ok bool
+		switch capability {
+		case CapabilityChat:
+			ok = !caps.Embedding
+		case CapabilityThinking:
+			ok = caps.Thinking
+		case CapabilityVision:
+			ok = caps.Vision
+		case CapabilityAudioInput:
+			ok = caps.AudioInput
+		case CapabilityAudioOutput:
+			ok = caps.AudioOutput
+		case CapabilityTranslation:
+			ok = caps.Translation
+		case CapabilityTools:
+			ok = caps.Tools
+		case CapabilityNonStream:
+			ok = !caps.StreamOnly
+		case CapabilityEmbedding:
+			ok = caps.Embedding
+		}
+		if !ok {
+			return &CapabilityError{Model: model, Capability: capability}
+		}
+	}
+	return nil
+}
+
+// checkChat refuses chat requests the model cannot serve: any chat on embedding models,
+// tools for models without tool calling and max_tokens above the model's output limit
+func (c *Client) checkChat(model string, params ModelParams) error {
+	if err := c.require(model, CapabilityChat); err != nil {
+		return err
+	}
+	if c.tools.Len() > 0 {
+		if err := c.require(model, CapabilityTools); err != nil {
+			return err
+		}
+	}
+
+	if caps, _ := LookupModel(model); !c.compatible && caps.MaxOutput > 0 && params.MaxTokens > caps.MaxOutput {
+		return fmt.Errorf("%w: max_tokens %d exceeds the %d output tokens of %s", ErrBadRequest, params.MaxTokens, caps.MaxOutput, model)
+	}
+	return nil
+}
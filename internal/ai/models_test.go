@@ -0,0 +1,141 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestLookupModel(t *testing.T) {
+	tests := []struct {
+		model    string
+		known    bool
+		thinking bool
+		vision   bool
+		window   int
+	}{
+		{"qwen-plus", true, true, false, 131072},
+		{"qwen-plus-2025-04-28", true, true, false, 131072},
+		{"QWEN-MAX", true, false, false, 32768},
+		{"qwen3-vl-plus", true, true, true, 262144},
+		{"qwen3-235b-a22b", true, true, false, 131072},
+		{"qwen-vl-max-latest", true, false, true, 131072},
+		{"gpt-4o", false, false, false, DefaultCapabilities.ContextWindow},
+	}
+	for _, tt := range tests {
+		caps, known := LookupModel(tt.model)
+		if known != tt.known || caps.Thinking != tt.thinking || caps.Vision != tt.vision || caps.ContextWindow != tt.window {
+			t.Errorf("LookupModel(%q) = %+v, %t", tt.model, caps, known)
+		}
+	}
+
+	if caps, _ := LookupModel("qwen-omni-turbo"); !caps.StreamOnly || !caps.AudioOutput || !caps.InlineVideo {
+		t.Errorf("qwen-omni-turbo should be a stream-only audio model, got %+v", caps)
+	}
+	if caps, _ := LookupModel("qwen-mt-plus"); !caps.Translation || caps.Tools {
+		t.Errorf("qwen-mt-plus should only translate, got %+v", caps)
+	}
+}
+
+func TestRegisterModel(t *testing.T) {
+	RegisterModel("acme-reasoner", Capabilities{Thinking: true, ContextWindow: 8192})
+	defer func() {
+		catalog.Lock()
+		delete(catalog.models, "acme-reasoner")
+		catalog.Unlock()
+	}()
+
+	client := NewClient("test-key", "https://test.com", "acme-reasoner-v2")
+	if !client.IsQwenModel() {
+		t.Error("Registered thinking model should get thinking mode")
+	}
+	if client.Capabilities().ContextWindow != 8192 {
+		t.Errorf("Unexpected capabilities %+v", client.Capabilities())
+	}
+}
+
+func TestCapabilityErrors(t *testing.T) {
+	ctx := context.Background()
+	vision := NewClient("test-key", "http://127.0.0.1:0", "qwen-vl-max")
+
+	_, err := vision.ChatOmni(ctx, "", "hello", nil, &OmniMedia{DataBase64: "AAAA", Mime: "audio/wav"}, nil, false)
+	var capErr *CapabilityError
+	if !errors.As(err, &capErr) || capErr.Capability != CapabilityAudioInput || capErr.Model != "qwen-vl-max" {
+		t.Errorf("Expected audio input CapabilityError, got %v", err)
+	}
+	if !errors.Is(err, ErrNotSupported) {
+		t.Errorf("CapabilityError should match ErrNotSupported, got %v", err)
+	}
+
+	if _, err := vision.ChatOmni(ctx, "", "hello", nil, nil, nil, true); !errors.As(err, &capErr) || capErr.Capability != CapabilityAudioOutput {
+		t.Errorf("Expected audio output CapabilityError, got %v", err)
+	}
+
+	vision.SetTranslationModel("qwen-plus")
+	if _, err := vision.Translate(ctx, TranslateRequest{Text: "halo", TargetLang: "en"}); !errors.As(err, &capErr) || capErr.Capability != CapabilityTranslation {
+		t.Errorf("Expected translation CapabilityError, got %v", err)
+	}
+
+	vision.SetSpeechModel("qwen-plus")
+	if _, err := vision.TextToSpeech(ctx, "halo", ""); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Expected ErrNotSupported for speech, got %v", err)
+	}
+
+	vision.SetTranscriptionModel("qwen-mt-turbo")
+	if _, err := vision.Transcribe(ctx, OmniMedia{DataBase64: "AAAA", Mime: "audio/ogg"}, ""); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Expected ErrNotSupported for transcription, got %v", err)
+	}
+
+	var calls int32
+	vision.SetTools(weatherRegistry(t, &calls))
+	if _, err := vision.Chat(ctx, NewConversation("", nil, "weather?")); !errors.As(err, &capErr) || capErr.Capability != CapabilityTools {
+		t.Errorf("Expected tool calling CapabilityError, got %v", err)
+	}
+}
+
+func TestCheckChatMaxTokens(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	server := captureServer(t, bodies)
+
+	maxTokens := 4096
+	ctx := WithRequestOptions(context.Background(), RequestOptions{MaxTokens: &maxTokens})
+
+	client := NewClient("test-key", server.URL, "qwen-omni-turbo")
+	if _, err := client.Chat(ctx, NewConversation("", nil, "hi")); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Expected ErrBadRequest above the output limit, got %v", err)
+	}
+
+	// OpenAI-compatible servers host arbitrary models and are not checked
+	compatible := NewOpenAICompatibleClient("", server.URL, "qwen-omni-turbo")
+	if _, err := compatible.Chat(ctx, NewConversation("", nil, "hi")); err != nil {
+		t.Errorf("Compatible client should not be checked, got %v", err)
+	}
+}
+
+func TestCheckChatRefusesEmbeddingModels(t *testing.T) {
+	client := NewClient("test-key", "http://127.0.0.1:0", DefaultEmbeddingModel)
+
+	_, err := client.Chat(context.Background(), NewConversation("", nil, "hi"))
+	var capErr *CapabilityError
+	if !errors.As(err, &capErr) || capErr.Capability != CapabilityChat || capErr.Model != DefaultEmbeddingModel {
+		t.Errorf("Expected chat CapabilityError, got %v", err)
+	}
+	if err := client.checkChat("qwen-plus", defaultParams); err != nil {
+		t.Errorf("checkChat(qwen-plus) error = %v", err)
+	}
+}
+
+func TestAcceptsInlineVideoUsesCatalog(t *testing.T) {
+	for model, want := range map[string]bool{
+		"qwen-omni-turbo":  true,
+		"qwen3-omni-flash": true,
+		"qwen-vl-max":      false,
+		"my-omni-finetune": false,
+		"qwen2.5-omni-7b":  true,
+		"qwen3-vl-plus":    false,
+	} {
+		if got := acceptsInlineVideo(model); got != want {
+			t.Errorf("acceptsInlineVideo(%q) = %t, want %t", model, got, want)
+		}
+	}
+}
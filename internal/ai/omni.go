@@ -1,14 +1,10 @@
 package ai
 
 import (
-	"bytes"
 	"context"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
-	"time"
+	"strings"
 )
 
 type OmniMedia struct {
@@ -17,15 +13,116 @@ type OmniMedia struct {
 }
 
 type OmniResponse struct {
-	Text     string
-	AudioMP3 []byte
+	Text string
+	// Audio is encoded as AudioFormat; wav and pcm16 use SampleRate
+	Audio       []byte
+	AudioFormat string
+	SampleRate  int
 }
 
-// ChatOmni sends a multimodal request (text + optional image/audio/video) and can request audio output
-func (c *Client) ChatOmni(ctx context.Context, systemPrompt string, userText string, images []OmniMedia, inputAudio *OmniMedia, videoURL string, wantAudio bool) (OmniResponse, error) {
-	// Build OpenAI-compatible JSON payload
-	// We construct raw JSON to support multimodal parts regardless of SDK version
+// ChatOmni sends a multimodal request (text + optional image/audio/video) and can request audio output.
+// It collects ChatOmniStream into a single response encoded with the resolved AudioOptions.
+func (c *Client) ChatOmni(ctx context.Context, systemPrompt string, userText string, images []OmniMedia, inputAudio *OmniMedia, video *OmniVideo, wantAudio bool) (OmniResponse, error) {
+	out, err := CollectOmniStream(c.ChatOmniStream(ctx, systemPrompt, userText, images, inputAudio, video, wantAudio))
+	if err != nil || len(out.Audio) == 0 {
+		return out, err
+	}
+
+	opts := resolveAudio(ctx, c.audio)
+	out.Audio = encodeOmniAudio(out.Audio, opts)
+	out.AudioFormat = opts.Format
+	out.SampleRate = outputRate(opts)
+	return out, nil
+}
+
+// ChatOmniStream streams a multimodal response: text arrives as EventAnswer deltas
+// (the spoken transcript when audio is requested) and audio as base64 EventAudio chunks.
+// Omni models only answer with stream=true. For wav and pcm16 the chunks are the model's raw
+// 24kHz PCM; ChatOmni converts them to the requested format and sample rate.
+// Images are normalized with PrepareImage before upload; uploaded video is sent inline or
+// as sampled frames according to the client's VideoOptions.
+func (c *Client) ChatOmniStream(ctx context.Context, systemPrompt string, userText string, images []OmniMedia, inputAudio *OmniMedia, video *OmniVideo, wantAudio bool) <-chan StreamEvent {
+	if c.compatible {
+		return failedStream(fmt.Errorf("omni request: %w", ErrNotSupported))
+	}
+	ctx, model := c.route(ctx, TaskMedia, c.mediaFallback())
+	if err := c.requireOmni(model, images, inputAudio, video, wantAudio); err != nil {
+		return failedStream(err)
+	}
+	if wantAudio {
+		if err := resolveAudio(ctx, c.audio).validateOmni(); err != nil {
+			return failedStream(err)
+		}
+	}
+	images, err := c.prepareImages(images)
+	if err != nil {
+		return failedStream(err)
+	}
+	video, err = c.prepareVideo(ctx, model, video)
+	if err != nil {
+		return failedStream(err)
+	}
+
+	body := c.omniRequestBody(ctx, model, systemPrompt, userText, images, inputAudio, video, wantAudio)
+	return c.streamOmniBody(ctx, body)
+}
+
+// mediaFallback is the model of unrouted media requests: the chat model when it reads
+// images or audio, DefaultMediaModel otherwise
+func (c *Client) mediaFallback() string {
+	if caps, _ := LookupModel(c.Model); caps.Vision || caps.AudioInput {
+		return c.Model
+	}
+	return DefaultMediaModel
+}
+
+// requireOmni checks the media of an omni request against the model's capabilities
+func (c *Client) requireOmni(model string, images []OmniMedia, inputAudio *OmniMedia, video *OmniVideo, wantAudio bool) error {
+	var needed []string
+	if len(images) > 0 || video != nil {
+		needed = append(needed, CapabilityVision)
+	}
+	if inputAudio != nil {
+		needed = append(needed, CapabilityAudioInput)
+	}
+	if wantAudio {
+		needed = append(needed, CapabilityAudioOutput)
+	}
+	return c.require(model, needed...)
+}
+
+// failedStream returns a closed stream carrying only err
+func failedStream(err error) <-chan StreamEvent {
+	events := make(chan StreamEvent, 1)
+	events <- StreamEvent{Type: EventError, Err: err}
+	close(events)
+	return events
+}
+
+// streamOmniBody posts an omni request body and streams the response
+func (c *Client) streamOmniBody(ctx context.Context, body map[string]any) <-chan StreamEvent {
+	events := make(chan StreamEvent, streamBuffer)
+
+	go func() {
+		defer close(events)
+
+		// Transient failures are retried only until the first event reaches the consumer
+		// Audio, image and video input only make sense to the requested model
+		model, _ := body["model"].(string)
+		err := c.do(ctx, "omni request", model, true, func(ep Endpoint) error {
+			return c.streamOmni(ctx, ep, body, events)
+		})
+		if err != nil {
+			emit(ctx, events, StreamEvent{Type: EventError, Err: err})
+		}
+	}()
+
+	return events
+}
 
+// omniRequestBody builds the raw JSON payload.
+// We construct raw JSON to support multimodal parts regardless of SDK version.
+func (c *Client) omniRequestBody(ctx context.Context, model string, systemPrompt string, userText string, images []OmniMedia, inputAudio *OmniMedia, video *OmniVideo, wantAudio bool) map[string]any {
 	// Build user content parts
 	userContent := make([]map[string]any, 0, 1+len(images)+1)
 	if userText != "" {
@@ -54,10 +151,20 @@ func (c *Client) ChatOmni(ctx context.Context, systemPrompt string, userText str
 		})
 	}
 
-	if videoURL != "" {
+	if video != nil && video.URL != "" {
 		userContent = append(userContent, map[string]any{
 			"type":      "input_video",
-			"video_url": map[string]any{"url": videoURL},
+			"video_url": map[string]any{"url": video.URL},
+		})
+	} else if video != nil && len(video.Frames) > 0 {
+		// An ordered image list is read as a video sampled at those frames
+		frames := make([]string, len(video.Frames))
+		for i, frame := range video.Frames {
+			frames[i] = fmt.Sprintf("data:%s;base64,%s", frame.Mime, frame.DataBase64)
+		}
+		userContent = append(userContent, map[string]any{
+			"type":  "video",
+			"video": frames,
 		})
 	}
 
@@ -77,88 +184,121 @@ func (c *Client) ChatOmni(ctx context.Context, systemPrompt string, userText str
 	})
 
 	body := map[string]any{
-		"model":    c.Model,
-		"messages": messages,
-		"stream":   false,
+		"model":          model,
+		"messages":       messages,
+		"stream":         true,
+		"stream_options": QwenStreamOptions{IncludeUsage: true},
 	}
 
 	if wantAudio {
+		audio := resolveAudio(ctx, c.audio)
 		body["modalities"] = []string{"text", "audio"}
 		body["audio"] = map[string]any{
-			"voice":  "alloy",
-			"format": "mp3",
+			"voice":  audio.Voice,
+			"format": omniWireFormat,
 		}
 	}
 
 	// Sampling params (align with client params)
-	body["temperature"] = c.params.Temperature
-	body["top_p"] = c.params.TopP
-	body["top_k"] = c.params.TopK
-
-	payload, err := json.Marshal(body)
-	if err != nil {
-		return OmniResponse{}, fmt.Errorf("failed to marshal payload: %w", err)
+	params := resolveParams(ctx, c.params)
+	body["temperature"] = params.Temperature
+	body["top_p"] = params.TopP
+	body["top_k"] = params.TopK
+	if params.MaxTokens > 0 {
+		body["max_tokens"] = params.MaxTokens
 	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/chat/completions", bytes.NewReader(payload))
-	if err != nil {
-		return OmniResponse{}, fmt.Errorf("failed to create request: %w", err)
+	if params.Seed != nil {
+		body["seed"] = *params.Seed
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.APIKey)
 
-	httpClient := &http.Client{Timeout: 60 * time.Second}
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return OmniResponse{}, fmt.Errorf("request error: %w", err)
+	return body
+}
+
+// streamOmni performs a single streaming omni attempt.
+// Errors after the first delivered event are wrapped in partialError.
+func (c *Client) streamOmni(ctx context.Context, ep Endpoint, body map[string]any, events chan<- StreamEvent) error {
+	emitted := false
+	send := func(event StreamEvent) bool {
+		if !emit(ctx, events, event) {
+			return false
+		}
+		emitted = true
+		return true
 	}
-	defer resp.Body.Close()
 
-	respBytes, err := io.ReadAll(resp.Body)
+	model := ep.Model
+	var finishReason string
+	err := postStream(ctx, ep.BaseURL+"/chat/completions", ep.APIKey, body, func(chunk *QwenThinkingStreamResponse) error {
+		if chunk.Usage != nil {
+			usage := chunk.usage(model)
+			recordUsage(ctx, c.usage, usage, model)
+			if !send(StreamEvent{Type: EventUsage, Usage: usage}) {
+				return ctx.Err()
+			}
+		}
+
+		if len(chunk.Choices) == 0 {
+			return nil
+		}
+
+		choice := chunk.Choices[0]
+		if choice.FinishReason != "" {
+			finishReason = choice.FinishReason
+		}
+
+		text := choice.Delta.Content
+		if audio := choice.Delta.Audio; audio != nil {
+			text += audio.Transcript
+			if audio.Data != "" {
+				if !send(StreamEvent{Type: EventAudio, Audio: audio.Data}) {
+					return ctx.Err()
+				}
+			}
+		}
+		if text != "" {
+			if !send(StreamEvent{Type: EventAnswer, Delta: text}) {
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return OmniResponse{}, fmt.Errorf("failed to read response: %w", err)
-	}
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return OmniResponse{}, fmt.Errorf("bad status %d: %s", resp.StatusCode, string(respBytes))
+		if emitted {
+			return partialError{err}
+		}
+		return err
 	}
 
-	// Parse flexible response structure
-	var raw map[string]any
-	if err := json.Unmarshal(respBytes, &raw); err != nil {
-		return OmniResponse{}, fmt.Errorf("failed to parse response: %w", err)
-	}
+	send(StreamEvent{Type: EventDone, FinishReason: finishReason})
+	return nil
+}
 
+// CollectOmniStream drains an omni stream into an OmniResponse, decoding and concatenating the audio chunks.
+// It returns the error carried by an EventError, if any.
+func CollectOmniStream(events <-chan StreamEvent) (OmniResponse, error) {
 	var out OmniResponse
-	// Extract text from choices[0]
-	choices, _ := raw["choices"].([]any)
-	if len(choices) > 0 {
-		choice, _ := choices[0].(map[string]any)
-		msg, _ := choice["message"].(map[string]any)
-		// message.content may be string or array of parts
-		switch content := msg["content"].(type) {
-		case string:
-			out.Text = content
-		case []any:
-			for _, part := range content {
-				pm, _ := part.(map[string]any)
-				ptype, _ := pm["type"].(string)
-				if ptype == "output_text" || ptype == "text" {
-					if txt, ok := pm["text"].(string); ok {
-						out.Text += txt
-					}
-				}
-				if ptype == "output_audio" || ptype == "audio" {
-					if aud, ok := pm["audio"].(map[string]any); ok {
-						if data, ok := aud["data"].(string); ok {
-							b, _ := base64.StdEncoding.DecodeString(data)
-							out.AudioMP3 = b
-						}
-					}
-				}
+	var text strings.Builder
+	var err error
+
+	for event := range events {
+		switch event.Type {
+		case EventAnswer:
+			text.WriteString(event.Delta)
+		case EventAudio:
+			chunk, decodeErr := base64.StdEncoding.DecodeString(event.Audio)
+			if decodeErr != nil && err == nil {
+				err = fmt.Errorf("failed to decode audio chunk: %w", decodeErr)
 			}
+			out.Audio = append(out.Audio, chunk...)
+		case EventError:
+			err = event.Err
 		}
 	}
 
+	if err != nil {
+		return OmniResponse{}, err
+	}
+	out.Text = text.String()
 	return out, nil
 }
 
@@ -0,0 +1,122 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// omniServer streams a spoken answer in two audio chunks and captures the request body
+func omniServer(t *testing.T, bodies chan<- map[string]any) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		if err := json.Unmarshal(raw, &body); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		bodies <- body
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"audio\":{\"transcript\":\"Halo \",\"data\":%q}}}]}\n\n", ToBase64([]byte("abc")))
+		fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"audio\":{\"transcript\":\"semua\",\"data\":%q}},\"finish_reason\":\"stop\"}]}\n\n", ToBase64([]byte("def")))
+		fmt.Fprint(w, `data: {"choices":[],"usage":{"prompt_tokens":5,"completion_tokens":7,"total_tokens":12}}`+"\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestChatOmniStreamEvents(t *testing.T) {
+	bodies := make(chan map[string]any, 1)
+	server := omniServer(t, bodies)
+	client := NewClient("test-key", server.URL, "qwen-omni-turbo")
+
+	var types []StreamEventType
+	var transcript string
+	var audioChunks int
+	for event := range client.ChatOmniStream(context.Background(), "", "say hi", nil, nil, nil, true) {
+		types = append(types, event.Type)
+		switch event.Type {
+		case EventAnswer:
+			transcript += event.Delta
+		case EventAudio:
+			audioChunks++
+		case EventError:
+			t.Fatalf("Unexpected error: %v", event.Err)
+		}
+	}
+
+	want := []StreamEventType{EventAudio, EventAnswer, EventAudio, EventAnswer, EventUsage, EventDone}
+	if fmt.Sprint(types) != fmt.Sprint(want) {
+		t.Errorf("Event order = %v, want %v", types, want)
+	}
+	if transcript != "Halo semua" || audioChunks != 2 {
+		t.Errorf("Got transcript %q with %d audio chunks", transcript, audioChunks)
+	}
+
+	body := <-bodies
+	if body["stream"] != true {
+		t.Errorf("Omni request must stream, got stream=%v", body["stream"])
+	}
+	if _, ok := body["modalities"]; !ok {
+		t.Error("Audio output was requested but modalities is missing")
+	}
+}
+
+func TestChatOmniAssemblesResponse(t *testing.T) {
+	bodies := make(chan map[string]any, 1)
+	server := omniServer(t, bodies)
+	client := NewClient("test-key", server.URL, "qwen-omni-turbo")
+
+	// pcm16 at the model's own rate passes the streamed chunks through unchanged
+	ctx := WithRequestOptions(context.Background(), RequestOptions{Audio: &AudioOptions{Format: AudioFormatPCM16}})
+	out, err := client.ChatOmni(ctx, "", "say hi", nil, nil, nil, true)
+	if err != nil {
+		t.Fatalf("ChatOmni() error = %v", err)
+	}
+	if out.Text != "Halo semua" {
+		t.Errorf("Expected text 'Halo semua', got %q", out.Text)
+	}
+	if string(out.Audio) != "abcdef" || out.AudioFormat != AudioFormatPCM16 || out.SampleRate != 24000 {
+		t.Errorf("Expected pcm16 audio 'abcdef' at 24000Hz, got %q as %s at %d", out.Audio, out.AudioFormat, out.SampleRate)
+	}
+
+	body := <-bodies
+	audio, _ := body["audio"].(map[string]any)
+	if audio["format"] != "wav" || audio["voice"] != DefaultAudioOptions.Voice {
+		t.Errorf("Expected wav wire format with default voice, got %v", body["audio"])
+	}
+}
+
+func TestFakeClientOmniStream(t *testing.T) {
+	fake := NewFakeClient("Hi there")
+	fake.Audio = []byte("voice")
+
+	out, err := fake.ChatOmni(context.Background(), "", "hello", nil, nil, nil, true)
+	if err != nil {
+		t.Fatalf("ChatOmni() error = %v", err)
+	}
+	if out.Text != "Hi there" || string(out.Audio) != "voice" {
+		t.Errorf("Unexpected response %+v", out)
+	}
+}
+
+func TestChatOmniFallsBackToMediaModel(t *testing.T) {
+	bodies := make(chan map[string]any, 1)
+	server := omniServer(t, bodies)
+	client := NewClient("test-key", server.URL, "qwen-mt-turbo")
+
+	audio := &OmniMedia{Mime: "audio/wav", DataBase64: ToBase64([]byte("RIFF"))}
+	if _, err := client.ChatOmni(context.Background(), "", "", nil, audio, nil, false); err != nil {
+		t.Fatalf("ChatOmni() error = %v", err)
+	}
+	if body := <-bodies; body["model"] != DefaultMediaModel {
+		t.Errorf("Expected unrouted media on %s, got %v", DefaultMediaModel, body["model"])
+	}
+}
@@ -1,15 +1,9 @@
 package ai
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
-	"io"
-	"net/http"
 	"strings"
-	"time"
 )
 
 // QwenThinkingClient handles Qwen-specific thinking mode functionality
@@ -18,31 +12,70 @@ type QwenThinkingClient struct {
 	baseURL string
 	model   string
 	params  ModelParams
+	retry   RetryPolicy
+	tools   *ToolRegistry
+	usage   UsageRecorder
+	// failover is shared with the Client that owns this one
+	failover *Failover
 }
 
-// QwenThinkingRequest represents the request structure for Qwen thinking mode
+// QwenThinkingRequest represents the request structure for Qwen thinking mode.
+// DashScope extensions (enable_thinking, thinking_budget, top_k) are top-level fields.
 type QwenThinkingRequest struct {
-	Model     string                 `json:"model"`
-	Messages  []QwenMessage          `json:"messages"`
-	Stream    bool                   `json:"stream"`
-	ExtraBody map[string]interface{} `json:"-"`
+	Model          string             `json:"model"`
+	Messages       []QwenMessage      `json:"messages"`
+	Stream         bool               `json:"stream"`
+	StreamOptions  *QwenStreamOptions `json:"stream_options,omitempty"`
+	Temperature    *float64           `json:"temperature,omitempty"`
+	TopP           *float64           `json:"top_p,omitempty"`
+	TopK           *int               `json:"top_k,omitempty"`
+	EnableThinking *bool              `json:"enable_thinking,omitempty"`
+	ThinkingBudget *int               `json:"thinking_budget,omitempty"`
+	MaxTokens      *int               `json:"max_tokens,omitempty"`
+	Seed           *int               `json:"seed,omitempty"`
+	Stop           []string           `json:"stop,omitempty"`
+	Tools          []QwenTool         `json:"tools,omitempty"`
+}
+
+// QwenStreamOptions asks the API to append a usage chunk to the stream
+type QwenStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// QwenTool describes a function the model may call
+type QwenTool struct {
+	Type     string           `json:"type"`
+	Function QwenToolFunction `json:"function"`
+}
+
+// QwenToolFunction is the name, description and JSON schema of a tool
+type QwenToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters"`
 }
 
 // QwenMessage represents a message in the Qwen API format
 type QwenMessage struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// ToolCalls is set on assistant messages that requested tools
+	ToolCalls []QwenToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID links a tool result to the call it answers
+	ToolCallID string `json:"tool_call_id,omitempty"`
 }
 
 // QwenThinkingStreamResponse represents the streaming response from Qwen thinking mode
 type QwenThinkingStreamResponse struct {
+	Model   string             `json:"model,omitempty"`
 	Choices []QwenStreamChoice `json:"choices"`
 	Usage   *QwenUsage         `json:"usage,omitempty"`
 }
 
 // QwenStreamChoice represents a choice in the streaming response
 type QwenStreamChoice struct {
-	Delta QwenStreamDelta `json:"delta"`
+	Delta        QwenStreamDelta `json:"delta"`
+	FinishReason string          `json:"finish_reason,omitempty"`
 }
 
 // QwenStreamDelta represents the delta content in streaming responses
@@ -50,11 +83,21 @@ type QwenStreamDelta struct {
 	Content          string         `json:"content,omitempty"`
 	ReasoningContent string         `json:"reasoning_content,omitempty"`
 	ToolCalls        []QwenToolCall `json:"tool_calls,omitempty"`
+	// Audio is streamed by omni models when audio output is requested
+	Audio *QwenAudioDelta `json:"audio,omitempty"`
+}
+
+// QwenAudioDelta is a chunk of spoken output: base64 audio data and/or its transcript
+type QwenAudioDelta struct {
+	ID         string `json:"id,omitempty"`
+	Data       string `json:"data,omitempty"`
+	Transcript string `json:"transcript,omitempty"`
 }
 
 // QwenToolCall represents tool calling information
 type QwenToolCall struct {
 	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"`
 	Function QwenFunction `json:"function,omitempty"`
 	Index    int          `json:"index,omitempty"`
 }
@@ -67,9 +110,45 @@ type QwenFunction struct {
 
 // QwenUsage represents token usage information
 type QwenUsage struct {
-	PromptTokens     int `json:"prompt_tokens"`
-	CompletionTokens int `json:"completion_tokens"`
-	TotalTokens      int `json:"total_tokens"`
+	PromptTokens            int `json:"prompt_tokens"`
+	CompletionTokens        int `json:"completion_tokens"`
+	TotalTokens             int `json:"total_tokens"`
+	CompletionTokensDetails *struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"completion_tokens_details,omitempty"`
+}
+
+// toUsage converts the wire format into the provider-neutral Usage
+func (u *QwenUsage) toUsage() *Usage {
+	usage := &Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+	if u.CompletionTokensDetails != nil {
+		usage.ReasoningTokens = u.CompletionTokensDetails.ReasoningTokens
+	}
+	return usage
+}
+
+// usage returns the chunk's usage, attributed to the model that served it
+func (r *QwenThinkingStreamResponse) usage(requestModel string) *Usage {
+	usage := r.Usage.toUsage()
+	usage.Model = r.Model
+	if usage.Model == "" {
+		usage.Model = requestModel
+	}
+	return usage
+}
+
+// toDelta converts a streamed tool call fragment into the provider-neutral form
+func (t QwenToolCall) toDelta() *ToolCallDelta {
+	return &ToolCallDelta{
+		Index:     t.Index,
+		ID:        t.ID,
+		Name:      t.Function.Name,
+		Arguments: t.Function.Arguments,
+	}
 }
 
 // NewQwenThinkingClient creates a new client specifically for Qwen thinking mode
@@ -79,166 +158,133 @@ func NewQwenThinkingClient(apiKey, baseURL, model string) *QwenThinkingClient {
 		baseURL: baseURL,
 		model:   model,
 		params:  defaultParams,
+		retry:   DefaultRetryPolicy,
 	}
 }
 
-// ChatWithThinkingStream streams the thinking process and final response
-func (q *QwenThinkingClient) ChatWithThinkingStream(ctx context.Context, messages []QwenMessage, callback func(stage string, content string, isComplete bool)) error {
-	// Process thinking prompt controls for the last user message
-	var thinkingEnabled bool
-	if len(messages) > 0 {
-		lastMessage := messages[len(messages)-1]
-		if lastMessage.Role == "user" {
-			_, thinkingEnabled = q.processThinkingPrompt(lastMessage.Content)
-		}
-	}
+// ChatWithThinkingStream streams the thinking process and final response as typed events.
+// It performs a single model turn: requested tools are reported as EventToolCall fragments
+// and executed by Client, which feeds the results back.
+func (q *QwenThinkingClient) ChatWithThinkingStream(ctx context.Context, messages []QwenMessage) <-chan StreamEvent {
+	events := make(chan StreamEvent, streamBuffer)
 
-	// Build request
-	reqBody := QwenThinkingRequest{
-		Model:    q.model,
-		Messages: messages,
-		Stream:   true,
-	}
+	go func() {
+		defer close(events)
 
-	// Add thinking mode support
-	if thinkingEnabled {
-		reqBody.ExtraBody = map[string]interface{}{
-			"enable_thinking": true,
+		// Transient failures are retried only until the first event reaches the consumer
+		primary := Endpoint{BaseURL: q.baseURL, APIKey: q.apiKey, Model: modelFrom(ctx, q.model)}
+		err := q.failover.run(ctx, "Qwen thinking request", q.retry, primary, false, func(ep Endpoint) error {
+			return q.stream(ctx, ep, messages, events)
+		})
+		if err != nil {
+			emit(ctx, events, StreamEvent{Type: EventError, Err: err})
 		}
-	}
-
-	// Marshal request body
-	jsonBody, err := json.Marshal(reqBody)
-	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, "POST", q.baseURL+"/chat/completions", bytes.NewReader(jsonBody))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
+	}()
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+q.apiKey)
-
-	// Execute request
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
+	return events
+}
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(bodyBytes))
+// stream performs the request and forwards parsed deltas to events.
+// Errors after the first delivered event are wrapped in partialError.
+func (q *QwenThinkingClient) stream(ctx context.Context, ep Endpoint, messages []QwenMessage, events chan<- StreamEvent) error {
+	emitted := false
+	send := func(event StreamEvent) bool {
+		if !emit(ctx, events, event) {
+			return false
+		}
+		emitted = true
+		return true
 	}
 
-	// Process streaming response
-	reader := bufio.NewReader(resp.Body)
-	var reasoningContent string
-	var answerContent string
-	isAnswering := false
-
-	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return fmt.Errorf("failed to read stream: %w", err)
-		}
+	params := resolveParams(ctx, q.params)
 
-		line = strings.TrimSpace(line)
-		if line == "" {
+	// Process thinking prompt controls for the last user message.
+	// During a tool loop it is followed by assistant and tool messages.
+	thinkingEnabled := params.EnableThinking
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != "user" {
 			continue
 		}
-
-		// Remove "data: " prefix if present
-		line = strings.TrimPrefix(line, "data: ")
-
-		// Skip "[DONE]" message
-		if line == "[DONE]" {
-			break
+		var content string
+		content, thinkingEnabled = processThinkingPrompt(messages[i].Content, params.EnableThinking)
+		if content != "" {
+			// Copy so the caller's slice keeps the original text
+			messages = append([]QwenMessage(nil), messages...)
+			messages[i].Content = content
 		}
+		break
+	}
 
-		// Parse JSON response
-		var streamResp QwenThinkingStreamResponse
-		if err := json.Unmarshal([]byte(line), &streamResp); err != nil {
-			continue // Skip malformed JSON
-		}
+	model := ep.Model
+	reqBody := newQwenRequest(model, messages, params, thinkingEnabled)
+	reqBody.Tools = q.tools.qwenTools()
 
-		if len(streamResp.Choices) > 0 {
-			delta := streamResp.Choices[0].Delta
+	var finishReason string
+	err := postStream(ctx, ep.BaseURL+"/chat/completions", ep.APIKey, reqBody, func(chunk *QwenThinkingStreamResponse) error {
+		if len(chunk.Choices) > 0 {
+			choice := chunk.Choices[0]
+			delta := choice.Delta
 
 			// Handle reasoning content (thinking process)
 			if delta.ReasoningContent != "" {
-				reasoningContent += delta.ReasoningContent
-				callback("thinking", delta.ReasoningContent, false)
+				if !send(StreamEvent{Type: EventReasoning, Delta: delta.ReasoningContent}) {
+					return ctx.Err()
+				}
 			}
 
 			// Handle regular content (final response)
 			if delta.Content != "" {
-				if !isAnswering {
-					callback("thinking_complete", "", false)
-					isAnswering = true
+				if !send(StreamEvent{Type: EventAnswer, Delta: delta.Content}) {
+					return ctx.Err()
 				}
-				answerContent += delta.Content
-				callback("streaming", delta.Content, false)
 			}
 
 			// Handle tool calls if present
-			if len(delta.ToolCalls) > 0 {
-				for _, toolCall := range delta.ToolCalls {
-					toolInfo := fmt.Sprintf("Tool: %s", toolCall.Function.Name)
-					if toolCall.Function.Arguments != "" {
-						toolInfo += fmt.Sprintf(" - Args: %s", toolCall.Function.Arguments)
-					}
-					callback("tool_call", toolInfo, false)
+			for _, toolCall := range delta.ToolCalls {
+				if !send(StreamEvent{Type: EventToolCall, ToolCall: toolCall.toDelta()}) {
+					return ctx.Err()
 				}
 			}
+
+			if choice.FinishReason != "" {
+				finishReason = choice.FinishReason
+			}
 		}
 
 		// Handle usage information
-		if streamResp.Usage != nil {
-			usageInfo := fmt.Sprintf("Tokens: %d prompt, %d completion, %d total",
-				streamResp.Usage.PromptTokens,
-				streamResp.Usage.CompletionTokens,
-				streamResp.Usage.TotalTokens)
-			callback("usage", usageInfo, false)
+		if chunk.Usage != nil {
+			usage := chunk.usage(model)
+			recordUsage(ctx, q.usage, usage, model)
+			if !send(StreamEvent{Type: EventUsage, Usage: usage}) {
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if emitted {
+			return partialError{err}
 		}
+		return err
 	}
 
-	// Final callback with complete response
-	callback("complete", answerContent, true)
+	send(StreamEvent{Type: EventDone, FinishReason: finishReason})
 	return nil
 }
 
 // ChatWithThinking provides a complete thinking response with both reasoning and answer
 func (q *QwenThinkingClient) ChatWithThinking(ctx context.Context, messages []QwenMessage) (*ThinkingResponse, error) {
-	response := &ThinkingResponse{}
-
-	err := q.ChatWithThinkingStream(ctx, messages, func(stage string, content string, isComplete bool) {
-		switch stage {
-		case "thinking":
-			response.ReasoningContent += content
-		case "streaming":
-			response.AnswerContent += content
-		case "complete":
-			response.IsComplete = true
-		}
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	return response, nil
+	return CollectStream(q.ChatWithThinkingStream(ctx, messages))
 }
 
 // processThinkingPrompt checks for /think or /no_think in the prompt and adjusts thinking mode
 func (q *QwenThinkingClient) processThinkingPrompt(content string) (string, bool) {
+	return processThinkingPrompt(content, q.params.EnableThinking)
+}
+
+// processThinkingPrompt strips a trailing /think or /no_think and returns the resulting thinking mode,
+// falling back to defaultThinking when neither is present
+func processThinkingPrompt(content string, defaultThinking bool) (string, bool) {
 	content = strings.TrimSpace(content)
 
 	// Check for /no_think to disable thinking mode
@@ -254,7 +300,7 @@ func (q *QwenThinkingClient) processThinkingPrompt(content string) (string, bool
 	}
 
 	// Default to current thinking mode setting
-	return content, q.params.EnableThinking
+	return content, defaultThinking
 }
 
 // SetParams allows overriding default model params at runtime
@@ -262,6 +308,26 @@ func (q *QwenThinkingClient) SetParams(p ModelParams) {
 	q.params = p
 }
 
+// SetRetryPolicy changes how transient failures are retried
+func (q *QwenThinkingClient) SetRetryPolicy(p RetryPolicy) {
+	q.retry = p
+}
+
+// SetUsageRecorder sets where token usage is reported; nil disables recording
+func (q *QwenThinkingClient) SetUsageRecorder(r UsageRecorder) {
+	q.usage = r
+}
+
+// SetTools sets the tools offered to the model; nil removes them
+func (q *QwenThinkingClient) SetTools(r *ToolRegistry) {
+	q.tools = r
+}
+
+// SetFailover sets the fallback chain tried when an endpoint is unavailable
+func (q *QwenThinkingClient) SetFailover(f *Failover) {
+	q.failover = f
+}
+
 // SetThinkingMode enables or disables thinking mode
 func (q *QwenThinkingClient) SetThinkingMode(enabled bool) {
 	q.params.EnableThinking = enabled
@@ -0,0 +1,122 @@
+package ai
+
+import "context"
+
+// RequestOptions overrides the client's ModelParams for a single request.
+// Nil fields keep the client default.
+type RequestOptions struct {
+	Temperature    *float64
+	TopP           *float64
+	TopK           *int
+	EnableThinking *bool
+	ThinkingBudget *int
+	MaxTokens      *int
+	Seed           *int
+	Stop           []string
+	// Audio overrides the non-empty fields of the client's AudioOptions for spoken output
+	Audio *AudioOptions
+	// NoCache bypasses the client's ResponseCache for this request
+	NoCache bool
+}
+
+type requestOptionsKey struct{}
+
+// WithRequestOptions returns a context carrying per-request parameter overrides
+func WithRequestOptions(ctx context.Context, opts RequestOptions) context.Context {
+	return context.WithValue(ctx, requestOptionsKey{}, opts)
+}
+
+// requestOptionsFrom extracts the overrides stored by WithRequestOptions
+func requestOptionsFrom(ctx context.Context) (RequestOptions, bool) {
+	opts, ok := ctx.Value(requestOptionsKey{}).(RequestOptions)
+	return opts, ok
+}
+
+// resolveParams applies the overrides carried by ctx on top of base
+func resolveParams(ctx context.Context, base ModelParams) ModelParams {
+	opts, ok := requestOptionsFrom(ctx)
+	if !ok {
+		return base
+	}
+
+	p := base
+	if opts.Temperature != nil {
+		p.Temperature = *opts.Temperature
+	}
+	if opts.TopP != nil {
+		p.TopP = *opts.TopP
+	}
+	if opts.TopK != nil {
+		p.TopK = *opts.TopK
+	}
+	if opts.EnableThinking != nil {
+		p.EnableThinking = *opts.EnableThinking
+	}
+	if opts.ThinkingBudget != nil {
+		p.ThinkingBudget = *opts.ThinkingBudget
+	}
+	if opts.MaxTokens != nil {
+		p.MaxTokens = *opts.MaxTokens
+	}
+	if opts.Seed != nil {
+		seed := *opts.Seed
+		p.Seed = &seed
+	}
+	if opts.Stop != nil {
+		p.Stop = opts.Stop
+	}
+	return p
+}
+
+// newQwenRequest builds the wire request for DashScope's compatible endpoint.
+// Vendor extensions are serialized as top-level fields, which is how the compatible
+// mode expects the OpenAI SDK's extra_body to arrive.
+func newQwenRequest(model string, messages []QwenMessage, p ModelParams, thinking bool) QwenThinkingRequest {
+	req := QwenThinkingRequest{
+		Model:          model,
+		Messages:       messages,
+		Stream:         true,
+		StreamOptions:  &QwenStreamOptions{IncludeUsage: true},
+		EnableThinking: &thinking,
+		Stop:           p.Stop,
+		Seed:           p.Seed,
+	}
+
+	if p.Temperature > 0 {
+		req.Temperature = &p.Temperature
+	}
+	if p.TopP > 0 {
+		req.TopP = &p.TopP
+	}
+	if p.TopK > 0 {
+		req.TopK = &p.TopK
+	}
+	if thinking && p.ThinkingBudget > 0 {
+		req.ThinkingBudget = &p.ThinkingBudget
+	}
+	if p.MaxTokens > 0 {
+		req.MaxTokens = &p.MaxTokens
+	}
+
+	return req
+}
+
+// applyParams copies the sampling params onto req. top_k is only sent when dashScope is set.
+// These requests stream the answer only, so thinking models are asked not to think and
+// thinking_budget never applies; reasoning goes through the Qwen thinking client.
+func applyParams(req *completionStreamRequest, p ModelParams, dashScope, thinkingModel bool) {
+	req.Temperature = float32(p.Temperature)
+	req.TopP = float32(p.TopP)
+	req.MaxTokens = p.MaxTokens
+	req.Seed = p.Seed
+	req.Stop = p.Stop
+
+	if dashScope && p.TopK > 0 {
+		topK := p.TopK
+		req.TopK = &topK
+	}
+	if thinkingModel {
+		thinking := false
+		req.EnableThinking = &thinking
+	}
+}
@@ -0,0 +1,167 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// captureServer records every request body and replies with a minimal successful stream
+func captureServer(t *testing.T, bodies chan<- []byte) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read request body: %v", err)
+		}
+		bodies <- body
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"choices":[{"delta":{"content":"ok"},"finish_reason":"stop"}]}`+"\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestThinkingRequestBody(t *testing.T) {
+	temperature := 0.2
+	topK := 10
+	budget := 512
+	maxTokens := 256
+	seed := 7
+	thinking := true
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		message string
+		want    string
+	}{
+		{
+			name:    "Defaults",
+			ctx:     context.Background(),
+			message: "Hello",
+			want:    `{"model":"qwen-plus","messages":[{"role":"system","content":"Be brief"},{"role":"user","content":"Hello"}],"stream":true,"stream_options":{"include_usage":true},"temperature":0.75,"top_p":0.92,"top_k":45,"enable_thinking":true}`,
+		},
+		{
+			name:    "No think suffix disables thinking and is stripped",
+			ctx:     context.Background(),
+			message: "Hello /no_think",
+			want:    `{"model":"qwen-plus","messages":[{"role":"system","content":"Be brief"},{"role":"user","content":"Hello"}],"stream":true,"stream_options":{"include_usage":true},"temperature":0.75,"top_p":0.92,"top_k":45,"enable_thinking":false}`,
+		},
+		{
+			name: "Per-request overrides",
+			ctx: WithRequestOptions(context.Background(), RequestOptions{
+				Temperature:    &temperature,
+				TopK:           &topK,
+				EnableThinking: &thinking,
+				ThinkingBudget: &budget,
+				MaxTokens:      &maxTokens,
+				Seed:           &seed,
+				Stop:           []string{"END"},
+			}),
+			message: "Hello",
+			want:    `{"model":"qwen-plus","messages":[{"role":"system","content":"Be brief"},{"role":"user","content":"Hello"}],"stream":true,"stream_options":{"include_usage":true},"temperature":0.2,"top_p":0.92,"top_k":10,"enable_thinking":true,"thinking_budget":512,"max_tokens":256,"seed":7,"stop":["END"]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bodies := make(chan []byte, 1)
+			server := captureServer(t, bodies)
+
+			client := NewQwenThinkingClient("test-key", server.URL, "qwen-plus")
+			messages := []QwenMessage{
+				{Role: "system", Content: "Be brief"},
+				{Role: "user", Content: tt.message},
+			}
+
+			response, err := client.ChatWithThinking(tt.ctx, messages)
+			if err != nil {
+				t.Fatalf("ChatWithThinking() error = %v", err)
+			}
+			if response.AnswerContent != "ok" {
+				t.Errorf("Expected answer 'ok', got '%s'", response.AnswerContent)
+			}
+
+			if got := string(<-bodies); got != tt.want {
+				t.Errorf("Request body mismatch\n got: %s\nwant: %s", got, tt.want)
+			}
+
+			if messages[1].Content != tt.message {
+				t.Errorf("Caller's messages were modified: %q", messages[1].Content)
+			}
+		})
+	}
+}
+
+func TestClientChatSendsOverrides(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	server := captureServer(t, bodies)
+
+	client := NewOpenAICompatibleClient("test-key", server.URL, "llama3")
+
+	maxTokens := 64
+	seed := 42
+	ctx := WithRequestOptions(context.Background(), RequestOptions{MaxTokens: &maxTokens, Seed: &seed})
+
+	if _, err := client.Chat(ctx, []Message{{Role: "user", Content: "Hello"}}); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal(<-bodies, &body); err != nil {
+		t.Fatalf("failed to parse request body: %v", err)
+	}
+
+	if body["max_tokens"] != float64(64) {
+		t.Errorf("Expected max_tokens 64, got %v", body["max_tokens"])
+	}
+	if body["seed"] != float64(42) {
+		t.Errorf("Expected seed 42, got %v", body["seed"])
+	}
+	if _, ok := body["enable_thinking"]; ok {
+		t.Error("OpenAI-compatible request must not carry enable_thinking")
+	}
+	if _, ok := body["top_k"]; ok {
+		t.Error("OpenAI-compatible request must not carry top_k")
+	}
+}
+
+func TestClientChatStreamSendsParams(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	server := captureServer(t, bodies)
+
+	client := NewClient("test-key", server.URL, "qwen-plus")
+
+	thinking := false
+	maxTokens := 128
+	ctx := WithRequestOptions(context.Background(), RequestOptions{EnableThinking: &thinking, MaxTokens: &maxTokens, Stop: []string{"END"}})
+
+	// With thinking disabled, ChatStreamWithThinking falls back to the go-openai request
+	response, err := CollectStream(client.ChatStreamWithThinking(ctx, []Message{{Role: "user", Content: "Hello"}}))
+	if err != nil || response.AnswerContent != "ok" {
+		t.Fatalf("ChatStreamWithThinking() = %+v, %v", response, err)
+	}
+	want := `{"model":"qwen-plus","messages":[{"role":"user","content":"Hello"}],"max_tokens":128,"temperature":0.75,"top_p":0.92,"stream":true,"stop":["END"],"stream_options":{"include_usage":true},"top_k":45,"enable_thinking":false}`
+	if got := string(<-bodies); got != want {
+		t.Errorf("Request body mismatch\n got: %s\nwant: %s", got, want)
+	}
+
+	if _, err := CollectStream(client.ChatStream(ctx, []Message{{Role: "user", Content: "Hi"}})); err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(<-bodies, &body); err != nil {
+		t.Fatalf("failed to parse request body: %v", err)
+	}
+	if body["max_tokens"] != float64(128) || body["temperature"] != 0.75 || body["top_k"] != float64(45) || body["enable_thinking"] != false {
+		t.Errorf("ChatStream() dropped params: %v", body)
+	}
+}
@@ -0,0 +1,262 @@
+package ai
+
+import (
+	"Qwen/internal/vector"
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PastTurn is a stored turn with its owner and time, as indexed for retrieval
+type PastTurn struct {
+	Turn
+	UserID string
+	At     time.Time
+}
+
+// Recollection is a past turn retrieved for the current message
+type Recollection struct {
+	PastTurn
+	Score float32
+}
+
+// TurnSource lists the stored turns of all users by ascending ID, for Backfill
+type TurnSource interface {
+	TurnsAfter(afterID int64, limit int) ([]PastTurn, error)
+}
+
+// RetrievalOptions tunes which past turns are recalled and how they are quoted
+type RetrievalOptions struct {
+	// TopK is the most turns recalled per message
+	TopK int
+	// MinScore is the lowest cosine similarity worth recalling
+	MinScore float32
+	// ExcerptChars bounds each quoted message, in characters
+	ExcerptChars int
+}
+
+// DefaultRetrievalOptions recalls up to 3 clearly related turns with excerpts of 200 characters
+var DefaultRetrievalOptions = RetrievalOptions{
+	TopK:         3,
+	MinScore:     0.45,
+	ExcerptChars: 200,
+}
+
+const (
+	// embeddingInputChars bounds the text embedded per turn, well within the embedding model's window
+	embeddingInputChars = 2000
+	// storedTextChars bounds the text kept per turn in the index, enough for any excerpt
+	storedTextChars = 1000
+	// backfillPage is the number of stored turns read per query by Backfill
+	backfillPage = 100
+)
+
+// Index metadata keys of a remembered turn; the item ID is the turn ID and the text the user message
+const (
+	metaUser   = "user"
+	metaAt     = "at"
+	metaAnswer = "answer"
+)
+
+// recollectionHeader introduces the recalled turns in the system prompt
+const recollectionHeader = "\n\nRelevant earlier conversations with this user. Use them only if they help with the new message, and cite them as [n] with their date:\n"
+
+// Retriever recalls the past turns of a user that are relevant to a new message, by the
+// similarity of their embeddings. Turns are indexed as they are saved (Remember) and
+// at startup (Backfill). A nil *Retriever recalls nothing.
+type Retriever struct {
+	llm   LLM
+	index vector.Index
+	opts  RetrievalOptions
+}
+
+// NewRetriever creates a retriever embedding with llm into index, usually a
+// vector.Collection so the embeddings survive restarts. Zero options take the defaults.
+func NewRetriever(llm LLM, index vector.Index, opts RetrievalOptions) *Retriever {
+	if opts.TopK <= 0 {
+		opts.TopK = DefaultRetrievalOptions.TopK
+	}
+	if opts.ExcerptChars <= 0 {
+		opts.ExcerptChars = DefaultRetrievalOptions.ExcerptChars
+	}
+	return &Retriever{llm: llm, index: index, opts: opts}
+}
+
+// Remember indexes a saved turn. Turns without an ID are ignored.
+func (r *Retriever) Remember(ctx context.Context, turn PastTurn) error {
+	if r == nil || turn.ID == 0 {
+		return nil
+	}
+	ctx = WithUsageTag(ctx, UsageTag{UserID: turn.UserID, Purpose: PurposeEmbedding})
+	return r.add(ctx, []PastTurn{turn})
+}
+
+// Backfill indexes the stored turns that are not indexed yet and returns how many it added
+func (r *Retriever) Backfill(ctx context.Context, source TurnSource) (int, error) {
+	if r == nil {
+		return 0, nil
+	}
+
+	// Pages mix users, so the embedding usage is not attributed to anyone
+	ctx = WithUsageTag(ctx, UsageTag{Purpose: PurposeEmbedding})
+
+	indexed := make(map[string]bool, r.index.Len())
+	for _, item := range r.index.Items() {
+		indexed[item.ID] = true
+	}
+
+	added := 0
+	var after int64
+	for {
+		turns, err := source.TurnsAfter(after, backfillPage)
+		if err != nil {
+			return added, err
+		}
+		if len(turns) == 0 {
+			return added, nil
+		}
+		after = turns[len(turns)-1].ID
+
+		var missing []PastTurn
+		for _, turn := range turns {
+			if !indexed[strconv.FormatInt(turn.ID, 10)] {
+				missing = append(missing, turn)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		if err := r.add(ctx, missing); err != nil {
+			return added, err
+		}
+		added += len(missing)
+	}
+}
+
+func (r *Retriever) add(ctx context.Context, turns []PastTurn) error {
+	texts := make([]string, len(turns))
+	for i, turn := range turns {
+		texts[i] = truncate(turnText(turn.Turn), embeddingInputChars)
+	}
+
+	vectors, err := r.llm.Embed(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("failed to embed turns: %w", err)
+	}
+
+	items := make([]vector.Item, len(turns))
+	for i, turn := range turns {
+		items[i] = vector.Item{
+			ID:     strconv.FormatInt(turn.ID, 10),
+			Vector: vectors[i],
+			Text:   truncate(turn.User, storedTextChars),
+			Meta: map[string]string{
+				metaUser:   turn.UserID,
+				metaAt:     turn.At.UTC().Format(time.RFC3339),
+				metaAnswer: truncate(turn.Assistant, storedTextChars),
+			},
+		}
+	}
+	return r.index.Add(items...)
+}
+
+// Forget removes the indexed turns of userID and returns how many were removed
+func (r *Retriever) Forget(userID string) int {
+	if r == nil || userID == "" {
+		return 0
+	}
+
+	var ids []string
+	for _, item := range r.index.Items() {
+		if item.Meta[metaUser] == userID {
+			ids = append(ids, item.ID)
+		}
+	}
+	if len(ids) > 0 {
+		r.index.Remove(ids...)
+	}
+	return len(ids)
+}
+
+// Recall returns up to TopK past turns of userID most similar to message, best first.
+// Turns in exclude, typically the history already in the prompt, are skipped.
+func (r *Retriever) Recall(ctx context.Context, userID, message string, exclude []Turn) ([]Recollection, error) {
+	if r == nil || userID == "" || strings.TrimSpace(message) == "" || r.index.Len() == 0 {
+		return nil, nil
+	}
+
+	ctx = WithUsageTag(ctx, UsageTag{UserID: userID, Purpose: PurposeEmbedding})
+	vectors, err := r.llm.Embed(ctx, []string{truncate(message, embeddingInputChars)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed message: %w", err)
+	}
+
+	skip := make(map[string]bool, len(exclude))
+	for _, turn := range exclude {
+		skip[strconv.FormatInt(turn.ID, 10)] = true
+	}
+	filter := func(item vector.Item) bool {
+		return item.Meta[metaUser] == userID && !skip[item.ID]
+	}
+
+	results, err := r.index.Search(vectors[0], r.opts.TopK, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var recollections []Recollection
+	for _, result := range results {
+		if result.Score < r.opts.MinScore {
+			break
+		}
+		id, _ := strconv.ParseInt(result.ID, 10, 64)
+		at, _ := time.Parse(time.RFC3339, result.Meta[metaAt])
+		recollections = append(recollections, Recollection{
+			PastTurn: PastTurn{
+				Turn:   Turn{ID: id, User: result.Text, Assistant: result.Meta[metaAnswer]},
+				UserID: userID,
+				At:     at,
+			},
+			Score: result.Score,
+		})
+	}
+	if len(recollections) > 0 {
+		log.Printf("🔎 Recalled %d earlier turn(s) for %s", len(recollections), userID)
+	}
+	return recollections, nil
+}
+
+// WithRecollections appends recollections to the system prompt as numbered citations
+// with their date and a short excerpt of both messages
+func (r *Retriever) WithRecollections(systemPrompt string, recollections []Recollection) string {
+	if r == nil || len(recollections) == 0 {
+		return systemPrompt
+	}
+
+	var b strings.Builder
+	b.WriteString(systemPrompt)
+	b.WriteString(recollectionHeader)
+	for i, rec := range recollections {
+		fmt.Fprintf(&b, "[%d] %s\nUser: %q\nAssistant: %q\n",
+			i+1, rec.At.Local().Format("2006-01-02"),
+			truncate(rec.User, r.opts.ExcerptChars), truncate(rec.Assistant, r.opts.ExcerptChars))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func turnText(turn Turn) string {
+	return "User: " + turn.User + "\nAssistant: " + turn.Assistant
+}
+
+// truncate shortens text to at most n characters, collapsing whitespace and marking the cut with "…"
+func truncate(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return strings.TrimSpace(string(runes[:n-1])) + "…"
+}
@@ -0,0 +1,138 @@
+package ai
+
+import (
+	"Qwen/internal/vector"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeTurnSource []PastTurn
+
+func (s fakeTurnSource) TurnsAfter(afterID int64, limit int) ([]PastTurn, error) {
+	var turns []PastTurn
+	for _, turn := range s {
+		if turn.ID > afterID && len(turns) < limit {
+			turns = append(turns, turn)
+		}
+	}
+	return turns, nil
+}
+
+func pastTurn(id int64, userID, user, assistant string) PastTurn {
+	return PastTurn{
+		Turn:   Turn{ID: id, User: user, Assistant: assistant},
+		UserID: userID,
+		At:     time.Date(2026, 3, int(id), 12, 0, 0, 0, time.Local),
+	}
+}
+
+func TestRetrieverRecallsRelevantTurns(t *testing.T) {
+	ctx := context.Background()
+	r := NewRetriever(NewFakeClient(), vector.NewFlat(), RetrievalOptions{TopK: 2, MinScore: 0.2})
+
+	source := fakeTurnSource{
+		pastTurn(1, "42", "resep rendang daging sapi", "rendang dimasak dengan santan"),
+		pastTurn(2, "42", "cuaca hari ini", "cerah berawan"),
+		pastTurn(3, "7", "resep rendang ayam", "pakai santan kental"),
+		pastTurn(4, "42", "rendang pakai santan apa", "santan kelapa segar"),
+	}
+	added, err := r.Backfill(ctx, source)
+	if err != nil || added != 4 {
+		t.Fatalf("Backfill() = %d, %v", added, err)
+	}
+	if added, _ := r.Backfill(ctx, source); added != 0 {
+		t.Errorf("Second Backfill() added %d turns, want 0", added)
+	}
+
+	recollections, err := r.Recall(ctx, "42", "resep rendang santan", []Turn{{ID: 4}})
+	if err != nil {
+		t.Fatalf("Recall() error = %v", err)
+	}
+	if len(recollections) != 1 || recollections[0].ID != 1 {
+		t.Fatalf("Recall() = %+v, want only turn 1 of user 42", recollections)
+	}
+	rec := recollections[0]
+	if rec.User != "resep rendang daging sapi" || rec.Assistant != "rendang dimasak dengan santan" || !rec.At.Equal(source[0].At) {
+		t.Errorf("Unexpected recollection %+v", rec)
+	}
+
+	prompt := r.WithRecollections("System", recollections)
+	if !strings.HasPrefix(prompt, "System"+recollectionHeader) || !strings.Contains(prompt, "[1] 2026-03-01\nUser: \"resep rendang daging sapi\"") {
+		t.Errorf("Unexpected prompt %q", prompt)
+	}
+}
+
+func TestRetrieverRemember(t *testing.T) {
+	ctx := context.Background()
+	index := vector.NewFlat()
+	r := NewRetriever(NewFakeClient(), index, RetrievalOptions{ExcerptChars: 10})
+
+	if err := r.Remember(ctx, pastTurn(0, "42", "unsaved", "turn")); err != nil || index.Len() != 0 {
+		t.Errorf("Turns without an ID should be ignored, got %v", err)
+	}
+	long := strings.Repeat("kucing ", 50)
+	if err := r.Remember(ctx, pastTurn(5, "42", long, "kucing  suka\nikan")); err != nil {
+		t.Fatalf("Remember() error = %v", err)
+	}
+
+	recollections, _ := r.Recall(ctx, "42", "kucing", nil)
+	if len(recollections) != 1 {
+		t.Fatalf("Recall() = %+v", recollections)
+	}
+	prompt := r.WithRecollections("", recollections)
+	if !strings.Contains(prompt, `User: "kucing ku…"`) || !strings.Contains(prompt, `Assistant: "kucing su…"`) {
+		t.Errorf("Excerpts should be truncated, got %q", prompt)
+	}
+}
+
+func TestRetrieverForget(t *testing.T) {
+	ctx := context.Background()
+	index := vector.NewFlat()
+	r := NewRetriever(NewFakeClient(), index, DefaultRetrievalOptions)
+	r.Backfill(ctx, fakeTurnSource{
+		pastTurn(1, "42", "kopi susu", "enak"),
+		pastTurn(2, "7", "kopi hitam", "pahit"),
+		pastTurn(3, "42", "teh manis", "segar"),
+	})
+
+	if n := r.Forget("42"); n != 2 || index.Len() != 1 {
+		t.Fatalf("Forget() = %d, %d turns left", n, index.Len())
+	}
+	if recollections, _ := r.Recall(ctx, "42", "kopi susu", nil); len(recollections) != 0 {
+		t.Errorf("Forgotten turns recalled: %+v", recollections)
+	}
+	if recollections, _ := r.Recall(ctx, "7", "kopi hitam", nil); len(recollections) != 1 {
+		t.Errorf("Turns of other users should be kept, got %+v", recollections)
+	}
+}
+
+func TestRetrieverDisabled(t *testing.T) {
+	var r *Retriever
+	ctx := context.Background()
+	if err := r.Remember(ctx, pastTurn(1, "42", "a", "b")); err != nil {
+		t.Errorf("Remember() error = %v", err)
+	}
+	if recollections, err := r.Recall(ctx, "42", "a", nil); recollections != nil || err != nil {
+		t.Errorf("Recall() = %v, %v", recollections, err)
+	}
+	if n := r.Forget("42"); n != 0 {
+		t.Errorf("Forget() = %d", n)
+	}
+	if got := r.WithRecollections("System", []Recollection{{}}); got != "System" {
+		t.Errorf("WithRecollections() = %q", got)
+	}
+}
+
+func TestRetrieverReportsEmbeddingErrors(t *testing.T) {
+	fake := NewFakeClient()
+	r := NewRetriever(fake, vector.NewFlat(), DefaultRetrievalOptions)
+	r.Remember(context.Background(), pastTurn(1, "42", "a", "b"))
+
+	fake.Err = ErrRateLimited
+	if _, err := r.Recall(context.Background(), "42", "a", nil); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected the embedding error, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy controls how transient upstream failures are retried
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt (0 disables retrying)
+	MaxRetries int
+	// BaseDelay is the wait before the first retry; it doubles on every further retry
+	BaseDelay time.Duration
+	// MaxDelay caps the exponential backoff
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy retries twice with a backoff starting at 500ms
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 2,
+	BaseDelay:  500 * time.Millisecond,
+	MaxDelay:   8 * time.Second,
+}
+
+// maxRetryAfter bounds how long a server-requested Retry-After is honoured
+const maxRetryAfter = time.Minute
+
+// partialError marks a failure that happened after output reached the caller.
+// Such failures are never retried because the caller would see duplicated tokens.
+type partialError struct{ error }
+
+func (e partialError) Unwrap() error { return e.error }
+
+// do runs fn until it succeeds, fails permanently or the policy is exhausted
+func (p RetryPolicy) do(ctx context.Context, op string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		var partial partialError
+		if errors.As(err, &partial) {
+			return classifyError(partial.error)
+		}
+
+		err = classifyError(err)
+		if !p.shouldRetry(attempt, err) {
+			return err
+		}
+
+		delay := p.backoff(attempt, err)
+		log.Printf("⚠️ %s failed (attempt %d/%d), retrying in %s: %v", op, attempt, p.MaxRetries+1, delay, err)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// shouldRetry reports whether attempt (1-based) may be followed by another one
+func (p RetryPolicy) shouldRetry(attempt int, err error) bool {
+	if attempt > p.MaxRetries {
+		return false
+	}
+
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Retryable()
+}
+
+// backoff returns the wait after the given attempt: the server's Retry-After when present,
+// otherwise exponential backoff with jitter in [d/2, d)
+func (p RetryPolicy) backoff(attempt int, err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		if apiErr.RetryAfter > maxRetryAfter {
+			return maxRetryAfter
+		}
+		return apiErr.RetryAfter
+	}
+
+	d := p.BaseDelay << (attempt - 1)
+	if d <= 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+	if d <= 1 {
+		return d
+	}
+
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)))
+}
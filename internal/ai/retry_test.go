@@ -0,0 +1,158 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var fastRetry = RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+func TestParseAPIError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"Rate limited", 429, `{"error":{"message":"Requests rate limit exceeded","type":"limit_requests","code":"Throttling.RateQuota"}}`, ErrRateLimited},
+		{"Quota exceeded", 429, `{"error":{"message":"You exceeded your current quota","type":"insufficient_quota","code":"insufficient_quota"}}`, ErrQuotaExceeded},
+		{"Arrearage", 400, `{"error":{"message":"Access denied, please make sure your account is in good standing.","code":"Arrearage"}}`, ErrQuotaExceeded},
+		{"Content filtered", 400, `{"error":{"message":"Input data may contain inappropriate content.","type":"data_inspection_failed","code":"data_inspection_failed"}}`, ErrContentFiltered},
+		{"Bad request", 400, `{"error":{"message":"The parameter is invalid","code":"InvalidParameter"}}`, ErrBadRequest},
+		{"Native format", 500, `{"code":"InternalError","message":"An internal error has occured"}`, ErrUpstreamUnavailable},
+		{"Unavailable without body", 503, ``, ErrUpstreamUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := parseAPIError(tt.status, nil, []byte(tt.body))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("parseAPIError() = %v, want kind %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryAfterIsHonoured(t *testing.T) {
+	header := http.Header{}
+	header.Set("Retry-After", "3")
+	err := parseAPIError(http.StatusTooManyRequests, header, nil)
+
+	if err.RetryAfter != 3*time.Second {
+		t.Fatalf("Expected RetryAfter 3s, got %s", err.RetryAfter)
+	}
+	if got := DefaultRetryPolicy.backoff(1, err); got != 3*time.Second {
+		t.Errorf("Expected backoff to follow Retry-After, got %s", got)
+	}
+}
+
+func TestBackoffIsBoundedAndJittered(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	err := &APIError{Kind: ErrUpstreamUnavailable}
+
+	for attempt := 1; attempt <= 8; attempt++ {
+		d := policy.backoff(attempt, err)
+		want := policy.BaseDelay << (attempt - 1)
+		if want > policy.MaxDelay {
+			want = policy.MaxDelay
+		}
+		if d < want/2 || d >= want {
+			t.Errorf("backoff(%d) = %s, want in [%s, %s)", attempt, d, want/2, want)
+		}
+	}
+}
+
+func TestChatRetriesTransientFailures(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			http.Error(w, `{"error":{"message":"busy"}}`, http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `data: {"choices":[{"delta":{"content":"ok"}}]}`+"\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	client := NewOpenAICompatibleClient("test-key", server.URL, "test")
+	client.SetRetryPolicy(fastRetry)
+
+	got, err := client.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if got != "ok" {
+		t.Errorf("Chat() = %q, want 'ok'", got)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", calls)
+	}
+}
+
+func TestChatDoesNotRetryBadRequest(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		http.Error(w, `{"error":{"message":"invalid","code":"InvalidParameter"}}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := NewOpenAICompatibleClient("test-key", server.URL, "test")
+	client.SetRetryPolicy(fastRetry)
+
+	_, err := client.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}})
+	if !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Expected ErrBadRequest, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected a single attempt, got %d", calls)
+	}
+}
+
+func TestThinkingStreamRetriesOnlyBeforeFirstToken(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if n == 1 {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, `{"error":{"code":"Throttling"}}`, http.StatusTooManyRequests)
+			return
+		}
+		// Second attempt streams a token and then drops the connection
+		fmt.Fprint(w, `data: {"choices":[{"delta":{"content":"partial"}}]}`+"\n\n")
+		w.(http.Flusher).Flush()
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer server.Close()
+
+	client := NewQwenThinkingClient("test-key", server.URL, "qwen-test")
+	client.SetRetryPolicy(fastRetry)
+
+	var answer string
+	var streamErr error
+	for event := range client.ChatWithThinkingStream(context.Background(), []QwenMessage{{Role: "user", Content: "hi"}}) {
+		switch event.Type {
+		case EventAnswer:
+			answer += event.Delta
+		case EventError:
+			streamErr = event.Err
+		}
+	}
+
+	if answer != "partial" {
+		t.Errorf("Expected answer 'partial', got %q", answer)
+	}
+	if streamErr == nil {
+		t.Error("Expected an error after the connection dropped mid-stream")
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 attempts (one retry before the first token), got %d", calls)
+	}
+}
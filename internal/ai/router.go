@@ -0,0 +1,297 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Task is the kind of work a request performs; a Router maps tasks to models
+type Task string
+
+const (
+	// TaskChat is casual conversation
+	TaskChat Task = "chat"
+	// TaskReasoning is a chat turn that needs careful thought (see ClassifyChat)
+	TaskReasoning Task = "reasoning"
+	// TaskMemory is the JSON extraction of user facts
+	TaskMemory        Task = "memory"
+	TaskMedia         Task = "media"
+	TaskTranslation   Task = "translation"
+	TaskSpeech        Task = "speech"
+	TaskTranscription Task = "transcription"
+	// TaskSummary condenses old conversation turns (see ContextBuilder)
+	TaskSummary   Task = "summary"
+	TaskEmbedding Task = "embedding"
+)
+
+// Tasks lists every task in a stable order
+var Tasks = []Task{TaskChat, TaskReasoning, TaskMemory, TaskMedia, TaskTranslation, TaskSpeech, TaskTranscription, TaskSummary, TaskEmbedding}
+
+// taskFallback names the task whose route is used when a task has none of its own
+var taskFallback = map[Task]Task{
+	TaskReasoning: TaskChat,
+	TaskMemory:    TaskChat,
+	TaskSummary:   TaskMemory,
+}
+
+// RouteCount is the number of requests a task sent to a model
+type RouteCount struct {
+	Task  Task   `json:"task"`
+	Model string `json:"model"`
+	Count int64  `json:"count"`
+}
+
+type routeKey struct {
+	task  Task
+	model string
+}
+
+// Router maps tasks to models. Tasks without a route use the model the client
+// would use otherwise (AI_MODEL for chat, the speech model for TextToSpeech, ...).
+// A nil *Router routes nothing and counts nothing.
+type Router struct {
+	mu       sync.Mutex
+	routes   map[Task]string
+	counts   map[routeKey]int64
+	classify func(messages []Message) Task
+}
+
+// NewRouter creates a router; empty models are ignored
+func NewRouter(routes map[Task]string) *Router {
+	r := &Router{
+		routes:   make(map[Task]string),
+		counts:   make(map[routeKey]int64),
+		classify: ClassifyChat,
+	}
+	for task, model := range routes {
+		if model = strings.TrimSpace(model); model != "" {
+			r.routes[task] = model
+		}
+	}
+	return r
+}
+
+// ParseRoutes parses "task=model" pairs separated by commas, such as
+// "memory=qwen-turbo,reasoning=qwq-plus,media=qwen-omni-turbo"
+func ParseRoutes(spec string) (map[Task]string, error) {
+	routes := make(map[Task]string)
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, model, ok := strings.Cut(pair, "=")
+		task := Task(strings.ToLower(strings.TrimSpace(name)))
+		model = strings.TrimSpace(model)
+		if !ok || model == "" {
+			return nil, fmt.Errorf("invalid route %q, want task=model", pair)
+		}
+		if !task.valid() {
+			return nil, fmt.Errorf("unknown task %q in route %q", task, pair)
+		}
+		routes[task] = model
+	}
+	return routes, nil
+}
+
+func (t Task) valid() bool {
+	for _, task := range Tasks {
+		if t == task {
+			return true
+		}
+	}
+	return false
+}
+
+// SetClassifier replaces ClassifyChat, which decides between TaskChat and TaskReasoning
+func (r *Router) SetClassifier(classify func(messages []Message) Task) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.classify = classify
+}
+
+// Route returns the model for task, or fallback when no route applies, and counts and logs the decision
+func (r *Router) Route(task Task, fallback string) string {
+	if r == nil {
+		return fallback
+	}
+
+	r.mu.Lock()
+	model := r.lookup(task)
+	if model == "" {
+		model = fallback
+	}
+	key := routeKey{task, model}
+	r.counts[key]++
+	count := r.counts[key]
+	r.mu.Unlock()
+
+	log.Printf("🧭 Routed %s to %s (#%d)", task, model, count)
+	return model
+}
+
+// lookup follows taskFallback until a route is found; r.mu must be held
+func (r *Router) lookup(task Task) string {
+	for task != "" {
+		if model, ok := r.routes[task]; ok {
+			return model
+		}
+		task = taskFallback[task]
+	}
+	return ""
+}
+
+// Routes returns a copy of the configured routes
+func (r *Router) Routes() map[Task]string {
+	routes := make(map[Task]string)
+	if r == nil {
+		return routes
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for task, model := range r.routes {
+		routes[task] = model
+	}
+	return routes
+}
+
+// Stats returns how many requests each task sent to each model, ordered by task then model
+func (r *Router) Stats() []RouteCount {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	stats := make([]RouteCount, 0, len(r.counts))
+	for key, count := range r.counts {
+		stats = append(stats, RouteCount{Task: key.task, Model: key.model, Count: count})
+	}
+	r.mu.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Task != stats[j].Task {
+			return stats[i].Task < stats[j].Task
+		}
+		return stats[i].Model < stats[j].Model
+	})
+	return stats
+}
+
+// chatTask picks the task of a chat request: an explicit WithTask, then the usage purpose
+// (memory extraction is tagged PurposeMemory), then the classifier
+func (r *Router) chatTask(ctx context.Context, messages []Message) Task {
+	if task, ok := TaskFrom(ctx); ok {
+		return task
+	}
+	if tag, ok := UsageTagFrom(ctx); ok && tag.Purpose == PurposeMemory {
+		return TaskMemory
+	}
+	if r == nil {
+		return TaskChat
+	}
+
+	r.mu.Lock()
+	classify := r.classify
+	r.mu.Unlock()
+	if classify == nil {
+		return TaskChat
+	}
+	return classify(messages)
+}
+
+// reasoningHints mark questions (Indonesian and English) that benefit from a thinking model
+var reasoningHints = []string{
+	"jelaskan", "mengapa", "kenapa", "bagaimana cara", "bandingkan", "analisis", "analisa",
+	"hitung", "buktikan", "langkah demi langkah", "selesaikan", "rancang",
+	"explain", "why ", "compare", "analyze", "analyse", "calculate", "prove", "step by step",
+	"solve", "design", "debug",
+}
+
+// hardQuestionLength is the message length (in characters) from which a question counts as hard
+const hardQuestionLength = 500
+
+// ClassifyChat is the default classifier: the last user message is TaskReasoning when it ends
+// with /think, contains code, is long, or asks to explain, compare, calculate or prove something
+func ClassifyChat(messages []Message) Task {
+	var text string
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			text = strings.TrimSpace(messages[i].Content)
+			break
+		}
+	}
+
+	lower := strings.ToLower(text)
+	switch {
+	case strings.HasSuffix(lower, "/no_think"):
+		return TaskChat
+	case strings.HasSuffix(lower, "/think"),
+		strings.Contains(text, "```"),
+		utf8.RuneCountInString(text) >= hardQuestionLength:
+		return TaskReasoning
+	}
+	for _, hint := range reasoningHints {
+		if strings.Contains(lower, hint) {
+			return TaskReasoning
+		}
+	}
+	return TaskChat
+}
+
+type taskKey struct{}
+
+// WithTask returns a context whose requests are routed as task, overriding classification
+func WithTask(ctx context.Context, task Task) context.Context {
+	return context.WithValue(ctx, taskKey{}, task)
+}
+
+// TaskFrom returns the task stored by WithTask
+func TaskFrom(ctx context.Context) (Task, bool) {
+	task, ok := ctx.Value(taskKey{}).(Task)
+	return task, ok
+}
+
+type routedModelKey struct{}
+
+// withModel returns a context carrying the model chosen for a request, so every
+// turn of a tool loop and nested calls (Translate via Chat) use the same model
+func withModel(ctx context.Context, model string) context.Context {
+	return context.WithValue(ctx, routedModelKey{}, model)
+}
+
+// modelFrom returns the model stored by withModel, or fallback
+func modelFrom(ctx context.Context, fallback string) string {
+	if model, ok := ctx.Value(routedModelKey{}).(string); ok && model != "" {
+		return model
+	}
+	return fallback
+}
+
+// SetRouter sets how tasks are mapped to models; nil sends every request to its default model
+func (c *Client) SetRouter(r *Router) {
+	c.router = r
+}
+
+// Router returns the client's router
+func (c *Client) Router() *Router {
+	return c.router
+}
+
+// routeChat resolves the model of a chat request and stores it in the returned context.
+// A model already chosen for ctx is kept.
+func (c *Client) routeChat(ctx context.Context, messages []Message) (context.Context, string) {
+	if model := modelFrom(ctx, ""); model != "" {
+		return ctx, model
+	}
+	model := c.router.Route(c.router.chatTask(ctx, messages), c.Model)
+	return withModel(ctx, model), model
+}
+
+// route resolves the model of a non-chat task and stores it in the returned context
+func (c *Client) route(ctx context.Context, task Task, fallback string) (context.Context, string) {
+	model := c.router.Route(task, fallback)
+	return withModel(ctx, model), model
+}
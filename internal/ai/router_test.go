@@ -0,0 +1,128 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := ParseRoutes(" memory=qwen-flash, Reasoning = qwq-plus ,,media=qwen-omni-turbo")
+	if err != nil {
+		t.Fatalf("ParseRoutes() error = %v", err)
+	}
+	want := map[Task]string{TaskMemory: "qwen-flash", TaskReasoning: "qwq-plus", TaskMedia: "qwen-omni-turbo"}
+	if !reflect.DeepEqual(routes, want) {
+		t.Errorf("ParseRoutes() = %v, want %v", routes, want)
+	}
+
+	for _, spec := range []string{"memory", "memory=", "painting=qwen-plus"} {
+		if _, err := ParseRoutes(spec); err == nil {
+			t.Errorf("ParseRoutes(%q) should fail", spec)
+		}
+	}
+}
+
+func TestRouterFallbackAndStats(t *testing.T) {
+	router := NewRouter(map[Task]string{TaskChat: "qwen-plus", TaskMedia: "qwen-omni-turbo", TaskSpeech: " "})
+
+	tests := []struct {
+		task     Task
+		fallback string
+		want     string
+	}{
+		{TaskChat, "qwen-mt-turbo", "qwen-plus"},
+		{TaskReasoning, "qwen-mt-turbo", "qwen-plus"},
+		{TaskMemory, "qwen-mt-turbo", "qwen-plus"},
+		{TaskMedia, "qwen-mt-turbo", "qwen-omni-turbo"},
+		{TaskSpeech, "qwen-omni-turbo", "qwen-omni-turbo"},
+		{TaskMemory, "qwen-mt-turbo", "qwen-plus"},
+	}
+	for _, tt := range tests {
+		if got := router.Route(tt.task, tt.fallback); got != tt.want {
+			t.Errorf("Route(%s) = %q, want %q", tt.task, got, tt.want)
+		}
+	}
+
+	want := []RouteCount{
+		{TaskChat, "qwen-plus", 1},
+		{TaskMedia, "qwen-omni-turbo", 1},
+		{TaskMemory, "qwen-plus", 2},
+		{TaskReasoning, "qwen-plus", 1},
+		{TaskSpeech, "qwen-omni-turbo", 1},
+	}
+	if got := router.Stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Stats() = %v, want %v", got, want)
+	}
+
+	var none *Router
+	if got := none.Route(TaskChat, "qwen-plus"); got != "qwen-plus" || none.Stats() != nil {
+		t.Error("A nil router should return the fallback and count nothing")
+	}
+}
+
+func TestClassifyChat(t *testing.T) {
+	long := strings.Repeat("aku suka kopi. ", 40)
+	tests := map[string]Task{
+		"halo, apa kabar?":                       TaskChat,
+		"Jelaskan perbedaan TCP dan UDP":         TaskReasoning,
+		"why is the sky blue":                    TaskReasoning,
+		"kenapa ya /no_think":                    TaskChat,
+		"ceritakan lelucon /think":               TaskReasoning,
+		"ini error apa?\n```go\npanic(nil)\n```": TaskReasoning,
+		long:                                     TaskReasoning,
+	}
+	for text, want := range tests {
+		messages := NewConversation("system", []Message{{Role: "user", Content: "jelaskan"}, {Role: "assistant", Content: "ok"}}, text)
+		if got := ClassifyChat(messages); got != want {
+			t.Errorf("ClassifyChat(%.30q) = %s, want %s", text, got, want)
+		}
+	}
+}
+
+func TestClientRoutesByTask(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	server := captureServer(t, bodies)
+
+	client := NewClient("test-key", server.URL, "qwen-mt-turbo")
+	router := NewRouter(map[Task]string{TaskMemory: "qwen-flash", TaskReasoning: "qwq-plus"})
+	client.SetRouter(router)
+
+	sentModel := func() string {
+		var body struct {
+			Model string `json:"model"`
+		}
+		json.Unmarshal(<-bodies, &body)
+		return body.Model
+	}
+
+	memoryCtx := WithUsageTag(context.Background(), UsageTag{UserID: "42", Purpose: PurposeMemory})
+	if _, err := client.Chat(memoryCtx, NewConversation("", nil, "extract facts")); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if model := sentModel(); model != "qwen-flash" {
+		t.Errorf("Memory extraction went to %q, want qwen-flash", model)
+	}
+
+	if _, err := CollectStream(client.ChatStreamWithThinking(context.Background(), NewConversation("", nil, "Jelaskan teori relativitas"))); err != nil {
+		t.Fatalf("ChatStreamWithThinking() error = %v", err)
+	}
+	if model := sentModel(); model != "qwq-plus" {
+		t.Errorf("Hard question went to %q, want qwq-plus", model)
+	}
+
+	ctx := WithTask(context.Background(), TaskChat)
+	if _, err := CollectStream(client.ChatStream(ctx, NewConversation("", nil, "Jelaskan teori relativitas"))); err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	if model := sentModel(); model != "qwen-mt-turbo" {
+		t.Errorf("WithTask(TaskChat) went to %q, want the client model", model)
+	}
+
+	want := []RouteCount{{TaskChat, "qwen-mt-turbo", 1}, {TaskMemory, "qwen-flash", 1}, {TaskReasoning, "qwq-plus", 1}}
+	if got := router.Stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Stats() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,151 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// upstreamTimeout bounds connecting and waiting for the response headers. It does not
+	// bound the whole exchange: answers may stream for longer, up to the caller's ctx deadline.
+	upstreamTimeout = 60 * time.Second
+	// streamIdleTimeout aborts a stream that sends nothing, not even a keep-alive, for this long
+	streamIdleTimeout = 2 * time.Minute
+)
+
+// httpClient is shared by upstream requests so connections are reused
+var httpClient = newHTTPClient()
+
+func newHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = 10 * time.Second
+	transport.ResponseHeaderTimeout = upstreamTimeout
+	return &http.Client{Transport: transport}
+}
+
+// errStreamIdle cancels a stream that stopped sending data
+var errStreamIdle = errors.New("stream idle")
+
+// postStream sends a streaming chat completion request and calls onChunk for every parsed chunk.
+// It is shared by the Qwen and OpenAI-compatible paths so both see the usage chunk,
+// which go-openai's stream type drops.
+func postStream(ctx context.Context, url, apiKey string, body any, onChunk func(*QwenThinkingStreamResponse) error) error {
+	return postStreamIdle(ctx, url, apiKey, body, streamIdleTimeout, onChunk)
+}
+
+// postStreamIdle is postStream aborting after idle without data
+func postStreamIdle(ctx context.Context, url, apiKey string, body any, idle time.Duration, onChunk func(*QwenThinkingStreamResponse) error) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	idleTimer := time.AfterFunc(idle, func() { cancel(errStreamIdle) })
+	defer idleTimer.Stop()
+
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jsonBody))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", classifyError(streamError(ctx, err)))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return parseAPIError(resp.StatusCode, resp.Header, bodyBytes)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("failed to read stream: %w", classifyError(streamError(ctx, err)))
+		}
+		idleTimer.Reset(idle)
+
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		// Remove "data: " prefix if present
+		line = strings.TrimPrefix(line, "data:")
+		line = strings.TrimSpace(line)
+
+		if line == "[DONE]" {
+			return nil
+		}
+
+		var chunk QwenThinkingStreamResponse
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			continue // Skip malformed JSON
+		}
+		if err := onChunk(&chunk); err != nil {
+			return err
+		}
+	}
+}
+
+// postJSON sends a non-streaming request and decodes the JSON response into out
+func postJSON(ctx context.Context, url, apiKey string, body, out any) error {
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jsonBody))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", classifyError(err))
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", classifyError(err))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return parseAPIError(resp.StatusCode, resp.Header, bodyBytes)
+	}
+	if err := json.Unmarshal(bodyBytes, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// streamError reports an idle stream as an unavailable upstream, so it can be retried
+// or failed over like a dropped connection, instead of as a cancellation by the caller
+func streamError(ctx context.Context, err error) error {
+	if errors.Is(context.Cause(ctx), errStreamIdle) {
+		return &APIError{Kind: ErrUpstreamUnavailable, Err: errStreamIdle}
+	}
+	return err
+}
@@ -0,0 +1,77 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowStreamServer streams chunks "a", "b", ... every interval and then stalls for stall before finishing
+func slowStreamServer(t *testing.T, chunks int, interval, stall time.Duration) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for i := 0; i < chunks; i++ {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", string(rune('a'+i)))
+			flusher.Flush()
+			select {
+			case <-time.After(interval):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		select {
+		case <-time.After(stall):
+		case <-r.Context().Done():
+			return
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestUpstreamClientHasNoTotalTimeout(t *testing.T) {
+	if httpClient.Timeout != 0 {
+		t.Errorf("The shared client must not bound whole streams, got Timeout %s", httpClient.Timeout)
+	}
+	if transport := httpClient.Transport.(*http.Transport); transport.ResponseHeaderTimeout != upstreamTimeout {
+		t.Errorf("ResponseHeaderTimeout = %s, want %s", transport.ResponseHeaderTimeout, upstreamTimeout)
+	}
+}
+
+func TestPostStreamOutlastsIdleTimeout(t *testing.T) {
+	// The stream runs for about 6 idle periods but never goes quiet for one
+	server := slowStreamServer(t, 6, 50*time.Millisecond, 0)
+
+	var got strings.Builder
+	err := postStreamIdle(context.Background(), server.URL, "", struct{}{}, 200*time.Millisecond, func(chunk *QwenThinkingStreamResponse) error {
+		got.WriteString(chunk.Choices[0].Delta.Content)
+		return nil
+	})
+	if err != nil || got.String() != "abcdef" {
+		t.Errorf("postStreamIdle() = %q, %v", got.String(), err)
+	}
+}
+
+func TestPostStreamAbortsIdleStream(t *testing.T) {
+	server := slowStreamServer(t, 1, 0, 5*time.Second)
+
+	start := time.Now()
+	err := postStreamIdle(context.Background(), server.URL, "", struct{}{}, 100*time.Millisecond, func(*QwenThinkingStreamResponse) error {
+		return nil
+	})
+	if !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Errorf("Expected ErrUpstreamUnavailable, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Idle stream took %s to abort", elapsed)
+	}
+}
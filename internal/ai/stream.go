@@ -0,0 +1,107 @@
+package ai
+
+import "context"
+
+// StreamEventType identifies the kind of data carried by a StreamEvent
+type StreamEventType string
+
+const (
+	// EventReasoning carries a delta of the model's thinking process
+	EventReasoning StreamEventType = "reasoning"
+	// EventAnswer carries a delta of the final answer
+	EventAnswer StreamEventType = "answer"
+	// EventToolCall carries a fragment of a tool call requested by the model
+	EventToolCall StreamEventType = "tool_call"
+	// EventAudio carries a base64 chunk of spoken output from an omni model
+	EventAudio StreamEventType = "audio"
+	// EventUsage carries token usage for the request
+	EventUsage StreamEventType = "usage"
+	// EventDone is the last event of a successful stream
+	EventDone StreamEventType = "done"
+	// EventError is the last event of a failed stream
+	EventError StreamEventType = "error"
+)
+
+// StreamEvent is a single typed update from a streaming chat call.
+// Every stream ends with exactly one EventDone or EventError, after which the channel is closed.
+type StreamEvent struct {
+	Type StreamEventType `json:"type"`
+	// Delta is the text fragment for EventReasoning and EventAnswer
+	Delta string `json:"delta,omitempty"`
+	// ToolCall is set for EventToolCall
+	ToolCall *ToolCallDelta `json:"tool_call,omitempty"`
+	// Audio is the base64 data for EventAudio; every chunk decodes on its own
+	Audio string `json:"audio,omitempty"`
+	// Usage is set for EventUsage
+	Usage *Usage `json:"usage,omitempty"`
+	// FinishReason is set for EventDone when the upstream reported one (stop, length, tool_calls, ...)
+	FinishReason string `json:"finish_reason,omitempty"`
+	// Cached is set for EventDone when the answer was replayed from the ResponseCache
+	Cached bool `json:"cached,omitempty"`
+	// Err is set for EventError
+	Err error `json:"-"`
+}
+
+// ToolCallDelta is a streamed fragment of a tool call.
+// Fragments with the same Index belong to the same call; Arguments arrive in pieces.
+type ToolCallDelta struct {
+	Index     int    `json:"index"`
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+}
+
+// Usage reports the tokens consumed by a request
+type Usage struct {
+	Model            string `json:"model,omitempty"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	ReasoningTokens  int    `json:"reasoning_tokens,omitempty"`
+	TotalTokens      int    `json:"total_tokens"`
+}
+
+// streamBuffer is the channel capacity used by streaming calls
+const streamBuffer = 16
+
+// emit delivers an event unless ctx is cancelled while the consumer is not reading.
+// It reports whether the event was delivered.
+func emit(ctx context.Context, events chan<- StreamEvent, event StreamEvent) bool {
+	// Prefer delivery when there is room so terminal events survive cancellation
+	select {
+	case events <- event:
+		return true
+	default:
+	}
+
+	select {
+	case events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// CollectStream drains a stream into a ThinkingResponse.
+// It returns the error carried by an EventError, if any.
+func CollectStream(events <-chan StreamEvent) (*ThinkingResponse, error) {
+	response := &ThinkingResponse{}
+	var err error
+
+	for event := range events {
+		switch event.Type {
+		case EventReasoning:
+			response.ReasoningContent += event.Delta
+		case EventAnswer:
+			response.AnswerContent += event.Delta
+		case EventDone:
+			response.IsComplete = true
+		case EventError:
+			err = event.Err
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
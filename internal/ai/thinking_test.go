@@ -1,7 +1,12 @@
 package ai
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestProcessThinkingPrompt(t *testing.T) {
@@ -127,10 +132,12 @@ func TestIsQwenModel(t *testing.T) {
 	}{
 		{"Qwen model", "qwen-plus-2025-04-28", true},
 		{"Qwen turbo", "qwen-turbo", true},
-		{"Qwen max", "qwen-max", true},
+		{"QwQ", "qwq-plus", true},
+		{"Qwen max has no thinking mode", "qwen-max", false},
+		{"Unknown Qwen model", "qwen-test", false},
 		{"Non-Qwen model", "gpt-4", false},
 		{"Empty model", "", false},
-		{"Case insensitive", "QWEN-TEST", true},
+		{"Case insensitive", "QWEN-PLUS", true},
 	}
 
 	for _, tt := range tests {
@@ -164,3 +171,101 @@ func TestThinkingResponse(t *testing.T) {
 		t.Error("Expected IsComplete to be true")
 	}
 }
+
+func TestChatWithThinkingStreamEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"choices":[{"delta":{"reasoning_content":"Let me think"}}]}`+"\n\n")
+		fmt.Fprint(w, `data: {"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"get_time","arguments":"{}"}}]}}]}`+"\n\n")
+		fmt.Fprint(w, `data: {"choices":[{"delta":{"content":"Hello"}}]}`+"\n\n")
+		fmt.Fprint(w, `data: {"choices":[{"delta":{"content":" world"},"finish_reason":"stop"}]}`+"\n\n")
+		fmt.Fprint(w, `data: {"choices":[],"usage":{"prompt_tokens":5,"completion_tokens":7,"total_tokens":12,"completion_tokens_details":{"reasoning_tokens":3}}}`+"\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	client := NewQwenThinkingClient("test-key", server.URL, "qwen-test")
+
+	var events []StreamEvent
+	for event := range client.ChatWithThinkingStream(context.Background(), []QwenMessage{{Role: "user", Content: "hi"}}) {
+		events = append(events, event)
+	}
+
+	wantTypes := []StreamEventType{EventReasoning, EventToolCall, EventAnswer, EventAnswer, EventUsage, EventDone}
+	if len(events) != len(wantTypes) {
+		t.Fatalf("Expected %d events, got %d: %+v", len(wantTypes), len(events), events)
+	}
+	for i, want := range wantTypes {
+		if events[i].Type != want {
+			t.Errorf("Event %d type = %s, want %s", i, events[i].Type, want)
+		}
+	}
+
+	if call := events[1].ToolCall; call == nil || call.Name != "get_time" || call.ID != "call_1" {
+		t.Errorf("Unexpected tool call: %+v", events[1].ToolCall)
+	}
+	if usage := events[4].Usage; usage == nil || usage.TotalTokens != 12 || usage.ReasoningTokens != 3 {
+		t.Errorf("Unexpected usage: %+v", events[4].Usage)
+	}
+	if events[5].FinishReason != "stop" {
+		t.Errorf("Expected finish reason 'stop', got '%s'", events[5].FinishReason)
+	}
+}
+
+func TestChatWithThinkingStreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"message":"boom"}}`, http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := NewQwenThinkingClient("test-key", server.URL, "qwen-test")
+	client.SetRetryPolicy(RetryPolicy{})
+
+	_, err := client.ChatWithThinking(context.Background(), []QwenMessage{{Role: "user", Content: "hi"}})
+	if err == nil {
+		t.Fatal("Expected error for failed request")
+	}
+}
+
+func TestChatWithThinkingStreamCancel(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `data: {"choices":[{"delta":{"content":"Hello"}}]}`+"\n\n")
+		w.(http.Flusher).Flush()
+		// Hold the stream open until the client goes away
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewQwenThinkingClient("test-key", server.URL, "qwen-test")
+	ctx, cancel := context.WithCancel(context.Background())
+
+	events := client.ChatWithThinkingStream(ctx, []QwenMessage{{Role: "user", Content: "hi"}})
+	if first := <-events; first.Type != EventAnswer {
+		t.Fatalf("Expected first event to be an answer, got %s", first.Type)
+	}
+
+	cancel()
+
+	done := make(chan StreamEvent, 1)
+	go func() {
+		var last StreamEvent
+		for event := range events {
+			last = event
+		}
+		done <- last
+	}()
+
+	select {
+	case last := <-done:
+		if last.Type != EventError {
+			t.Errorf("Expected stream to end with EventError after cancel, got %s", last.Type)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stream did not stop after context cancellation")
+	}
+}
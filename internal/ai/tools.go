@@ -0,0 +1,281 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// DefaultMaxToolIterations bounds how many rounds of tool calls a single request may run
+const DefaultMaxToolIterations = 5
+
+// ErrToolLoopLimit is returned when the model keeps requesting tools after the iteration limit
+var ErrToolLoopLimit = errors.New("tool call iteration limit reached")
+
+// ToolFunc executes a tool. arguments is the JSON object produced by the model;
+// the returned string is sent back to the model as the tool result.
+type ToolFunc func(ctx context.Context, arguments json.RawMessage) (string, error)
+
+// Tool is a Go function the model may call
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the arguments object. Empty means no arguments.
+	Parameters json.RawMessage
+	Func       ToolFunc
+}
+
+// ToolCall is a complete tool call requested by the model
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// emptySchema is used for tools registered without parameters
+var emptySchema = json.RawMessage(`{"type":"object","properties":{}}`)
+
+// ToolRegistry holds the tools offered to the model. It is safe for concurrent use.
+type ToolRegistry struct {
+	mu    sync.RWMutex
+	tools map[string]Tool
+	order []string
+}
+
+// NewToolRegistry creates an empty registry
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{tools: make(map[string]Tool)}
+}
+
+// Register adds a tool. Names must be unique and the schema must be valid JSON.
+func (r *ToolRegistry) Register(tool Tool) error {
+	if strings.TrimSpace(tool.Name) == "" {
+		return fmt.Errorf("tool name is required")
+	}
+	if tool.Func == nil {
+		return fmt.Errorf("tool %q has no function", tool.Name)
+	}
+	if len(tool.Parameters) == 0 {
+		tool.Parameters = emptySchema
+	} else if !json.Valid(tool.Parameters) {
+		return fmt.Errorf("tool %q has an invalid parameter schema", tool.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.tools[tool.Name]; exists {
+		return fmt.Errorf("tool %q is already registered", tool.Name)
+	}
+	r.tools[tool.Name] = tool
+	r.order = append(r.order, tool.Name)
+	return nil
+}
+
+// Len returns the number of registered tools. A nil registry is empty.
+func (r *ToolRegistry) Len() int {
+	if r == nil {
+		return 0
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.order)
+}
+
+// Tools returns the registered tools in registration order
+func (r *ToolRegistry) Tools() []Tool {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tools := make([]Tool, len(r.order))
+	for i, name := range r.order {
+		tools[i] = r.tools[name]
+	}
+	return tools
+}
+
+// Execute runs a tool call and returns the content for the tool message.
+// Unknown tools, malformed arguments and tool failures are reported to the model as
+// a JSON error object so it can recover; only context cancellation is returned as an error.
+func (r *ToolRegistry) Execute(ctx context.Context, call ToolCall) (string, error) {
+	r.mu.RLock()
+	tool, ok := r.tools[call.Name]
+	r.mu.RUnlock()
+	if !ok {
+		return toolError(fmt.Sprintf("unknown tool %q", call.Name)), nil
+	}
+
+	arguments := json.RawMessage(call.Arguments)
+	if strings.TrimSpace(call.Arguments) == "" {
+		arguments = json.RawMessage("{}")
+	}
+	if !json.Valid(arguments) {
+		return toolError("arguments are not valid JSON"), nil
+	}
+
+	result, err := tool.Func(ctx, arguments)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return "", ctxErr
+	}
+	if err != nil {
+		log.Printf("⚠️ Tool %s failed: %v", call.Name, err)
+		return toolError(err.Error()), nil
+	}
+	return result, nil
+}
+
+func toolError(message string) string {
+	b, _ := json.Marshal(map[string]string{"error": message})
+	return string(b)
+}
+
+// qwenTools converts the registry to the DashScope wire format
+func (r *ToolRegistry) qwenTools() []QwenTool {
+	var tools []QwenTool
+	for _, tool := range r.Tools() {
+		tools = append(tools, QwenTool{
+			Type: "function",
+			Function: QwenToolFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	return tools
+}
+
+// openAITools converts the registry to go-openai tools
+func (r *ToolRegistry) openAITools() []openai.Tool {
+	var tools []openai.Tool
+	for _, tool := range r.Tools() {
+		tools = append(tools, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: openai.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	return tools
+}
+
+// toolCallAccumulator assembles streamed tool call fragments by index
+type toolCallAccumulator struct {
+	calls map[int]*ToolCall
+}
+
+func (a *toolCallAccumulator) add(delta *ToolCallDelta) {
+	if delta == nil {
+		return
+	}
+	if a.calls == nil {
+		a.calls = make(map[int]*ToolCall)
+	}
+
+	call, ok := a.calls[delta.Index]
+	if !ok {
+		call = &ToolCall{}
+		a.calls[delta.Index] = call
+	}
+	if delta.ID != "" {
+		call.ID = delta.ID
+	}
+	if delta.Name != "" {
+		call.Name = delta.Name
+	}
+	call.Arguments += delta.Arguments
+}
+
+// result returns the assembled calls ordered by index
+func (a *toolCallAccumulator) result() []ToolCall {
+	indexes := make([]int, 0, len(a.calls))
+	for index := range a.calls {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	calls := make([]ToolCall, 0, len(indexes))
+	for _, index := range indexes {
+		call := *a.calls[index]
+		if call.ID == "" {
+			call.ID = fmt.Sprintf("call_%d", index)
+		}
+		calls = append(calls, call)
+	}
+	return calls
+}
+
+// streamTurn performs a single model request over the given conversation
+type streamTurn func(ctx context.Context, messages []Message) <-chan StreamEvent
+
+// runToolLoop streams model turns, executing requested tools and feeding their results back
+// until the model answers without calling a tool. Events of every turn are forwarded;
+// only the final turn's EventDone is delivered.
+func runToolLoop(ctx context.Context, registry *ToolRegistry, maxIterations int, messages []Message, turn streamTurn) <-chan StreamEvent {
+	events := make(chan StreamEvent, streamBuffer)
+
+	go func() {
+		defer close(events)
+
+		messages = append([]Message(nil), messages...)
+		for iteration := 0; ; iteration++ {
+			var calls toolCallAccumulator
+			var answer strings.Builder
+			var done *StreamEvent
+
+			for event := range turn(ctx, messages) {
+				switch event.Type {
+				case EventToolCall:
+					calls.add(event.ToolCall)
+				case EventAnswer:
+					answer.WriteString(event.Delta)
+				case EventDone:
+					last := event
+					done = &last
+					continue
+				}
+				if !emit(ctx, events, event) || event.Type == EventError {
+					return
+				}
+			}
+			if done == nil {
+				// The turn was cancelled before finishing
+				return
+			}
+
+			requested := calls.result()
+			if len(requested) == 0 {
+				emit(ctx, events, *done)
+				return
+			}
+			if iteration >= maxIterations {
+				emit(ctx, events, StreamEvent{Type: EventError, Err: fmt.Errorf("%w (%d)", ErrToolLoopLimit, maxIterations)})
+				return
+			}
+
+			messages = append(messages, Message{Role: "assistant", Content: answer.String(), ToolCalls: requested})
+			for _, call := range requested {
+				result, err := registry.Execute(ctx, call)
+				if err != nil {
+					emit(ctx, events, StreamEvent{Type: EventError, Err: err})
+					return
+				}
+				messages = append(messages, Message{Role: "tool", Content: result, ToolCallID: call.ID})
+			}
+		}
+	}()
+
+	return events
+}
@@ -0,0 +1,204 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func weatherRegistry(t *testing.T, calls *int32) *ToolRegistry {
+	t.Helper()
+
+	registry := NewToolRegistry()
+	err := registry.Register(Tool{
+		Name:        "get_weather",
+		Description: "Current weather for a city",
+		Parameters:  json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}`),
+		Func: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+			atomic.AddInt32(calls, 1)
+			var args struct {
+				City string `json:"city"`
+			}
+			if err := json.Unmarshal(arguments, &args); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf(`{"city":%q,"temp_c":31}`, args.City), nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	return registry
+}
+
+func TestToolRegistryRegister(t *testing.T) {
+	registry := NewToolRegistry()
+	noop := func(ctx context.Context, arguments json.RawMessage) (string, error) { return "ok", nil }
+
+	if err := registry.Register(Tool{Name: "noop", Func: noop}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := registry.Register(Tool{Name: "noop", Func: noop}); err == nil {
+		t.Error("Expected error for duplicate tool name")
+	}
+	if err := registry.Register(Tool{Name: "broken", Parameters: json.RawMessage(`{`), Func: noop}); err == nil {
+		t.Error("Expected error for invalid schema")
+	}
+	if err := registry.Register(Tool{Name: "nofunc"}); err == nil {
+		t.Error("Expected error for missing function")
+	}
+
+	tools := registry.Tools()
+	if len(tools) != 1 || string(tools[0].Parameters) != string(emptySchema) {
+		t.Errorf("Expected a single tool with the empty schema, got %+v", tools)
+	}
+}
+
+func TestToolRegistryExecuteReportsErrorsToModel(t *testing.T) {
+	var calls int32
+	registry := weatherRegistry(t, &calls)
+
+	tests := []struct {
+		name string
+		call ToolCall
+		want string
+	}{
+		{"Unknown tool", ToolCall{Name: "missing"}, `{"error":"unknown tool \"missing\""}`},
+		{"Invalid arguments", ToolCall{Name: "get_weather", Arguments: `{"city":`}, `{"error":"arguments are not valid JSON"}`},
+		{"Success", ToolCall{Name: "get_weather", Arguments: `{"city":"Jakarta"}`}, `{"city":"Jakarta","temp_c":31}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := registry.Execute(context.Background(), tt.call)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Execute() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToolCallAccumulator(t *testing.T) {
+	var acc toolCallAccumulator
+	fragments := []*ToolCallDelta{
+		{Index: 0, ID: "call_a", Name: "get_weather", Arguments: `{"ci`},
+		{Index: 1, ID: "call_b", Name: "get_time"},
+		{Index: 0, Arguments: `ty":"Bandung"}`},
+		{Index: 1, Arguments: `{}`},
+	}
+	for _, fragment := range fragments {
+		acc.add(fragment)
+	}
+
+	want := []ToolCall{
+		{ID: "call_a", Name: "get_weather", Arguments: `{"city":"Bandung"}`},
+		{ID: "call_b", Name: "get_time", Arguments: `{}`},
+	}
+	got := acc.result()
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d calls, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Call %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+// toolServer replies with a fragmented tool call until the request carries a tool result,
+// then with a final answer. With alwaysCall it never stops requesting the tool.
+func toolServer(t *testing.T, alwaysCall bool, bodies chan<- string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- string(body)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		if alwaysCall || !strings.Contains(string(body), `"role":"tool"`) {
+			fmt.Fprint(w, `data: {"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":"}}]}}]}`+"\n\n")
+			fmt.Fprint(w, `data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Jakarta\"}"}}]},"finish_reason":"tool_calls"}]}`+"\n\n")
+		} else {
+			fmt.Fprint(w, `data: {"choices":[{"delta":{"content":"31°C in Jakarta"},"finish_reason":"stop"}]}`+"\n\n")
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClientToolLoop(t *testing.T) {
+	tests := []struct {
+		name   string
+		client func(url string) *Client
+	}{
+		{"Qwen thinking", func(url string) *Client { return NewClient("test-key", url, "qwen-plus") }},
+		{"OpenAI-compatible", func(url string) *Client { return NewOpenAICompatibleClient("test-key", url, "llama3") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bodies := make(chan string, 4)
+			server := toolServer(t, false, bodies)
+
+			var calls int32
+			client := tt.client(server.URL)
+			client.SetTools(weatherRegistry(t, &calls))
+
+			response, err := client.ChatWithThinking(context.Background(), []Message{{Role: "user", Content: "Weather in Jakarta?"}})
+			if err != nil {
+				t.Fatalf("ChatWithThinking() error = %v", err)
+			}
+			if response.AnswerContent != "31°C in Jakarta" {
+				t.Errorf("Unexpected answer %q", response.AnswerContent)
+			}
+			if calls != 1 {
+				t.Errorf("Expected the tool to run once, got %d", calls)
+			}
+
+			first, second := <-bodies, <-bodies
+			if !strings.Contains(first, `"tools":[{"type":"function","function":{"name":"get_weather"`) {
+				t.Errorf("First request does not offer the tool: %s", first)
+			}
+			for _, want := range []string{
+				`"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Jakarta\"}"}}]`,
+				`{"role":"tool","content":"{\"city\":\"Jakarta\",\"temp_c\":31}","tool_call_id":"call_1"}`,
+			} {
+				if !strings.Contains(second, want) {
+					t.Errorf("Second request is missing %s\n got: %s", want, second)
+				}
+			}
+		})
+	}
+}
+
+func TestClientToolLoopLimit(t *testing.T) {
+	bodies := make(chan string, 8)
+	server := toolServer(t, true, bodies)
+
+	var calls int32
+	client := NewClient("test-key", server.URL, "qwen-plus")
+	client.SetTools(weatherRegistry(t, &calls))
+	client.SetMaxToolIterations(2)
+
+	_, err := client.ChatWithThinking(context.Background(), []Message{{Role: "user", Content: "Weather?"}})
+	if !errors.Is(err, ErrToolLoopLimit) {
+		t.Fatalf("Expected ErrToolLoopLimit, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 tool executions, got %d", calls)
+	}
+	if len(bodies) != 3 {
+		t.Errorf("Expected 3 model requests, got %d", len(bodies))
+	}
+}
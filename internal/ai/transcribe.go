@@ -0,0 +1,181 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Transcription is the text spoken in an audio clip
+type Transcription struct {
+	Text string
+	// Language is the detected (or hinted) language, such as "id" or "en"; empty if unknown
+	Language string
+	// Segments carry timing when the backend reports it
+	Segments []TranscriptSegment
+}
+
+// TranscriptSegment is a timed part of a transcription
+type TranscriptSegment struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// transcriptionResult is the JSON shape of both /audio/transcriptions (verbose_json)
+// and the answer requested from omni models; times are in seconds
+type transcriptionResult struct {
+	Text     string `json:"text"`
+	Language string `json:"language"`
+	Segments []struct {
+		Start float64 `json:"start"`
+		End   float64 `json:"end"`
+		Text  string  `json:"text"`
+	} `json:"segments"`
+}
+
+func (r transcriptionResult) toTranscription() *Transcription {
+	out := &Transcription{
+		Text:     strings.TrimSpace(r.Text),
+		Language: r.Language,
+	}
+	for _, s := range r.Segments {
+		out.Segments = append(out.Segments, TranscriptSegment{
+			Start: time.Duration(s.Start * float64(time.Second)),
+			End:   time.Duration(s.End * float64(time.Second)),
+			Text:  strings.TrimSpace(s.Text),
+		})
+	}
+	return out
+}
+
+// transcribePrompt makes an omni model return a verbatim transcript as JSON
+const transcribePrompt = `You are a speech-to-text engine. Transcribe the user's audio verbatim in the language it is spoken. Do not translate, summarize or answer it.
+Respond with JSON only, no markdown:
+{"language": "<ISO 639-1 code>", "text": "<full transcript>", "segments": [{"start": <seconds>, "end": <seconds>, "text": "<sentence>"}]}
+Leave "segments" empty if you cannot tell the timing.`
+
+// Transcribe returns a faithful transcript of audio. languageHint (such as "id") is optional
+// and improves accuracy when the spoken language is known.
+// DashScope clients use the transcription (omni) model; OpenAI-compatible servers use /audio/transcriptions.
+func (c *Client) Transcribe(ctx context.Context, audio OmniMedia, languageHint string) (*Transcription, error) {
+	if audio.DataBase64 == "" {
+		return nil, fmt.Errorf("%w: empty audio", ErrBadRequest)
+	}
+
+	ctx, model := c.route(ctx, TaskTranscription, c.transcriptionModel)
+	if c.compatible {
+		data, err := base64.StdEncoding.DecodeString(audio.DataBase64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid base64 audio: %v", ErrBadRequest, err)
+		}
+		var result *transcriptionResult
+		err = c.do(ctx, "transcription request", model, true, func(ep Endpoint) error {
+			var err error
+			result, err = c.postTranscription(ctx, ep, data, audio.Mime, languageHint)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return result.toTranscription(), nil
+	}
+
+	if err := c.require(model, CapabilityAudioInput); err != nil {
+		return nil, err
+	}
+
+	prompt := transcribePrompt
+	if languageHint != "" {
+		prompt += fmt.Sprintf("\nThe audio is most likely in language %q.", languageHint)
+	}
+
+	body := c.omniRequestBody(ctx, model, prompt, "", nil, &audio, nil, false)
+	out, err := CollectOmniStream(c.streamOmniBody(ctx, body))
+	if err != nil {
+		return nil, err
+	}
+
+	transcription := parseTranscription(out.Text)
+	if transcription.Language == "" {
+		transcription.Language = languageHint
+	}
+	return transcription, nil
+}
+
+// parseTranscription reads the JSON answer of an omni model, falling back to the raw text
+func parseTranscription(answer string) *Transcription {
+	clean := strings.TrimSpace(answer)
+	clean = strings.TrimPrefix(clean, "```json")
+	clean = strings.TrimSuffix(clean, "```")
+	clean = strings.TrimSpace(clean)
+
+	var result transcriptionResult
+	if err := json.Unmarshal([]byte(clean), &result); err != nil || result.Text == "" {
+		return &Transcription{Text: strings.TrimSpace(answer)}
+	}
+	return result.toTranscription()
+}
+
+// postTranscription uploads audio to the OpenAI-style /audio/transcriptions endpoint
+func (c *Client) postTranscription(ctx context.Context, ep Endpoint, audio []byte, mime, languageHint string) (*transcriptionResult, error) {
+	var payload bytes.Buffer
+	form := multipart.NewWriter(&payload)
+
+	fields := map[string]string{
+		"model":           ep.Model,
+		"response_format": "verbose_json",
+	}
+	if languageHint != "" {
+		fields["language"] = languageHint
+	}
+	for name, value := range fields {
+		if err := form.WriteField(name, value); err != nil {
+			return nil, fmt.Errorf("failed to build form: %w", err)
+		}
+	}
+	file, err := form.CreateFormFile("file", "audio."+mimeToSimpleFormat(mime))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build form: %w", err)
+	}
+	if _, err := file.Write(audio); err != nil {
+		return nil, fmt.Errorf("failed to build form: %w", err)
+	}
+	if err := form.Close(); err != nil {
+		return nil, fmt.Errorf("failed to build form: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.BaseURL+"/audio/transcriptions", &payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+ep.APIKey)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request error: %w", classifyError(err))
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", classifyError(err))
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, parseAPIError(resp.StatusCode, resp.Header, body)
+	}
+
+	var result transcriptionResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse transcription: %w", err)
+	}
+	return &result, nil
+}
@@ -0,0 +1,103 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTranscription(t *testing.T) {
+	got := parseTranscription("```json\n{\"language\":\"id\",\"text\":\"Halo semua\",\"segments\":[{\"start\":0,\"end\":1.5,\"text\":\"Halo semua\"}]}\n```")
+	if got.Text != "Halo semua" || got.Language != "id" {
+		t.Errorf("Unexpected transcription %+v", got)
+	}
+	if len(got.Segments) != 1 || got.Segments[0].End != 1500*time.Millisecond {
+		t.Errorf("Unexpected segments %+v", got.Segments)
+	}
+
+	// Models occasionally ignore the JSON instruction; the answer is still the transcript
+	if got := parseTranscription("  just words "); got.Text != "just words" || got.Language != "" {
+		t.Errorf("Unexpected fallback %+v", got)
+	}
+}
+
+func TestTranscribeOmni(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		json.Unmarshal(raw, &body)
+
+		answer, _ := json.Marshal(`{"text":"selamat pagi","segments":[]}`)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%s},\"finish_reason\":\"stop\"}]}\n\n", answer)
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	client := NewClient("test-key", server.URL, "qwen-plus")
+	got, err := client.Transcribe(context.Background(), OmniMedia{Mime: "audio/ogg", DataBase64: ToBase64([]byte("voice"))}, "id")
+	if err != nil {
+		t.Fatalf("Transcribe() error = %v", err)
+	}
+	if got.Text != "selamat pagi" || got.Language != "id" {
+		t.Errorf("Unexpected transcription %+v", got)
+	}
+
+	if body["model"] != DefaultSpeechModel {
+		t.Errorf("Expected model %s, got %v", DefaultSpeechModel, body["model"])
+	}
+	if _, ok := body["audio"]; ok {
+		t.Error("Transcription must not request audio output")
+	}
+	raw, _ := json.Marshal(body["messages"])
+	if !strings.Contains(string(raw), `"format":"ogg"`) || !strings.Contains(string(raw), `\"id\"`) {
+		t.Errorf("Expected ogg input audio and language hint, got %s", raw)
+	}
+}
+
+func TestTranscribeCompatible(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/audio/transcriptions" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.FormValue("model") != "whisper-1" || r.FormValue("language") != "en" {
+			t.Errorf("Unexpected form model=%q language=%q", r.FormValue("model"), r.FormValue("language"))
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Fatalf("missing file: %v", err)
+		}
+		data, _ := io.ReadAll(file)
+		if string(data) != "voice" || header.Filename != "audio.mp3" {
+			t.Errorf("Unexpected upload %s %q", header.Filename, data)
+		}
+		fmt.Fprint(w, `{"text":" good morning ","language":"english","segments":[{"start":0.2,"end":1,"text":"good morning"}]}`)
+	}))
+	defer server.Close()
+
+	client := NewOpenAICompatibleClient("", server.URL, "llama3")
+	got, err := client.Transcribe(context.Background(), OmniMedia{Mime: "audio/mpeg", DataBase64: ToBase64([]byte("voice"))}, "en")
+	if err != nil {
+		t.Fatalf("Transcribe() error = %v", err)
+	}
+	if got.Text != "good morning" || got.Language != "english" {
+		t.Errorf("Unexpected transcription %+v", got)
+	}
+	if len(got.Segments) != 1 || got.Segments[0].Start != 200*time.Millisecond {
+		t.Errorf("Unexpected segments %+v", got.Segments)
+	}
+}
+
+func TestTranscribeRejectsEmptyAudio(t *testing.T) {
+	client := NewClient("test-key", "http://127.0.0.1:0", "qwen-plus")
+	if _, err := client.Transcribe(context.Background(), OmniMedia{Mime: "audio/ogg"}, ""); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Expected ErrBadRequest, got %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// DefaultTranslationModel is the DashScope machine-translation model used by Translate
+const DefaultTranslationModel = "qwen-mt-turbo"
+
+// TranslationTerm pins the translation of a term (a glossary entry)
+type TranslationTerm struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// TranslateRequest describes a translation.
+// Languages are ISO codes ("id", "en") or English names ("Indonesian"); an empty SourceLang is detected.
+type TranslateRequest struct {
+	Text       string
+	SourceLang string
+	TargetLang string
+	// Terms is a glossary applied to the translation
+	Terms []TranslationTerm
+	// Domains describes the field and style of the text in English, such as "legal contracts, formal"
+	Domains string
+}
+
+// Translation is the result of Translate
+type Translation struct {
+	Text       string
+	SourceLang string
+	TargetLang string
+}
+
+// translationOptions is DashScope's translation_options
+type translationOptions struct {
+	SourceLang string            `json:"source_lang"`
+	TargetLang string            `json:"target_lang"`
+	Terms      []TranslationTerm `json:"terms,omitempty"`
+	Domains    string            `json:"domains,omitempty"`
+}
+
+// translationResponse is a non-streaming chat completion
+type translationResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+	Usage *QwenUsage `json:"usage,omitempty"`
+}
+
+// languageNames maps ISO 639-1 codes to the names qwen-mt expects
+var languageNames = map[string]string{
+	"ar": "Arabic",
+	"de": "German",
+	"en": "English",
+	"es": "Spanish",
+	"fr": "French",
+	"hi": "Hindi",
+	"id": "Indonesian",
+	"it": "Italian",
+	"ja": "Japanese",
+	"jv": "Javanese",
+	"ko": "Korean",
+	"ms": "Malay",
+	"nl": "Dutch",
+	"pt": "Portuguese",
+	"ru": "Russian",
+	"th": "Thai",
+	"tl": "Tagalog",
+	"tr": "Turkish",
+	"vi": "Vietnamese",
+	"zh": "Chinese",
+}
+
+// LanguageName returns the English name for an ISO code ("id" -> "Indonesian"), or lang unchanged
+func LanguageName(lang string) string {
+	lang = strings.TrimSpace(lang)
+	code, _, _ := strings.Cut(strings.ToLower(lang), "-")
+	if name, ok := languageNames[code]; ok {
+		return name
+	}
+	return lang
+}
+
+// Translate translates req.Text with the translation model (SetTranslationModel).
+// DashScope clients send translation_options; OpenAI-compatible servers get an instruction prompt instead.
+func (c *Client) Translate(ctx context.Context, req TranslateRequest) (*Translation, error) {
+	if strings.TrimSpace(req.Text) == "" {
+		return nil, fmt.Errorf("%w: empty text", ErrBadRequest)
+	}
+	if strings.TrimSpace(req.TargetLang) == "" {
+		return nil, fmt.Errorf("%w: missing target language", ErrBadRequest)
+	}
+
+	out := &Translation{SourceLang: LanguageName(req.SourceLang), TargetLang: LanguageName(req.TargetLang)}
+	if out.SourceLang == "" {
+		out.SourceLang = "auto"
+	}
+
+	// OpenAI-compatible servers translate with the chat model
+	fallback := c.translationModel
+	if c.compatible {
+		fallback = c.Model
+	}
+	ctx, model := c.route(ctx, TaskTranslation, fallback)
+
+	if c.compatible {
+		text, err := c.Chat(ctx, []Message{
+			{Role: "system", Content: translationPrompt(out.SourceLang, out.TargetLang, req.Terms, req.Domains)},
+			{Role: "user", Content: req.Text},
+		})
+		if err != nil {
+			return nil, err
+		}
+		out.Text = strings.TrimSpace(text)
+		return out, nil
+	}
+
+	if err := c.require(model, CapabilityTranslation, CapabilityNonStream); err != nil {
+		return nil, err
+	}
+
+	// qwen-mt takes a single user message and no system prompt. Its stream repeats the full
+	// text in every chunk instead of sending deltas, so the request is not streamed.
+	body := map[string]any{
+		"model":    model,
+		"messages": []map[string]string{{"role": "user", "content": req.Text}},
+		"translation_options": translationOptions{
+			SourceLang: out.SourceLang,
+			TargetLang: out.TargetLang,
+			Terms:      req.Terms,
+			Domains:    req.Domains,
+		},
+	}
+
+	var resp translationResponse
+	err := c.do(ctx, "translation request", model, true, func(ep Endpoint) error {
+		return postJSON(ctx, ep.BaseURL+"/chat/completions", ep.APIKey, body, &resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Usage != nil {
+		usage := resp.Usage.toUsage()
+		usage.Model = resp.Model
+		recordUsage(ctx, c.usage, usage, model)
+	}
+	if len(resp.Choices) == 0 || strings.TrimSpace(resp.Choices[0].Message.Content) == "" {
+		return nil, fmt.Errorf("empty translation response")
+	}
+
+	out.Text = strings.TrimSpace(resp.Choices[0].Message.Content)
+	return out, nil
+}
+
+// translationPrompt instructs a general chat model to behave like qwen-mt
+func translationPrompt(source, target string, terms []TranslationTerm, domains string) string {
+	var b strings.Builder
+	if source == "auto" {
+		fmt.Fprintf(&b, "Translate the user's text into %s.", target)
+	} else {
+		fmt.Fprintf(&b, "Translate the user's text from %s into %s.", source, target)
+	}
+	b.WriteString(" Output only the translation, keeping the formatting, without explanations.")
+	if domains != "" {
+		fmt.Fprintf(&b, "\nDomain and style: %s", domains)
+	}
+	if len(terms) > 0 {
+		b.WriteString("\nAlways translate these terms as given:")
+		for _, t := range terms {
+			fmt.Fprintf(&b, "\n- %s => %s", t.Source, t.Target)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,120 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLanguageName(t *testing.T) {
+	tests := map[string]string{
+		"id":       "Indonesian",
+		"EN":       "English",
+		"zh-CN":    "Chinese",
+		"Japanese": "Japanese",
+		"":         "",
+	}
+	for in, want := range tests {
+		if got := LanguageName(in); got != want {
+			t.Errorf("LanguageName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTranslateSendsTranslationOptions(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		json.Unmarshal(raw, &body)
+		fmt.Fprint(w, `{"model":"qwen-mt-turbo","choices":[{"message":{"role":"assistant","content":" Good morning, Budi "}}],"usage":{"prompt_tokens":20,"completion_tokens":5,"total_tokens":25}}`)
+	}))
+	defer server.Close()
+
+	var recorded []Usage
+	client := NewClient("test-key", server.URL, "qwen-plus")
+	client.SetUsageRecorder(UsageRecorderFunc(func(ctx context.Context, u Usage) { recorded = append(recorded, u) }))
+
+	got, err := client.Translate(context.Background(), TranslateRequest{
+		Text:       "Selamat pagi, Budi",
+		TargetLang: "en",
+		Terms:      []TranslationTerm{{Source: "Budi", Target: "Budi"}},
+		Domains:    "casual chat",
+	})
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	want := &Translation{Text: "Good morning, Budi", SourceLang: "auto", TargetLang: "English"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Translate() = %+v, want %+v", got, want)
+	}
+
+	if body["model"] != DefaultTranslationModel {
+		t.Errorf("Expected model %s, got %v", DefaultTranslationModel, body["model"])
+	}
+	if _, ok := body["stream"]; ok {
+		t.Error("Translation requests must not stream")
+	}
+	options, _ := body["translation_options"].(map[string]any)
+	if options["source_lang"] != "auto" || options["target_lang"] != "English" || options["domains"] != "casual chat" {
+		t.Errorf("Unexpected translation_options %v", options)
+	}
+	if terms, _ := options["terms"].([]any); len(terms) != 1 {
+		t.Errorf("Expected the glossary term, got %v", options["terms"])
+	}
+	messages, _ := body["messages"].([]any)
+	if len(messages) != 1 || messages[0].(map[string]any)["role"] != "user" {
+		t.Errorf("qwen-mt takes a single user message, got %v", messages)
+	}
+
+	if len(recorded) != 1 || recorded[0].TotalTokens != 25 || recorded[0].Model != "qwen-mt-turbo" {
+		t.Errorf("Unexpected recorded usage %+v", recorded)
+	}
+}
+
+func TestTranslateCompatiblePrompt(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	server := captureServer(t, bodies)
+	client := NewOpenAICompatibleClient("", server.URL, "llama3")
+
+	got, err := client.Translate(context.Background(), TranslateRequest{
+		Text:       "hello",
+		SourceLang: "en",
+		TargetLang: "id",
+		Terms:      []TranslationTerm{{Source: "bot", Target: "bot"}},
+	})
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	if got.Text != "ok" {
+		t.Errorf("Expected the model's answer, got %q", got.Text)
+	}
+
+	var body struct {
+		Messages           []Message       `json:"messages"`
+		TranslationOptions json.RawMessage `json:"translation_options"`
+	}
+	json.Unmarshal(<-bodies, &body)
+	if body.TranslationOptions != nil {
+		t.Error("OpenAI-compatible servers do not understand translation_options")
+	}
+	prompt := body.Messages[0].Content
+	if !strings.Contains(prompt, "from English into Indonesian") || !strings.Contains(prompt, "bot => bot") {
+		t.Errorf("Expected instruction prompt with glossary, got %q", prompt)
+	}
+}
+
+func TestTranslateValidates(t *testing.T) {
+	client := NewClient("test-key", "http://127.0.0.1:0", "qwen-plus")
+	for _, req := range []TranslateRequest{{Text: " ", TargetLang: "en"}, {Text: "halo"}} {
+		if _, err := client.Translate(context.Background(), req); !errors.Is(err, ErrBadRequest) {
+			t.Errorf("Translate(%+v) error = %v, want ErrBadRequest", req, err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package ai
+
+import "context"
+
+// UsageRecorder receives the token usage of every upstream call that reported one.
+// RecordUsage is called from the streaming goroutine and must not block for long.
+type UsageRecorder interface {
+	RecordUsage(ctx context.Context, usage Usage)
+}
+
+// UsageRecorderFunc adapts a function to UsageRecorder
+type UsageRecorderFunc func(ctx context.Context, usage Usage)
+
+// RecordUsage calls f(ctx, usage)
+func (f UsageRecorderFunc) RecordUsage(ctx context.Context, usage Usage) {
+	f(ctx, usage)
+}
+
+// UsageTag attributes the usage of a request to a user and a purpose (chat, memory, ...)
+type UsageTag struct {
+	UserID  string
+	Purpose string
+}
+
+// Purposes used by the application when tagging requests
+const (
+	PurposeChat   = "chat"
+	PurposeMemory = "memory"
+	PurposeSpeech = "speech"
+	// PurposeTranscription is speech-to-text of voice notes and audio uploads
+	PurposeTranscription = "transcription"
+	PurposeTranslation   = "translation"
+	// PurposeSummary is the rolling summary of old conversation turns
+	PurposeSummary   = "summary"
+	PurposeEmbedding = "embedding"
+)
+
+type usageTagKey struct{}
+
+// WithUsageTag returns a context whose requests are attributed to tag
+func WithUsageTag(ctx context.Context, tag UsageTag) context.Context {
+	return context.WithValue(ctx, usageTagKey{}, tag)
+}
+
+// UsageTagFrom returns the tag stored by WithUsageTag
+func UsageTagFrom(ctx context.Context) (UsageTag, bool) {
+	tag, ok := ctx.Value(usageTagKey{}).(UsageTag)
+	return tag, ok
+}
+
+// recordUsage forwards usage to recorder when both are set, defaulting the model name
+func recordUsage(ctx context.Context, recorder UsageRecorder, usage *Usage, model string) {
+	if recorder == nil || usage == nil {
+		return
+	}
+	u := *usage
+	if u.Model == "" {
+		u.Model = model
+	}
+	recorder.RecordUsage(ctx, u)
+}
+
+// MultiUsageRecorder reports usage to every non-nil recorder in order
+func MultiUsageRecorder(recorders ...UsageRecorder) UsageRecorder {
+	var active []UsageRecorder
+	for _, r := range recorders {
+		if r != nil {
+			active = append(active, r)
+		}
+	}
+	return UsageRecorderFunc(func(ctx context.Context, usage Usage) {
+		for _, r := range active {
+			r.RecordUsage(ctx, usage)
+		}
+	})
+}
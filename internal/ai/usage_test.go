@@ -0,0 +1,107 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// usageServer streams a short answer followed by a usage chunk and records whether stream_options was sent
+func usageServer(t *testing.T, includeUsage *bool) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		*includeUsage = strings.Contains(string(body), `"stream_options":{"include_usage":true}`)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"model":"qwen-plus-2025","choices":[{"delta":{"content":"ok"},"finish_reason":"stop"}]}`+"\n\n")
+		fmt.Fprint(w, `data: {"model":"qwen-plus-2025","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":30,"total_tokens":42,"completion_tokens_details":{"reasoning_tokens":20}}}`+"\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+type recordedUsage struct {
+	tag   UsageTag
+	usage Usage
+}
+
+func TestUsageIsRecorded(t *testing.T) {
+	tests := []struct {
+		name   string
+		client func(url string) *Client
+		call   func(ctx context.Context, c *Client) error
+	}{
+		{
+			name:   "Qwen thinking stream",
+			client: func(url string) *Client { return NewClient("test-key", url, "qwen-plus") },
+			call: func(ctx context.Context, c *Client) error {
+				_, err := CollectStream(c.ChatStreamWithThinking(ctx, []Message{{Role: "user", Content: "hi"}}))
+				return err
+			},
+		},
+		{
+			name:   "OpenAI-compatible stream",
+			client: func(url string) *Client { return NewOpenAICompatibleClient("test-key", url, "qwen-plus") },
+			call: func(ctx context.Context, c *Client) error {
+				_, err := CollectStream(c.ChatStream(ctx, []Message{{Role: "user", Content: "hi"}}))
+				return err
+			},
+		},
+		{
+			name:   "Chat",
+			client: func(url string) *Client { return NewClient("test-key", url, "qwen-plus") },
+			call: func(ctx context.Context, c *Client) error {
+				_, err := c.Chat(ctx, []Message{{Role: "user", Content: "hi"}})
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var includeUsage bool
+			server := usageServer(t, &includeUsage)
+
+			var mu sync.Mutex
+			var records []recordedUsage
+			client := tt.client(server.URL)
+			client.SetUsageRecorder(UsageRecorderFunc(func(ctx context.Context, usage Usage) {
+				tag, _ := UsageTagFrom(ctx)
+				mu.Lock()
+				records = append(records, recordedUsage{tag: tag, usage: usage})
+				mu.Unlock()
+			}))
+
+			ctx := WithUsageTag(context.Background(), UsageTag{UserID: "42", Purpose: PurposeChat})
+			if err := tt.call(ctx, client); err != nil {
+				t.Fatalf("call error = %v", err)
+			}
+
+			if !includeUsage {
+				t.Error("Request did not ask for usage with stream_options")
+			}
+
+			want := recordedUsage{
+				tag: UsageTag{UserID: "42", Purpose: PurposeChat},
+				usage: Usage{
+					Model:            "qwen-plus-2025",
+					PromptTokens:     12,
+					CompletionTokens: 30,
+					ReasoningTokens:  20,
+					TotalTokens:      42,
+				},
+			}
+			if len(records) != 1 || records[0] != want {
+				t.Errorf("Recorded usage = %+v, want [%+v]", records, want)
+			}
+		})
+	}
+}
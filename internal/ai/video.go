@@ -0,0 +1,239 @@
+package ai
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OmniVideo is a video for an omni request: a public URL, uploaded bytes or an ordered frame sequence
+type OmniVideo struct {
+	// URL is sent as is when set
+	URL string
+	// Mime and DataBase64 hold an uploaded file, sent inline or as sampled frames (see VideoOptions.Mode)
+	Mime       string
+	DataBase64 string
+	// Frames are already sampled images, sent in order
+	Frames []OmniMedia
+	// Duration is the length of the upload if known (Telegram reports it); 0 = unknown
+	Duration time.Duration
+}
+
+// Video sending modes
+const (
+	// VideoModeAuto sends uploads inline to models that accept video data and as frames otherwise
+	VideoModeAuto   = "auto"
+	VideoModeInline = "inline"
+	VideoModeFrames = "frames"
+)
+
+// VideoOptions controls how uploaded videos are sent and sampled
+type VideoOptions struct {
+	Mode string
+	// FPS is the frame sampling rate
+	FPS float64
+	// MaxFrames caps the sequence; longer samplings are thinned evenly
+	MaxFrames int
+	// MaxDuration rejects longer uploads of known duration; others are cut off there
+	MaxDuration time.Duration
+	// MaxInlineBytes is the largest upload sent inline; in auto mode larger ones are sent as frames
+	MaxInlineBytes int
+}
+
+// DefaultVideoOptions samples one frame per second of up to two minutes
+var DefaultVideoOptions = VideoOptions{
+	Mode:           VideoModeAuto,
+	FPS:            1,
+	MaxFrames:      32,
+	MaxDuration:    2 * time.Minute,
+	MaxInlineBytes: 10 << 20,
+}
+
+// minVideoFrames is the shortest image sequence DashScope accepts as a video
+const minVideoFrames = 4
+
+// FrameExtractor samples JPEG frames from a video file
+type FrameExtractor interface {
+	ExtractFrames(ctx context.Context, video []byte, mime string, opts VideoOptions) ([][]byte, error)
+}
+
+// FFmpegExtractor extracts frames with the ffmpeg binary at Path ("ffmpeg" if empty)
+type FFmpegExtractor struct {
+	Path string
+}
+
+// ExtractFrames writes video to a temporary file and samples it at opts.FPS up to opts.MaxDuration
+func (e FFmpegExtractor) ExtractFrames(ctx context.Context, video []byte, mime string, opts VideoOptions) ([][]byte, error) {
+	path := e.Path
+	if path == "" {
+		path = "ffmpeg"
+	}
+
+	dir, err := os.MkdirTemp("", "omni-video-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "input"+videoExtension(mime))
+	if err := os.WriteFile(input, video, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write video: %w", err)
+	}
+
+	args := []string{"-hide_banner", "-loglevel", "error", "-i", input}
+	if opts.MaxDuration > 0 {
+		args = append(args, "-t", strconv.FormatFloat(opts.MaxDuration.Seconds(), 'f', -1, 64))
+	}
+	args = append(args,
+		"-vf", "fps="+strconv.FormatFloat(opts.FPS, 'f', -1, 64),
+		"-q:v", "3",
+		filepath.Join(dir, "frame_%05d.jpg"),
+	)
+
+	out, err := exec.CommandContext(ctx, path, args...).CombinedOutput()
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("frame extraction needs ffmpeg: %w", ErrNotSupported)
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%w: ffmpeg could not read the video: %s", ErrBadRequest, strings.TrimSpace(string(out)))
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read frames: %w", err)
+	}
+	var frames [][]byte
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "frame_") {
+			continue
+		}
+		frame, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read frame: %w", err)
+		}
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+// prepareVideo turns an upload into an inline data URL or a frame sequence for model
+func (c *Client) prepareVideo(ctx context.Context, model string, video *OmniVideo) (*OmniVideo, error) {
+	if video == nil || video.URL != "" {
+		return video, nil
+	}
+	if len(video.Frames) > 0 {
+		frames, err := c.prepareImages(video.Frames)
+		if err != nil {
+			return nil, err
+		}
+		return &OmniVideo{Frames: padFrames(thinFrames(frames, c.video.MaxFrames))}, nil
+	}
+	if video.DataBase64 == "" {
+		return nil, nil
+	}
+
+	opts := c.video
+	if opts.MaxDuration > 0 && video.Duration > opts.MaxDuration {
+		return nil, fmt.Errorf("%w: video of %s exceeds %s", ErrBadRequest, video.Duration, opts.MaxDuration)
+	}
+
+	size := base64.StdEncoding.DecodedLen(len(video.DataBase64))
+	inline := size <= opts.MaxInlineBytes || opts.MaxInlineBytes <= 0
+	switch opts.Mode {
+	case VideoModeInline:
+		if !inline {
+			return nil, fmt.Errorf("%w: video of %d bytes exceeds the inline limit of %d", ErrBadRequest, size, opts.MaxInlineBytes)
+		}
+		return inlineVideo(video), nil
+	case VideoModeFrames:
+	default:
+		if inline && acceptsInlineVideo(model) {
+			return inlineVideo(video), nil
+		}
+	}
+
+	data, err := base64.StdEncoding.DecodeString(video.DataBase64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid base64 video: %v", ErrBadRequest, err)
+	}
+	raw, err := c.frames.ExtractFrames(ctx, data, video.Mime, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("%w: no frames could be extracted from the video", ErrBadRequest)
+	}
+
+	raw = thinFrames(raw, opts.MaxFrames)
+	frames := make([]OmniMedia, 0, len(raw))
+	for _, frame := range raw {
+		media, err := PrepareImage(frame, c.images)
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, media)
+	}
+	return &OmniVideo{Frames: padFrames(frames)}, nil
+}
+
+// acceptsInlineVideo reports whether model takes base64 video data rather than frame sequences
+func acceptsInlineVideo(model string) bool {
+	caps, _ := LookupModel(model)
+	return caps.InlineVideo
+}
+
+func inlineVideo(video *OmniVideo) *OmniVideo {
+	mime := video.Mime
+	if mime == "" {
+		mime = "video/mp4"
+	}
+	return &OmniVideo{URL: fmt.Sprintf("data:%s;base64,%s", mime, video.DataBase64)}
+}
+
+// thinFrames keeps at most limit frames spread evenly over the sequence, first and last included
+func thinFrames[T any](frames []T, limit int) []T {
+	if limit <= 0 || len(frames) <= limit {
+		return frames
+	}
+	if limit == 1 {
+		return frames[:1]
+	}
+
+	out := make([]T, limit)
+	for i := range out {
+		out[i] = frames[i*(len(frames)-1)/(limit-1)]
+	}
+	return out
+}
+
+// padFrames repeats the last frame of very short clips up to minVideoFrames
+func padFrames(frames []OmniMedia) []OmniMedia {
+	for len(frames) > 0 && len(frames) < minVideoFrames {
+		frames = append(frames, frames[len(frames)-1])
+	}
+	return frames
+}
+
+// videoExtension helps ffmpeg pick a demuxer for a MIME type
+func videoExtension(mime string) string {
+	switch mime {
+	case "video/quicktime":
+		return ".mov"
+	case "video/webm":
+		return ".webm"
+	case "image/gif":
+		return ".gif"
+	default:
+		return ".mp4"
+	}
+}
@@ -0,0 +1,130 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubExtractor returns n copies of a JPEG fixture and records the options it was called with
+type stubExtractor struct {
+	frame []byte
+	n     int
+	opts  VideoOptions
+	calls int
+}
+
+func (s *stubExtractor) ExtractFrames(ctx context.Context, video []byte, mime string, opts VideoOptions) ([][]byte, error) {
+	s.calls++
+	s.opts = opts
+	frames := make([][]byte, s.n)
+	for i := range frames {
+		frames[i] = s.frame
+	}
+	return frames, nil
+}
+
+// videoParts returns the video content parts of a captured omni request
+func videoParts(t *testing.T, body map[string]any) []map[string]any {
+	t.Helper()
+	var parts []map[string]any
+	messages, _ := body["messages"].([]any)
+	for _, m := range messages {
+		content, _ := m.(map[string]any)["content"].([]any)
+		for _, c := range content {
+			part := c.(map[string]any)
+			if part["type"] == "video" || part["type"] == "input_video" {
+				parts = append(parts, part)
+			}
+		}
+	}
+	return parts
+}
+
+func TestChatOmniVideoInlineForOmniModels(t *testing.T) {
+	bodies := make(chan map[string]any, 1)
+	server := omniServer(t, bodies)
+	client := NewClient("test-key", server.URL, "qwen-omni-turbo")
+	extractor := &stubExtractor{}
+	client.SetFrameExtractor(extractor)
+
+	video := &OmniVideo{Mime: "video/mp4", DataBase64: ToBase64([]byte("fake mp4")), Duration: 5 * time.Second}
+	if _, err := client.ChatOmni(context.Background(), "", "what happens?", nil, nil, video, false); err != nil {
+		t.Fatalf("ChatOmni() error = %v", err)
+	}
+
+	parts := videoParts(t, <-bodies)
+	if len(parts) != 1 || parts[0]["type"] != "input_video" {
+		t.Fatalf("Expected one inline video part, got %v", parts)
+	}
+	url := parts[0]["video_url"].(map[string]any)["url"].(string)
+	if !strings.HasPrefix(url, "data:video/mp4;base64,") {
+		t.Errorf("Expected a data URL, got %q", url)
+	}
+	if extractor.calls != 0 {
+		t.Error("Inline video must not be sampled")
+	}
+}
+
+func TestChatOmniVideoFrames(t *testing.T) {
+	bodies := make(chan map[string]any, 1)
+	server := omniServer(t, bodies)
+	client := NewClient("test-key", server.URL, "qwen-vl-max")
+	extractor := &stubExtractor{frame: readFixture(t, "photo-exif-gps.jpg"), n: 40}
+	client.SetFrameExtractor(extractor)
+	client.SetVideoOptions(VideoOptions{Mode: VideoModeAuto, FPS: 2, MaxFrames: 8, MaxDuration: time.Minute, MaxInlineBytes: 1 << 20})
+
+	video := &OmniVideo{Mime: "video/mp4", DataBase64: ToBase64([]byte("fake mp4"))}
+	if _, err := client.ChatOmni(context.Background(), "", "what happens?", nil, nil, video, false); err != nil {
+		t.Fatalf("ChatOmni() error = %v", err)
+	}
+
+	if extractor.opts.FPS != 2 || extractor.opts.MaxDuration != time.Minute {
+		t.Errorf("Extractor got options %+v", extractor.opts)
+	}
+	parts := videoParts(t, <-bodies)
+	if len(parts) != 1 || parts[0]["type"] != "video" {
+		t.Fatalf("Expected one frame-sequence part, got %v", parts)
+	}
+	frames := parts[0]["video"].([]any)
+	if len(frames) != 8 {
+		t.Errorf("Expected frames thinned to 8, got %d", len(frames))
+	}
+	if !strings.HasPrefix(frames[0].(string), "data:image/jpeg;base64,") {
+		t.Errorf("Expected JPEG data URLs, got %.40q", frames[0])
+	}
+}
+
+func TestChatOmniVideoLimits(t *testing.T) {
+	client := NewClient("test-key", "http://127.0.0.1:0", "qwen-omni-turbo")
+	client.SetFrameExtractor(&stubExtractor{})
+
+	long := &OmniVideo{Mime: "video/mp4", DataBase64: ToBase64([]byte("x")), Duration: 10 * time.Minute}
+	if _, err := client.ChatOmni(context.Background(), "", "hi", nil, nil, long, false); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Expected ErrBadRequest for an overlong video, got %v", err)
+	}
+
+	client.SetVideoOptions(VideoOptions{Mode: VideoModeInline, MaxInlineBytes: 4})
+	big := &OmniVideo{Mime: "video/mp4", DataBase64: ToBase64([]byte("larger than four bytes"))}
+	if _, err := client.ChatOmni(context.Background(), "", "hi", nil, nil, big, false); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Expected ErrBadRequest above the inline limit, got %v", err)
+	}
+}
+
+func TestThinAndPadFrames(t *testing.T) {
+	frames := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	if got := thinFrames(frames, 4); !reflect.DeepEqual(got, []int{0, 3, 6, 9}) {
+		t.Errorf("thinFrames() = %v", got)
+	}
+	if got := thinFrames(frames, 20); len(got) != 10 {
+		t.Errorf("thinFrames() below the limit should keep all frames, got %v", got)
+	}
+
+	padded := padFrames([]OmniMedia{{Mime: "a"}, {Mime: "b"}})
+	if len(padded) != minVideoFrames || padded[3].Mime != "b" {
+		t.Errorf("padFrames() = %v", padded)
+	}
+}
@@ -0,0 +1,123 @@
+package bot
+
+import (
+	"Qwen/internal/knowledge"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleDocument adds an uploaded file to the user's knowledge base, then answers the caption if there is one
+func (h *Handler) handleDocument(msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	if h.knowledge == nil {
+		h.reply(chatID, "⚠️ Fitur dokumen tidak aktif karena database belum dikonfigurasi.")
+		return
+	}
+	userID := strconv.FormatInt(msg.From.ID, 10)
+	file := msg.Document
+
+	if file.FileSize > maxDownloadBytes {
+		h.reply(chatID, "📦 File terlalu besar. Ukuran maksimum 20 MB.")
+		return
+	}
+	// Unsupported files are turned away before they use the sender's quota
+	if _, err := knowledge.DetectFormat(file.FileName, file.MimeType, nil); err != nil {
+		h.reply(chatID, documentErrorReply(err))
+		return
+	}
+	if !h.allow(msg) {
+		return
+	}
+
+	h.bot.Request(tgbotapi.NewChatAction(chatID, tgbotapi.ChatTyping))
+
+	data, err := h.downloadFile(h.ctx, file.FileID)
+	if err != nil {
+		log.Printf("❌ Failed to download document: %v", err)
+		h.reply(chatID, "❌ Gagal mengunduh dokumen. Coba kirim ulang.")
+		return
+	}
+
+	doc, err := h.knowledge.Ingest(h.ctx, userID, file.FileName, file.MimeType, data)
+	if err != nil {
+		log.Printf("❌ Document error for user %s: %v", userID, err)
+		h.bot.Send(tgbotapi.NewMessage(chatID, documentErrorReply(err)))
+		return
+	}
+	h.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("📚 %s tersimpan (%d bagian). Tanyakan apa saja tentang isinya, atau lihat semua dokumen dengan /docs.", doc.Name, doc.Chunks)))
+
+	if caption := strings.TrimSpace(msg.Caption); caption != "" {
+		h.chat(msg, caption)
+	}
+}
+
+// documentErrorReply explains why a document could not be added
+func documentErrorReply(err error) string {
+	switch {
+	case errors.Is(err, knowledge.ErrUnsupportedFormat):
+		return "📄 Format dokumen belum didukung. Kirim file .txt, .md, atau .pdf (tanpa password)."
+	case errors.Is(err, knowledge.ErrNoText):
+		return "📄 Tidak ada teks yang bisa dibaca dari dokumen itu. PDF hasil scan belum didukung."
+	case errors.Is(err, knowledge.ErrTooLarge):
+		return "📦 Dokumen terlalu besar untuk disimpan."
+	case errors.Is(err, knowledge.ErrLimitReached):
+		return "🗂️ Dokumenmu sudah terlalu banyak. Hapus yang tidak dipakai dengan /docs hapus <id>."
+	default:
+		return errorReply(err)
+	}
+}
+
+// handleDocs lists the user's documents or removes one ("hapus <id>")
+func (h *Handler) handleDocs(msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	if h.knowledge == nil {
+		h.reply(chatID, "⚠️ Fitur dokumen tidak aktif karena database belum dikonfigurasi.")
+		return
+	}
+	userID := strconv.FormatInt(msg.From.ID, 10)
+	args := strings.TrimSpace(msg.CommandArguments())
+
+	if args == "" {
+		docs, err := h.knowledge.Documents(userID)
+		if err != nil {
+			log.Printf("❌ Error loading documents: %v", err)
+			h.reply(chatID, "❌ Gagal memuat dokumen. Coba lagi nanti.")
+			return
+		}
+		if len(docs) == 0 {
+			h.bot.Send(tgbotapi.NewMessage(chatID, "📚 Belum ada dokumen.\nKirim file .txt, .md, atau .pdf untuk menambahkannya."))
+			return
+		}
+		var b strings.Builder
+		b.WriteString("📚 Dokumen kamu:\n")
+		for _, doc := range docs {
+			fmt.Fprintf(&b, "\n%d. %s (%d bagian, %s)", doc.ID, doc.Name, doc.Chunks, doc.CreatedAt.Format("2006-01-02"))
+		}
+		b.WriteString("\n\nHapus dengan /docs hapus <id>")
+		h.bot.Send(tgbotapi.NewMessage(chatID, b.String()))
+		return
+	}
+
+	command, arg, _ := strings.Cut(args, " ")
+	id, err := strconv.ParseInt(strings.TrimSpace(arg), 10, 64)
+	if (command != "hapus" && command != "remove") || err != nil {
+		h.reply(chatID, "Cara pakai: /docs untuk melihat dokumen, atau /docs hapus <id>.")
+		return
+	}
+	removed, err := h.knowledge.Delete(userID, id)
+	if err != nil {
+		log.Printf("❌ Error removing document: %v", err)
+		h.reply(chatID, "❌ Gagal menghapus dokumen. Coba lagi nanti.")
+		return
+	}
+	if !removed {
+		h.bot.Send(tgbotapi.NewMessage(chatID, "Dokumen itu tidak ada."))
+		return
+	}
+	h.bot.Send(tgbotapi.NewMessage(chatID, "🗑️ Dokumen dihapus."))
+}
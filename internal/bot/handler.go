@@ -0,0 +1,489 @@
+package bot
+
+import (
+	"Qwen/internal/ai"
+	"Qwen/internal/database"
+	"Qwen/internal/knowledge"
+	"Qwen/internal/memory"
+	"Qwen/internal/prompt"
+	"Qwen/internal/quota"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// Telegram rejects messages longer than 4096 UTF-16 code units, so emoji and other
+	// characters outside the BMP count twice
+	maxMessageLength = 4096
+	// Minimum delay between two edits of the same streaming message
+	editInterval = time.Second
+	// Number of previous turns loaded as conversation context; the context builder
+	// drops or summarizes the oldest ones to fit the model's token budget
+	contextMessages = 20
+	// How long Stop waits for in-flight replies before cancelling them
+	drainTimeout = 30 * time.Second
+)
+
+// Handler receives Telegram updates and answers them with the AI client
+type Handler struct {
+	bot           *tgbotapi.BotAPI
+	aiClient      ai.LLM
+	convService   *database.ConversationService
+	memoryService *memory.MemoryService
+	// glossary is optional; nil disables /glossary
+	glossary *database.GlossaryService
+	// limiter is optional; nil disables quotas
+	limiter *quota.Limiter
+	// contextBuilder is optional; nil sends every loaded turn
+	contextBuilder *ai.ContextBuilder
+	// retriever is optional; nil recalls no earlier turns
+	retriever *ai.Retriever
+	// knowledge is optional; nil disables documents and /docs
+	knowledge *knowledge.Base
+	// prompts renders the system prompt of the user's persona; nil uses the built-in templates
+	prompts *prompt.Library
+
+	// ctx is cancelled when in-flight replies fail to drain in time
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewHandler creates a new Telegram bot handler.
+// convService, memoryService, glossary, limiter, contextBuilder, retriever, knowledgeBase and prompts are optional and may be nil.
+func NewHandler(token string, aiClient ai.LLM, convService *database.ConversationService, memoryService *memory.MemoryService, glossary *database.GlossaryService, limiter *quota.Limiter, contextBuilder *ai.ContextBuilder, retriever *ai.Retriever, knowledgeBase *knowledge.Base, prompts *prompt.Library) (*Handler, error) {
+	bot, err := tgbotapi.NewBotAPI(token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bot API: %w", err)
+	}
+
+	log.Printf("Authorized on account %s", bot.Self.UserName)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Handler{
+		bot:            bot,
+		aiClient:       aiClient,
+		convService:    convService,
+		memoryService:  memoryService,
+		glossary:       glossary,
+		limiter:        limiter,
+		contextBuilder: contextBuilder,
+		retriever:      retriever,
+		knowledge:      knowledgeBase,
+		prompts:        prompts,
+		ctx:            ctx,
+		cancel:         cancel,
+		done:           make(chan struct{}),
+	}, nil
+}
+
+// Start long-polls Telegram for updates until Stop is called
+func (h *Handler) Start() error {
+	defer close(h.done)
+
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = 60
+
+	updates := h.bot.GetUpdatesChan(u)
+	for update := range updates {
+		if update.Message == nil {
+			continue
+		}
+
+		h.wg.Add(1)
+		go func(msg *tgbotapi.Message) {
+			defer h.wg.Done()
+			h.handleMessage(msg)
+		}(update.Message)
+	}
+
+	return nil
+}
+
+// Stop stops receiving updates and waits for in-flight replies to finish
+func (h *Handler) Stop() {
+	h.stopOnce.Do(func() {
+		h.bot.StopReceivingUpdates()
+	})
+
+	// The update channel is only closed after the current long poll returns
+	select {
+	case <-h.done:
+	case <-time.After(70 * time.Second):
+		log.Println("Timed out waiting for update loop to stop")
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-time.After(drainTimeout):
+		log.Println("Timed out draining replies, cancelling in-flight requests")
+		h.cancel()
+		<-drained
+	}
+	h.cancel()
+}
+
+func (h *Handler) handleMessage(msg *tgbotapi.Message) {
+	if msg.From == nil {
+		return
+	}
+
+	log.Printf("[%s] %s", msg.From.UserName, msg.Text)
+
+	if msg.IsCommand() {
+		h.handleCommand(msg)
+		return
+	}
+
+	if msg.Voice != nil || msg.Audio != nil {
+		h.handleVoiceNote(msg)
+		return
+	}
+
+	if msg.Video != nil || msg.VideoNote != nil {
+		h.handleVideo(msg)
+		return
+	}
+
+	// Telegram also sets Document on GIFs (Animation), which are not documents
+	if msg.Document != nil && msg.Animation == nil {
+		h.handleDocument(msg)
+		return
+	}
+
+	if strings.TrimSpace(msg.Text) == "" {
+		return
+	}
+
+	h.handleChat(msg)
+}
+
+func (h *Handler) handleCommand(msg *tgbotapi.Message) {
+	switch msg.Command() {
+	case "start":
+		name := msg.From.FirstName
+		if name == "" {
+			name = msg.From.UserName
+		}
+		h.reply(msg.Chat.ID, fmt.Sprintf("Halo %s! 👋\n\nAku asisten AI berbasis Qwen. Kirim pesan apa saja dan aku akan membalas secara real-time.\n\nKetik /help untuk melihat perintah yang tersedia.", name))
+	case "help":
+		h.reply(msg.Chat.ID, helpText)
+	case "resetmemory":
+		if h.memoryService == nil {
+			h.reply(msg.Chat.ID, "⚠️ Fitur memory tidak aktif karena database belum dikonfigurasi.")
+			return
+		}
+		if err := h.memoryService.ResetMemory(msg.From.ID); err != nil {
+			log.Printf("❌ Error resetting memory: %v", err)
+			h.reply(msg.Chat.ID, "❌ Gagal menghapus memory. Coba lagi nanti.")
+			return
+		}
+		// Past turns are recalled by the retriever and folded into the summary, so they go too
+		userID := strconv.FormatInt(msg.From.ID, 10)
+		if h.convService != nil {
+			if err := h.convService.DeleteConversations(userID); err != nil {
+				log.Printf("❌ Error deleting conversations: %v", err)
+				h.reply(msg.Chat.ID, "❌ Gagal menghapus riwayat percakapan. Coba lagi nanti.")
+				return
+			}
+		}
+		if err := h.contextBuilder.ResetSummary(userID); err != nil {
+			log.Printf("❌ Error resetting conversation summary: %v", err)
+			h.reply(msg.Chat.ID, "❌ Gagal menghapus ringkasan percakapan. Coba lagi nanti.")
+			return
+		}
+		if n := h.retriever.Forget(userID); n > 0 {
+			log.Printf("🗑️ Forgot %d indexed turn(s) of user %s", n, userID)
+		}
+		h.reply(msg.Chat.ID, "🗑️ Semua memory dan riwayat percakapan tentang kamu sudah dihapus.")
+	case "voice":
+		h.handleVoice(msg)
+	case "speak":
+		h.handleSpeak(msg)
+	case "translate":
+		h.handleTranslate(msg)
+	case "glossary":
+		h.handleGlossary(msg)
+	case "docs":
+		h.handleDocs(msg)
+	case "persona":
+		h.handlePersona(msg)
+	default:
+		h.reply(msg.Chat.ID, "Perintah tidak dikenal. Ketik /help untuk melihat daftar perintah.")
+	}
+}
+
+const helpText = `🤖 *Bantuan*
+
+/start - Memulai percakapan
+/help - Menampilkan pesan bantuan ini
+/resetmemory - Menghapus semua memory/informasi personal dan riwayat percakapan yang tersimpan
+/speak [teks] - Membacakan teks, atau balas sebuah pesan dengan /speak untuk membacakannya
+/voice [suara] [wav|pcm16] [sample rate] - Mengatur suara untuk /speak
+/translate <bahasa> <teks> - Menerjemahkan teks (atau balas sebuah pesan), misalnya /translate en Selamat pagi
+/glossary [istilah = terjemahan | hapus istilah] - Mengatur glossary yang otomatis dipakai saat menerjemahkan
+/docs [hapus id] - Melihat atau menghapus dokumen yang sudah dikirim
+/persona [nama] - Memilih gaya AI: casual, tutor, translator, atau coder
+
+Kirim pesan apa saja untuk mengobrol dengan AI.
+Kirim pesan suara untuk ditranskrip dan dijawab, atau video (dengan caption sebagai pertanyaan) untuk dianalisis.
+Kirim file .txt, .md, atau .pdf untuk disimpan, lalu tanyakan isinya kapan saja.
+Tambahkan /think di akhir pesan untuk mode berpikir, atau /no_think untuk menonaktifkannya.`
+
+// handleChat answers a text message after checking the user's quota
+func (h *Handler) handleChat(msg *tgbotapi.Message) {
+	if !h.allow(msg) {
+		return
+	}
+	h.chat(msg, msg.Text)
+}
+
+// allow checks the quota before any AI call so exhausted users cost nothing, telling them when it fails
+func (h *Handler) allow(msg *tgbotapi.Message) bool {
+	userID := strconv.FormatInt(msg.From.ID, 10)
+	if err := h.limiter.Allow(userID); err != nil {
+		log.Printf("🚫 Quota exceeded for user %s: %v", userID, err)
+		h.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, quota.Reply(err, msg.From.LanguageCode)))
+		return false
+	}
+	return true
+}
+
+// chat streams an AI reply to text into a single Telegram message that is edited as chunks arrive
+func (h *Handler) chat(msg *tgbotapi.Message, text string) {
+	chatID := msg.Chat.ID
+	userID := strconv.FormatInt(msg.From.ID, 10)
+
+	h.bot.Request(tgbotapi.NewChatAction(chatID, tgbotapi.ChatTyping))
+
+	placeholder, err := h.bot.Send(tgbotapi.NewMessage(chatID, "💭 ..."))
+	if err != nil {
+		log.Printf("❌ Failed to send placeholder: %v", err)
+		return
+	}
+
+	stream := &streamingMessage{bot: h.bot, chatID: chatID, messageID: placeholder.MessageID}
+
+	ctx := ai.WithUsageTag(h.ctx, ai.UsageTag{UserID: userID, Purpose: ai.PurposeChat})
+
+	var answer strings.Builder
+	var streamErr error
+	for event := range h.aiClient.ChatStreamWithThinking(ctx, h.buildMessages(ctx, msg, text)) {
+		switch event.Type {
+		case ai.EventAnswer:
+			answer.WriteString(event.Delta)
+			stream.update(answer.String(), false)
+		case ai.EventError:
+			streamErr = event.Err
+		}
+	}
+
+	if streamErr != nil {
+		log.Printf("❌ AI error for user %s: %v", userID, streamErr)
+		if answer.Len() == 0 {
+			stream.update(errorReply(streamErr), true)
+			return
+		}
+	}
+
+	reply := strings.TrimSpace(answer.String())
+	if reply == "" {
+		stream.update("🤔 Maaf, aku tidak punya jawaban untuk itu.", true)
+		return
+	}
+	stream.update(reply, true)
+
+	h.saveTurn(ctx, msg, text, reply)
+
+	if h.memoryService != nil {
+		if _, _, err := h.memoryService.ProcessMessage(msg.From.ID, text); err != nil {
+			log.Printf("❌ Error updating memory: %v", err)
+		}
+	}
+}
+
+// saveTurn stores an exchange and indexes it for retrieval. A turn that was not saved
+// has no ID to index under, so it is skipped.
+func (h *Handler) saveTurn(ctx context.Context, msg *tgbotapi.Message, text, reply string) {
+	if h.convService == nil {
+		return
+	}
+
+	userID := strconv.FormatInt(msg.From.ID, 10)
+	id, err := h.convService.SaveConversation(userID, msg.From.UserName, text, reply)
+	if err != nil {
+		log.Printf("❌ Error saving conversation: %v; not indexing it", err)
+		return
+	}
+	turn := ai.PastTurn{Turn: ai.Turn{ID: id, User: text, Assistant: reply}, UserID: userID, At: time.Now()}
+	if err := h.retriever.Remember(ctx, turn); err != nil {
+		log.Printf("❌ Error indexing conversation: %v", err)
+	}
+}
+
+// errorReply turns an upstream failure into a message for the user
+func errorReply(err error) string {
+	switch {
+	case errors.Is(err, ai.ErrRateLimited):
+		return "⏳ Lagi banyak permintaan nih. Tunggu sebentar lalu coba lagi ya."
+	case errors.Is(err, ai.ErrQuotaExceeded):
+		return "⚠️ Kuota layanan AI sedang habis. Coba lagi nanti."
+	case errors.Is(err, ai.ErrContentFiltered):
+		return "🙏 Maaf, aku tidak bisa memproses pesan itu karena terdeteksi konten yang tidak pantas."
+	case errors.Is(err, ai.ErrNotSupported):
+		return "🚫 Model AI yang dipakai tidak mendukung fitur ini."
+	case errors.Is(err, ai.ErrBadRequest):
+		return "❌ Permintaan tidak bisa diproses. Coba ubah pesanmu."
+	default:
+		return "❌ Maaf, terjadi kesalahan saat memproses pesan kamu. Coba lagi nanti."
+	}
+}
+
+// buildMessages assembles the system prompt of the user's persona with stored memory, relevant earlier turns
+// and document passages, recent conversation turns and the user message, trimmed to the model's token budget
+func (h *Handler) buildMessages(ctx context.Context, msg *tgbotapi.Message, text string) []ai.Message {
+	userID := strconv.FormatInt(msg.From.ID, 10)
+
+	var mem string
+	if h.memoryService != nil {
+		if stored, err := h.memoryService.GetMemory(msg.From.ID); err == nil && stored != "{}" {
+			mem = stored
+		}
+	}
+	systemPrompt := h.systemPrompt(msg, mem)
+
+	var history []ai.Turn
+	if h.convService != nil {
+		turns, err := h.convService.GetConversationTurns(userID, contextMessages)
+		if err != nil {
+			log.Printf("❌ Error loading conversation history: %v", err)
+		}
+		history = turns
+	}
+
+	recollections, err := h.retriever.Recall(ctx, userID, text, history)
+	if err != nil {
+		log.Printf("❌ Error recalling earlier conversations: %v", err)
+	}
+	systemPrompt = h.retriever.WithRecollections(systemPrompt, recollections)
+
+	passages, err := h.knowledge.Search(ctx, userID, text)
+	if err != nil {
+		log.Printf("❌ Error searching documents: %v", err)
+	}
+	systemPrompt = h.knowledge.WithPassages(systemPrompt, passages)
+
+	return h.contextBuilder.Build(ctx, userID, systemPrompt, history, text)
+}
+
+func (h *Handler) reply(chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	if _, err := h.bot.Send(msg); err != nil {
+		// Retry without markdown in case the text is not valid Markdown
+		msg.ParseMode = ""
+		if _, err := h.bot.Send(msg); err != nil {
+			log.Printf("❌ Failed to send message: %v", err)
+		}
+	}
+}
+
+// streamingMessage throttles edits of a Telegram message while a reply is streamed
+type streamingMessage struct {
+	bot       *tgbotapi.BotAPI
+	chatID    int64
+	messageID int
+	lastEdit  time.Time
+	lastText  string
+}
+
+func (s *streamingMessage) update(text string, final bool) {
+	if !final && time.Since(s.lastEdit) < editInterval {
+		return
+	}
+
+	parts := splitMessage(text)
+	if parts[0] != s.lastText {
+		if _, err := s.bot.Send(tgbotapi.NewEditMessageText(s.chatID, s.messageID, parts[0])); err != nil {
+			log.Printf("❌ Failed to edit message: %v", err)
+		}
+		s.lastText = parts[0]
+	}
+	s.lastEdit = time.Now()
+
+	if !final {
+		return
+	}
+
+	// Overflow goes out as follow-up messages once the reply is complete
+	for _, part := range parts[1:] {
+		if _, err := s.bot.Send(tgbotapi.NewMessage(s.chatID, part)); err != nil {
+			log.Printf("❌ Failed to send message: %v", err)
+		}
+	}
+}
+
+// splitMessage splits text into chunks that fit into a single Telegram message
+func splitMessage(text string) []string {
+	var parts []string
+	for utf16Len(text) > maxMessageLength {
+		n := fitMessage(text)
+		parts = append(parts, text[:n])
+		text = text[n:]
+	}
+	return append(parts, text)
+}
+
+// fitMessage returns the byte length of the longest prefix of text within maxMessageLength,
+// ending after a newline when there is one in the second half of the prefix
+func fitMessage(text string) int {
+	units, newline := 0, -1
+	for i, r := range text {
+		units += runeUnits(r)
+		if units > maxMessageLength {
+			if newline > 0 {
+				return newline
+			}
+			return i
+		}
+		if r == '\n' && units > maxMessageLength/2 {
+			newline = i + 1
+		}
+	}
+	return len(text)
+}
+
+// runeUnits is the number of UTF-16 code units of r (utf16.RuneLen needs Go 1.23)
+func runeUnits(r rune) int {
+	if r > 0xFFFF && r <= utf8.MaxRune {
+		return 2
+	}
+	return 1
+}
+
+// utf16Len counts the UTF-16 code units of text, the unit of Telegram's length limit
+func utf16Len(text string) int {
+	n := 0
+	for _, r := range text {
+		n += runeUnits(r)
+	}
+	return n
+}
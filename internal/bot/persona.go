@@ -0,0 +1,77 @@
+package bot
+
+import (
+	"Qwen/internal/ai"
+	"Qwen/internal/prompt"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handlePersona lists the personas or switches the user's persona: /persona [name]
+func (h *Handler) handlePersona(msg *tgbotapi.Message) {
+	if h.convService == nil {
+		h.reply(msg.Chat.ID, "⚠️ Persona tidak bisa disimpan karena database belum dikonfigurasi.")
+		return
+	}
+	userID := strconv.FormatInt(msg.From.ID, 10)
+
+	name := strings.TrimSpace(msg.CommandArguments())
+	if name == "" {
+		current := h.persona(userID)
+		if _, ok := h.prompts.Persona(current); !ok {
+			current = h.prompts.DefaultPersona()
+		}
+
+		var b strings.Builder
+		b.WriteString("🎭 Persona:\n")
+		for _, p := range h.prompts.Personas() {
+			mark := "▫️"
+			if p.Name == current {
+				mark = "✅"
+			}
+			fmt.Fprintf(&b, "\n%s %s - %s", mark, p.Name, p.Description)
+		}
+		b.WriteString("\n\nContoh: /persona tutor")
+		h.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, b.String()))
+		return
+	}
+
+	p, ok := h.prompts.Persona(name)
+	if !ok {
+		h.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, fmt.Sprintf("❌ Persona %q tidak dikenal. Ketik /persona untuk melihat daftarnya.", name)))
+		return
+	}
+	if err := h.convService.SetPersona(userID, p.Name); err != nil {
+		log.Printf("❌ Error saving persona: %v", err)
+		h.reply(msg.Chat.ID, "❌ Gagal menyimpan persona. Coba lagi nanti.")
+		return
+	}
+	h.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, fmt.Sprintf("✅ Persona diganti ke %s.", p.Name)))
+}
+
+// persona returns the persona the user picked, empty for the default one
+func (h *Handler) persona(userID string) string {
+	if h.convService == nil {
+		return ""
+	}
+	name, err := h.convService.GetPersona(userID)
+	if err != nil {
+		log.Printf("❌ Error loading persona: %v", err)
+	}
+	return name
+}
+
+// systemPrompt renders the user's persona with their stored memory, language and chat type
+func (h *Handler) systemPrompt(msg *tgbotapi.Message, mem string) string {
+	return h.prompts.System(h.persona(strconv.FormatInt(msg.From.ID, 10)), prompt.Data{
+		Memory:   mem,
+		Language: ai.LanguageName(msg.From.LanguageCode),
+		ChatType: msg.Chat.Type,
+		Now:      time.Now(),
+	})
+}
@@ -0,0 +1,119 @@
+package bot
+
+import (
+	"Qwen/internal/ai"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const translateUsage = "Cara pakai: /translate <bahasa> <teks>, misalnya /translate en Selamat pagi.\n" +
+	"Balas sebuah pesan dengan /translate <bahasa> untuk menerjemahkannya. Gunakan id:en untuk menentukan bahasa asal."
+
+// handleTranslate translates the command text or the replied-to message with the user's glossary
+func (h *Handler) handleTranslate(msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	userID := strconv.FormatInt(msg.From.ID, 10)
+
+	langs, text, _ := strings.Cut(strings.TrimSpace(msg.CommandArguments()), " ")
+	text = strings.TrimSpace(text)
+	if text == "" && msg.ReplyToMessage != nil {
+		text = strings.TrimSpace(msg.ReplyToMessage.Text)
+	}
+	if langs == "" || text == "" {
+		h.reply(chatID, translateUsage)
+		return
+	}
+
+	req := ai.TranslateRequest{Text: text, TargetLang: langs}
+	if source, target, ok := strings.Cut(langs, ":"); ok {
+		req.SourceLang, req.TargetLang = source, target
+	}
+
+	if h.glossary != nil {
+		terms, err := h.glossary.GetTerms(userID)
+		if err != nil {
+			log.Printf("❌ Error loading glossary: %v", err)
+		}
+		req.Terms = terms
+	}
+
+	if !h.allow(msg) {
+		return
+	}
+
+	h.bot.Request(tgbotapi.NewChatAction(chatID, tgbotapi.ChatTyping))
+
+	ctx := ai.WithUsageTag(h.ctx, ai.UsageTag{UserID: userID, Purpose: ai.PurposeTranslation})
+	translation, err := h.aiClient.Translate(ctx, req)
+	if err != nil {
+		log.Printf("❌ Translation error for user %s: %v", userID, err)
+		h.reply(chatID, errorReply(err))
+		return
+	}
+
+	for _, part := range splitMessage(translation.Text) {
+		h.bot.Send(tgbotapi.NewMessage(chatID, part))
+	}
+}
+
+// handleGlossary lists, sets ("istilah = terjemahan") or removes ("hapus istilah") glossary terms
+func (h *Handler) handleGlossary(msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	if h.glossary == nil {
+		h.reply(chatID, "⚠️ Fitur glossary tidak aktif karena database belum dikonfigurasi.")
+		return
+	}
+	userID := strconv.FormatInt(msg.From.ID, 10)
+	args := strings.TrimSpace(msg.CommandArguments())
+
+	if args == "" {
+		terms, err := h.glossary.GetTerms(userID)
+		if err != nil {
+			log.Printf("❌ Error loading glossary: %v", err)
+			h.reply(chatID, "❌ Gagal memuat glossary. Coba lagi nanti.")
+			return
+		}
+		if len(terms) == 0 {
+			h.bot.Send(tgbotapi.NewMessage(chatID, "📖 Glossary kamu masih kosong.\nTambahkan dengan /glossary istilah = terjemahan"))
+			return
+		}
+		var b strings.Builder
+		b.WriteString("📖 Glossary kamu:\n")
+		for _, term := range terms {
+			fmt.Fprintf(&b, "\n• %s = %s", term.Source, term.Target)
+		}
+		h.bot.Send(tgbotapi.NewMessage(chatID, b.String()))
+		return
+	}
+
+	if command, term, ok := strings.Cut(args, " "); ok && (command == "hapus" || command == "remove") {
+		removed, err := h.glossary.RemoveTerm(userID, term)
+		if err != nil {
+			log.Printf("❌ Error removing glossary term: %v", err)
+			h.reply(chatID, "❌ Gagal menghapus istilah. Coba lagi nanti.")
+			return
+		}
+		if !removed {
+			h.bot.Send(tgbotapi.NewMessage(chatID, "Istilah itu tidak ada di glossary kamu."))
+			return
+		}
+		h.bot.Send(tgbotapi.NewMessage(chatID, "🗑️ Istilah dihapus dari glossary."))
+		return
+	}
+
+	source, target, ok := strings.Cut(args, "=")
+	if !ok || strings.TrimSpace(source) == "" || strings.TrimSpace(target) == "" {
+		h.reply(chatID, "Cara pakai: /glossary istilah = terjemahan, atau /glossary hapus istilah.")
+		return
+	}
+	if err := h.glossary.SetTerm(userID, source, target); err != nil {
+		log.Printf("❌ Error saving glossary term: %v", err)
+		h.reply(chatID, "❌ Gagal menyimpan istilah. Coba lagi nanti.")
+		return
+	}
+	h.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ %s akan selalu diterjemahkan sebagai %s.", strings.TrimSpace(source), strings.TrimSpace(target))))
+}
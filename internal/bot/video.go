@@ -0,0 +1,70 @@
+package bot
+
+import (
+	"Qwen/internal/ai"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// defaultVideoPrompt is asked when a video arrives without a caption
+const defaultVideoPrompt = "Jelaskan isi video ini."
+
+// handleVideo answers a video or video note, using the caption as the question
+func (h *Handler) handleVideo(msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	userID := strconv.FormatInt(msg.From.ID, 10)
+
+	video := &ai.OmniVideo{Mime: "video/mp4"}
+	var fileID string
+	if msg.Video != nil {
+		fileID = msg.Video.FileID
+		video.Duration = time.Duration(msg.Video.Duration) * time.Second
+		if msg.Video.MimeType != "" {
+			video.Mime = msg.Video.MimeType
+		}
+	} else {
+		fileID = msg.VideoNote.FileID
+		video.Duration = time.Duration(msg.VideoNote.Duration) * time.Second
+	}
+
+	prompt := strings.TrimSpace(msg.Caption)
+	if prompt == "" {
+		prompt = defaultVideoPrompt
+	}
+
+	if !h.allow(msg) {
+		return
+	}
+
+	h.bot.Request(tgbotapi.NewChatAction(chatID, tgbotapi.ChatTyping))
+
+	data, err := h.downloadFile(h.ctx, fileID)
+	if err != nil {
+		log.Printf("❌ Failed to download video: %v", err)
+		h.reply(chatID, "❌ Gagal mengunduh video. Coba kirim ulang.")
+		return
+	}
+	video.DataBase64 = ai.ToBase64(data)
+
+	ctx := ai.WithUsageTag(h.ctx, ai.UsageTag{UserID: userID, Purpose: ai.PurposeChat})
+	out, err := h.aiClient.ChatOmni(ctx, h.systemPrompt(msg, ""), prompt, nil, nil, video, false)
+	if err != nil {
+		log.Printf("❌ Video error for user %s: %v", userID, err)
+		h.reply(chatID, errorReply(err))
+		return
+	}
+
+	reply := strings.TrimSpace(out.Text)
+	if reply == "" {
+		reply = "🤔 Maaf, aku tidak punya jawaban untuk itu."
+	}
+	for _, part := range splitMessage(reply) {
+		h.reply(chatID, part)
+	}
+
+	h.saveTurn(ctx, msg, "🎬 "+prompt, reply)
+}
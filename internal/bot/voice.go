@@ -0,0 +1,216 @@
+package bot
+
+import (
+	"Qwen/internal/ai"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleVoice shows or updates the user's voice settings: /voice [voice] [format] [sample_rate]
+func (h *Handler) handleVoice(msg *tgbotapi.Message) {
+	if h.convService == nil {
+		h.reply(msg.Chat.ID, "⚠️ Pengaturan suara tidak bisa disimpan karena database belum dikonfigurasi.")
+		return
+	}
+	userID := strconv.FormatInt(msg.From.ID, 10)
+
+	args := strings.Fields(msg.CommandArguments())
+	if len(args) == 0 {
+		prefs, err := h.convService.GetAudioPreferences(userID)
+		if err != nil {
+			log.Printf("❌ Error loading audio preferences: %v", err)
+		}
+		h.reply(msg.Chat.ID, fmt.Sprintf("🔊 Suara: %s\nFormat: %s\nSample rate: %s\n\nContoh: /voice Cherry wav 16000",
+			orDefault(prefs.Voice), orDefault(prefs.Format), orDefault(sampleRateText(prefs.SampleRate))))
+		return
+	}
+
+	prefs := ai.AudioOptions{Voice: args[0]}
+	if len(args) > 1 {
+		prefs.Format = strings.ToLower(args[1])
+	}
+	if len(args) > 2 {
+		rate, err := strconv.Atoi(args[2])
+		if err != nil || rate <= 0 {
+			h.reply(msg.Chat.ID, "❌ Sample rate harus berupa angka, misalnya 16000.")
+			return
+		}
+		prefs.SampleRate = rate
+	}
+	if prefs.Format != "" {
+		if err := h.aiClient.ValidateAudio(prefs); err != nil {
+			h.reply(msg.Chat.ID, "❌ Format tidak didukung. Gunakan wav atau pcm16.")
+			return
+		}
+	}
+
+	if err := h.convService.SetAudioPreferences(userID, prefs); err != nil {
+		log.Printf("❌ Error saving audio preferences: %v", err)
+		h.reply(msg.Chat.ID, "❌ Gagal menyimpan pengaturan suara. Coba lagi nanti.")
+		return
+	}
+	h.reply(msg.Chat.ID, "✅ Pengaturan suara disimpan.")
+}
+
+// handleSpeak reads out the command text or the message being replied to
+func (h *Handler) handleSpeak(msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	userID := strconv.FormatInt(msg.From.ID, 10)
+
+	text := strings.TrimSpace(msg.CommandArguments())
+	if text == "" && msg.ReplyToMessage != nil {
+		text = strings.TrimSpace(msg.ReplyToMessage.Text)
+	}
+	if text == "" {
+		h.reply(chatID, "Tulis teks setelah /speak, atau balas sebuah pesan dengan /speak.")
+		return
+	}
+
+	if !h.allow(msg) {
+		return
+	}
+
+	h.bot.Request(tgbotapi.NewChatAction(chatID, tgbotapi.ChatRecordVoice))
+
+	ctx := ai.WithUsageTag(h.ctx, ai.UsageTag{UserID: userID, Purpose: ai.PurposeSpeech})
+	if h.convService != nil {
+		prefs, err := h.convService.GetAudioPreferences(userID)
+		if err != nil {
+			log.Printf("❌ Error loading audio preferences: %v", err)
+		}
+		ctx = ai.WithRequestOptions(ctx, ai.RequestOptions{Audio: &prefs})
+	}
+
+	speech, err := h.aiClient.TextToSpeech(ctx, text, "")
+	if err != nil {
+		log.Printf("❌ Speech error for user %s: %v", userID, err)
+		h.reply(chatID, errorReply(err))
+		return
+	}
+
+	file := tgbotapi.FileBytes{Name: "speech." + speechExtension(speech.Format), Bytes: speech.Audio}
+	var upload tgbotapi.Chattable
+	if speech.Format == ai.AudioFormatMP3 {
+		// Telegram only plays mp3/m4a inline; other formats are sent as files
+		upload = tgbotapi.NewAudio(chatID, file)
+	} else {
+		upload = tgbotapi.NewDocument(chatID, file)
+	}
+	if _, err := h.bot.Send(upload); err != nil {
+		log.Printf("❌ Failed to send audio: %v", err)
+	}
+}
+
+// maxDownloadBytes is the largest file the Bot API lets bots download
+const maxDownloadBytes = 20 << 20
+
+// handleVoiceNote transcribes a voice note or audio file and answers the transcript like a text message
+func (h *Handler) handleVoiceNote(msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	userID := strconv.FormatInt(msg.From.ID, 10)
+
+	fileID, mime := "", ""
+	if msg.Voice != nil {
+		fileID, mime = msg.Voice.FileID, msg.Voice.MimeType
+	} else {
+		fileID, mime = msg.Audio.FileID, msg.Audio.MimeType
+	}
+	if mime == "" {
+		// Telegram voice notes are opus in ogg
+		mime = "audio/ogg"
+	}
+
+	if !h.allow(msg) {
+		return
+	}
+
+	h.bot.Request(tgbotapi.NewChatAction(chatID, tgbotapi.ChatTyping))
+
+	data, err := h.downloadFile(h.ctx, fileID)
+	if err != nil {
+		log.Printf("❌ Failed to download voice note: %v", err)
+		h.reply(chatID, "❌ Gagal mengunduh pesan suara. Coba kirim ulang.")
+		return
+	}
+
+	ctx := ai.WithUsageTag(h.ctx, ai.UsageTag{UserID: userID, Purpose: ai.PurposeTranscription})
+	transcript, err := h.aiClient.Transcribe(ctx, ai.OmniMedia{Mime: mime, DataBase64: ai.ToBase64(data)}, languageHint(msg.From.LanguageCode))
+	if err != nil {
+		log.Printf("❌ Transcription error for user %s: %v", userID, err)
+		h.reply(chatID, errorReply(err))
+		return
+	}
+	if transcript.Text == "" {
+		h.reply(chatID, "🤔 Maaf, aku tidak bisa menangkap isi pesan suaramu.")
+		return
+	}
+
+	log.Printf("[%s] 🎙️ %s", msg.From.UserName, transcript.Text)
+	h.bot.Send(tgbotapi.NewMessage(chatID, "🎙️ "+transcript.Text))
+	h.chat(msg, transcript.Text)
+}
+
+// downloadFile fetches a file sent to the bot
+func (h *Handler) downloadFile(ctx context.Context, fileID string) ([]byte, error) {
+	url, err := h.bot.GetFileDirectURL(fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file URL: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download file: status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDownloadBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+	if len(data) > maxDownloadBytes {
+		return nil, fmt.Errorf("file is larger than %d bytes", maxDownloadBytes)
+	}
+	return data, nil
+}
+
+// languageHint reduces a Telegram language_code such as "en-US" to its primary subtag
+func languageHint(languageCode string) string {
+	code, _, _ := strings.Cut(languageCode, "-")
+	return strings.ToLower(code)
+}
+
+// speechExtension returns the file extension for an ai.AudioFormat*
+func speechExtension(format string) string {
+	if format == ai.AudioFormatPCM16 {
+		return "pcm"
+	}
+	return format
+}
+
+func sampleRateText(rate int) string {
+	if rate == 0 {
+		return ""
+	}
+	return strconv.Itoa(rate)
+}
+
+func orDefault(value string) string {
+	if value == "" {
+		return "default"
+	}
+	return value
+}
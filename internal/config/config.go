@@ -3,6 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -11,9 +14,84 @@ type Config struct {
 	TelegramBotToken string
 	DashScopeAPIKey  string
 	DashScopeBaseURL string
+	AIProvider       string
+	OpenAIAPIKey     string
+	OpenAIBaseURL    string
 	AIModel          string
 	HTTPPort         string
 	DatabaseDSN      string
+	// WebAuthSecret signs WebSocket user IDs; unauthenticated web clients get no stored data
+	WebAuthSecret string
+
+	// Retry policy for transient upstream failures
+	AIMaxRetries     int
+	AIRetryBaseDelay time.Duration
+	AIRetryMaxDelay  time.Duration
+
+	// Rolling-window quotas (0 = unlimited)
+	QuotaUserMessages   int
+	QuotaUserTokens     int
+	QuotaUserWindow     time.Duration
+	QuotaGlobalMessages int
+	QuotaGlobalTokens   int
+	QuotaGlobalWindow   time.Duration
+	// QuotaAllowlist holds user IDs exempt from quotas
+	QuotaAllowlist []string
+
+	// AITranslationModel serves /translate (empty = qwen-mt-turbo)
+	AITranslationModel string
+
+	// Embedding model and vector size for Embed (0 = model default)
+	AIEmbeddingModel      string
+	AIEmbeddingDimensions int
+
+	// Retrieval of relevant past turns (see ai.Retriever); needs a database, 0 turns disables it
+	AIRetrievalTopK     int
+	AIRetrievalMinScore float64
+
+	// Knowledge base of uploaded documents (see knowledge.Base); needs a database, 0 passages disables it
+	KnowledgeTopK         int
+	KnowledgeMaxDocuments int
+
+	// Prompt templates (see prompt.Library); an empty PromptDir uses the built-ins, a zero interval disables reloading
+	PromptDir            string
+	PromptDefaultPersona string
+	PromptReloadInterval time.Duration
+
+	// Task routing (see ai.Router); empty models fall back to AIModel.
+	// AIRoutes holds "task=model" pairs (see ai.ParseRoutes); the per-task models take precedence.
+	AIRoutes         string
+	AIMemoryModel    string
+	AIReasoningModel string
+	AIMediaModel     string
+	AISummaryModel   string
+
+	// Prompt token budget of chat requests (see ai.ContextBuilder); older turns are summarized
+	AIContextBudget int
+
+	// Response cache for repeated chat requests: "off", "memory" or "database"
+	AICache     string
+	AICacheTTL  time.Duration
+	AICacheSize int
+
+	// Fallback chain (see ai.ParseEndpoints) and its circuit breaker
+	AIFallbacks        string
+	AIFallbackAPIKey   string
+	AIBreakerThreshold int
+	AIBreakerCooldown  time.Duration
+
+	// Default spoken output (empty/0 = provider default), overridable per user with /voice
+	AISpeechModel     string
+	AIVoice           string
+	AIAudioFormat     string
+	AIAudioSampleRate int
+
+	// Uploaded video handling (see ai.VideoOptions)
+	VideoMode        string
+	VideoFPS         float64
+	VideoMaxFrames   int
+	VideoMaxDuration time.Duration
+	FFmpegPath       string
 }
 
 func Load() *Config {
@@ -26,16 +104,80 @@ func Load() *Config {
 		TelegramBotToken: getEnv("TELEGRAM_BOT_TOKEN", ""),
 		DashScopeAPIKey:  getEnv("DASHSCOPE_API_KEY", ""),
 		DashScopeBaseURL: getEnv("DASHSCOPE_BASE_URL", "https://dashscope-intl.aliyuncs.com/compatible-mode/v1"),
+		AIProvider:       getEnv("AI_PROVIDER", "dashscope"),
+		OpenAIAPIKey:     getEnv("OPENAI_API_KEY", ""),
+		OpenAIBaseURL:    getEnv("OPENAI_BASE_URL", "http://localhost:11434/v1"),
 		AIModel:          getEnv("AI_MODEL", "qwen-mt-turbo"),
 		HTTPPort:         getEnv("HTTP_PORT", "8080"),
 		DatabaseDSN:      getEnv("DATABASE_DSN", ""),
+		WebAuthSecret:    getEnv("WEB_AUTH_SECRET", ""),
+		AIMaxRetries:     getEnvInt("AI_MAX_RETRIES", 2),
+		AIRetryBaseDelay: getEnvDuration("AI_RETRY_BASE_DELAY", 500*time.Millisecond),
+		AIRetryMaxDelay:  getEnvDuration("AI_RETRY_MAX_DELAY", 8*time.Second),
+
+		QuotaUserMessages:   getEnvInt("QUOTA_USER_MESSAGES", 0),
+		QuotaUserTokens:     getEnvInt("QUOTA_USER_TOKENS", 0),
+		QuotaUserWindow:     getEnvDuration("QUOTA_USER_WINDOW", 24*time.Hour),
+		QuotaGlobalMessages: getEnvInt("QUOTA_GLOBAL_MESSAGES", 0),
+		QuotaGlobalTokens:   getEnvInt("QUOTA_GLOBAL_TOKENS", 0),
+		QuotaGlobalWindow:   getEnvDuration("QUOTA_GLOBAL_WINDOW", 24*time.Hour),
+		QuotaAllowlist:      getEnvList("QUOTA_ALLOWLIST"),
+
+		AITranslationModel: getEnv("AI_TRANSLATION_MODEL", ""),
+
+		AIEmbeddingModel:      getEnv("AI_EMBEDDING_MODEL", ""),
+		AIEmbeddingDimensions: getEnvInt("AI_EMBEDDING_DIMENSIONS", 0),
+
+		AIRetrievalTopK:     getEnvInt("AI_RETRIEVAL_TOP_K", 3),
+		AIRetrievalMinScore: getEnvFloat("AI_RETRIEVAL_MIN_SCORE", 0.45),
+
+		KnowledgeTopK:         getEnvInt("KNOWLEDGE_TOP_K", 4),
+		KnowledgeMaxDocuments: getEnvInt("KNOWLEDGE_MAX_DOCUMENTS", 50),
+
+		PromptDir:            getEnv("PROMPT_DIR", ""),
+		PromptDefaultPersona: getEnv("PROMPT_DEFAULT_PERSONA", "casual"),
+		PromptReloadInterval: getEnvDuration("PROMPT_RELOAD_INTERVAL", 5*time.Second),
+
+		AIRoutes:         getEnv("AI_ROUTES", ""),
+		AIMemoryModel:    getEnv("AI_MEMORY_MODEL", ""),
+		AIReasoningModel: getEnv("AI_REASONING_MODEL", ""),
+		AIMediaModel:     getEnv("AI_MEDIA_MODEL", ""),
+		AISummaryModel:   getEnv("AI_SUMMARY_MODEL", ""),
+
+		AIContextBudget: getEnvInt("AI_CONTEXT_BUDGET", 6000),
+
+		AICache:     strings.ToLower(getEnv("AI_CACHE", "off")),
+		AICacheTTL:  getEnvDuration("AI_CACHE_TTL", time.Hour),
+		AICacheSize: getEnvInt("AI_CACHE_SIZE", 1000),
+
+		AIFallbacks:        getEnv("AI_FALLBACKS", ""),
+		AIFallbackAPIKey:   getEnv("AI_FALLBACK_API_KEY", ""),
+		AIBreakerThreshold: getEnvInt("AI_BREAKER_THRESHOLD", 3),
+		AIBreakerCooldown:  getEnvDuration("AI_BREAKER_COOLDOWN", 30*time.Second),
+
+		AISpeechModel:     getEnv("AI_SPEECH_MODEL", ""),
+		AIVoice:           getEnv("AI_VOICE", ""),
+		AIAudioFormat:     getEnv("AI_AUDIO_FORMAT", ""),
+		AIAudioSampleRate: getEnvInt("AI_AUDIO_SAMPLE_RATE", 0),
+
+		VideoMode:        getEnv("VIDEO_MODE", "auto"),
+		VideoFPS:         getEnvFloat("VIDEO_FPS", 1),
+		VideoMaxFrames:   getEnvInt("VIDEO_MAX_FRAMES", 32),
+		VideoMaxDuration: getEnvDuration("VIDEO_MAX_DURATION", 2*time.Minute),
+		FFmpegPath:       getEnv("FFMPEG_PATH", "ffmpeg"),
+	}
+
+	// Provider names are matched case-insensitively, like ai.NewLLM does
+	config.AIProvider = strings.ToLower(strings.TrimSpace(config.AIProvider))
+	if config.AIProvider == "" {
+		config.AIProvider = "dashscope"
 	}
 
 	if config.TelegramBotToken == "" {
 		log.Fatal("TELEGRAM_BOT_TOKEN is required")
 	}
 
-	if config.DashScopeAPIKey == "" {
+	if config.AIProvider == "dashscope" && config.DashScopeAPIKey == "" {
 		log.Fatal("DASHSCOPE_API_KEY is required")
 	}
 
@@ -48,3 +190,61 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %g", key, value, defaultValue)
+		return defaultValue
+	}
+	return f
+}
+
+// getEnvList splits a comma-separated variable, dropping empty items
+func getEnvList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// AICredentials returns the API key and base URL for the configured AI provider
+func (c *Config) AICredentials() (apiKey, baseURL string) {
+	if c.AIProvider == "openai" {
+		return c.OpenAIAPIKey, c.OpenAIBaseURL
+	}
+	return c.DashScopeAPIKey, c.DashScopeBaseURL
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"Qwen/internal/ai"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// CacheService stores ai.ResponseCache entries in MySQL, so cached answers survive restarts
+// and are shared by every instance of the bot
+type CacheService struct {
+	db         *DB
+	maxEntries int
+}
+
+// NewCacheService creates a store holding at most maxEntries responses (0 = ai.DefaultCacheEntries);
+// the least recently used ones are evicted first
+func NewCacheService(db *DB, maxEntries int) *CacheService {
+	if maxEntries <= 0 {
+		maxEntries = ai.DefaultCacheEntries
+	}
+	return &CacheService{db: db, maxEntries: maxEntries}
+}
+
+var _ ai.CacheStore = (*CacheService)(nil)
+
+// GetResponse returns the response stored under key and marks it as recently used
+func (cs *CacheService) GetResponse(key string) (ai.CachedResponse, bool, error) {
+	query := `
+		SELECT model, answer, reasoning, expires_at
+		FROM response_cache
+		WHERE cache_key = ?
+	`
+
+	var response ai.CachedResponse
+	err := cs.db.conn.QueryRow(query, key).Scan(&response.Model, &response.Answer, &response.Reasoning, &response.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return response, false, nil
+		}
+		return response, false, fmt.Errorf("failed to get cached response: %w", err)
+	}
+
+	if _, err := cs.db.conn.Exec(`UPDATE response_cache SET last_used_at = ? WHERE cache_key = ?`, time.Now(), key); err != nil {
+		return response, true, fmt.Errorf("failed to touch cached response: %w", err)
+	}
+	return response, true, nil
+}
+
+// SaveResponse stores response under key, then drops expired entries and the least
+// recently used ones above the size bound
+func (cs *CacheService) SaveResponse(key string, response ai.CachedResponse) error {
+	query := `
+		INSERT INTO response_cache (cache_key, model, answer, reasoning, expires_at, last_used_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+		model = VALUES(model),
+		answer = VALUES(answer),
+		reasoning = VALUES(reasoning),
+		expires_at = VALUES(expires_at),
+		last_used_at = VALUES(last_used_at)
+	`
+
+	now := time.Now()
+	if _, err := cs.db.conn.Exec(query, key, response.Model, response.Answer, response.Reasoning, response.ExpiresAt, now); err != nil {
+		return fmt.Errorf("failed to save cached response: %w", err)
+	}
+	return cs.evict(now)
+}
+
+// DeleteResponse removes the response stored under key
+func (cs *CacheService) DeleteResponse(key string) error {
+	if _, err := cs.db.conn.Exec(`DELETE FROM response_cache WHERE cache_key = ?`, key); err != nil {
+		return fmt.Errorf("failed to delete cached response: %w", err)
+	}
+	return nil
+}
+
+func (cs *CacheService) evict(now time.Time) error {
+	if _, err := cs.db.conn.Exec(`DELETE FROM response_cache WHERE expires_at <= ?`, now); err != nil {
+		return fmt.Errorf("failed to expire cached responses: %w", err)
+	}
+
+	var count int
+	if err := cs.db.conn.QueryRow(`SELECT COUNT(*) FROM response_cache`).Scan(&count); err != nil {
+		return fmt.Errorf("failed to count cached responses: %w", err)
+	}
+	if extra := count - cs.maxEntries; extra > 0 {
+		if _, err := cs.db.conn.Exec(`DELETE FROM response_cache ORDER BY last_used_at LIMIT ?`, extra); err != nil {
+			return fmt.Errorf("failed to evict cached responses: %w", err)
+		}
+	}
+	return nil
+}
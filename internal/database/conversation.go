@@ -1,12 +1,21 @@
 package database
 
 import (
+	"Qwen/internal/ai"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"time"
 )
 
+// ConversationVectors is the vector collection of conversation turns (see ai.Retriever),
+// keyed by conversation ID
+const ConversationVectors = "conversations"
+
+// DocumentVectors is the vector collection of document chunks (see knowledge.Base),
+// keyed by "<document ID>:<chunk number>"
+const DocumentVectors = "documents"
+
 type Conversation struct {
 	ID        int64     `json:"id"`
 	UserID    string    `json:"user_id"`
@@ -32,19 +41,23 @@ func NewConversationService(db *DB) *ConversationService {
 	return &ConversationService{db: db}
 }
 
-// SaveConversation saves a conversation to the database
-func (cs *ConversationService) SaveConversation(userID, userName, message, response string) error {
+// SaveConversation saves a conversation to the database and returns its ID
+func (cs *ConversationService) SaveConversation(userID, userName, message, response string) (int64, error) {
 	query := `
 		INSERT INTO conversations (user_id, user_name, message, response) 
 		VALUES (?, ?, ?, ?)
 	`
 
-	_, err := cs.db.conn.Exec(query, userID, userName, message, response)
+	result, err := cs.db.conn.Exec(query, userID, userName, message, response)
 	if err != nil {
-		return fmt.Errorf("failed to save conversation: %w", err)
+		return 0, fmt.Errorf("failed to save conversation: %w", err)
 	}
 
-	return nil
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get conversation ID: %w", err)
+	}
+	return id, nil
 }
 
 // GetRecentConversations gets recent conversations for a user
@@ -76,21 +89,66 @@ func (cs *ConversationService) GetRecentConversations(userID string, limit int)
 	return conversations, nil
 }
 
-// GetConversationContext builds context from recent conversations
-func (cs *ConversationService) GetConversationContext(userID string, maxMessages int) string {
-	conversations, err := cs.GetRecentConversations(userID, maxMessages)
-	if err != nil || len(conversations) == 0 {
-		return ""
+// GetConversationHistory gets the most recent conversations for a user in chronological order (oldest first)
+func (cs *ConversationService) GetConversationHistory(userID string, limit int) ([]Conversation, error) {
+	conversations, err := cs.GetRecentConversations(userID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(conversations)-1; i < j; i, j = i+1, j-1 {
+		conversations[i], conversations[j] = conversations[j], conversations[i]
+	}
+
+	return conversations, nil
+}
+
+// GetConversationTurns returns the most recent conversations of a user as turns, oldest first,
+// for ai.ContextBuilder to fit into the model's token budget
+func (cs *ConversationService) GetConversationTurns(userID string, limit int) ([]ai.Turn, error) {
+	conversations, err := cs.GetConversationHistory(userID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	turns := make([]ai.Turn, 0, len(conversations))
+	for _, conv := range conversations {
+		turns = append(turns, ai.Turn{ID: conv.ID, User: conv.Message, Assistant: conv.Response})
+	}
+	return turns, nil
+}
+
+// TurnsAfter returns up to limit conversations of all users with an ID above afterID, in ID order,
+// for ai.Retriever to index
+func (cs *ConversationService) TurnsAfter(afterID int64, limit int) ([]ai.PastTurn, error) {
+	query := `
+		SELECT id, user_id, message, response, created_at
+		FROM conversations
+		WHERE id > ?
+		ORDER BY id
+		LIMIT ?
+	`
+
+	rows, err := cs.db.conn.Query(query, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversations: %w", err)
 	}
+	defer rows.Close()
 
-	var context string
-	// Reverse to get chronological order (oldest first)
-	for i := len(conversations) - 1; i >= 0; i-- {
-		conv := conversations[i]
-		context += fmt.Sprintf("User: %s\nAI: %s\n\n", conv.Message, conv.Response)
+	var turns []ai.PastTurn
+	for rows.Next() {
+		var turn ai.PastTurn
+		err := rows.Scan(&turn.ID, &turn.UserID, &turn.User, &turn.Assistant, &turn.At)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan conversation: %w", err)
+		}
+		turns = append(turns, turn)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get conversations: %w", err)
 	}
 
-	return context
+	return turns, nil
 }
 
 // UpdateSession updates or creates a chat session
@@ -140,22 +198,172 @@ func (cs *ConversationService) GetSession(userID string) (*ChatSession, error) {
 	return &session, nil
 }
 
-// CleanOldConversations removes conversations older than specified days
+// sessionData returns the session JSON of a user as a map, empty if there is none
+func (cs *ConversationService) sessionData(userID string) (map[string]json.RawMessage, error) {
+	data := make(map[string]json.RawMessage)
+
+	session, err := cs.GetSession(userID)
+	if err != nil || session == nil || len(session.SessionData) == 0 {
+		return data, err
+	}
+	if err := json.Unmarshal(session.SessionData, &data); err != nil {
+		return nil, fmt.Errorf("failed to parse session data: %w", err)
+	}
+	return data, nil
+}
+
+// GetAudioPreferences returns the user's saved voice settings (zero value if none)
+func (cs *ConversationService) GetAudioPreferences(userID string) (ai.AudioOptions, error) {
+	var prefs ai.AudioOptions
+
+	data, err := cs.sessionData(userID)
+	if err != nil {
+		return prefs, err
+	}
+	if raw, ok := data["audio"]; ok {
+		if err := json.Unmarshal(raw, &prefs); err != nil {
+			return prefs, fmt.Errorf("failed to parse audio preferences: %w", err)
+		}
+	}
+	return prefs, nil
+}
+
+// SetAudioPreferences stores the user's voice settings in the session, keeping other session keys
+func (cs *ConversationService) SetAudioPreferences(userID string, prefs ai.AudioOptions) error {
+	data, err := cs.sessionData(userID)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(prefs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audio preferences: %w", err)
+	}
+	data["audio"] = raw
+
+	return cs.UpdateSession(userID, data)
+}
+
+// GetSummary returns the rolling summary of the user's older turns (zero value if none)
+func (cs *ConversationService) GetSummary(userID string) (ai.ConversationSummary, error) {
+	var summary ai.ConversationSummary
+
+	data, err := cs.sessionData(userID)
+	if err != nil {
+		return summary, err
+	}
+	if raw, ok := data["summary"]; ok {
+		if err := json.Unmarshal(raw, &summary); err != nil {
+			return summary, fmt.Errorf("failed to parse conversation summary: %w", err)
+		}
+	}
+	return summary, nil
+}
+
+// SaveSummary stores the rolling summary in the session, keeping other session keys
+func (cs *ConversationService) SaveSummary(userID string, summary ai.ConversationSummary) error {
+	data, err := cs.sessionData(userID)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("failed to marshal conversation summary: %w", err)
+	}
+	data["summary"] = raw
+
+	return cs.UpdateSession(userID, data)
+}
+
+// GetPersona returns the name of the persona the user picked (empty if none)
+func (cs *ConversationService) GetPersona(userID string) (string, error) {
+	var persona string
+
+	data, err := cs.sessionData(userID)
+	if err != nil {
+		return persona, err
+	}
+	if raw, ok := data["persona"]; ok {
+		if err := json.Unmarshal(raw, &persona); err != nil {
+			return persona, fmt.Errorf("failed to parse persona: %w", err)
+		}
+	}
+	return persona, nil
+}
+
+// SetPersona stores the user's persona in the session, keeping other session keys
+func (cs *ConversationService) SetPersona(userID, persona string) error {
+	data, err := cs.sessionData(userID)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(persona)
+	if err != nil {
+		return fmt.Errorf("failed to marshal persona: %w", err)
+	}
+	data["persona"] = raw
+
+	return cs.UpdateSession(userID, data)
+}
+
+// DeleteConversations removes all conversations of a user and their vector items
+func (cs *ConversationService) DeleteConversations(userID string) error {
+	vectorsQuery := `
+		DELETE v FROM vector_items v
+		JOIN conversations c ON v.item_id = CAST(c.id AS CHAR)
+		WHERE v.collection = ? AND c.user_id = ?
+	`
+	query := `DELETE FROM conversations WHERE user_id = ?`
+
+	_, err := cs.deleteConversations(vectorsQuery, query, userID)
+	return err
+}
+
+// CleanOldConversations removes conversations older than specified days, with their vector items
 func (cs *ConversationService) CleanOldConversations(days int) error {
+	vectorsQuery := `
+		DELETE v FROM vector_items v
+		JOIN conversations c ON v.item_id = CAST(c.id AS CHAR)
+		WHERE v.collection = ? AND c.created_at < DATE_SUB(NOW(), INTERVAL ? DAY)
+	`
 	query := `
 		DELETE FROM conversations 
 		WHERE created_at < DATE_SUB(NOW(), INTERVAL ? DAY)
 	`
 
-	result, err := cs.db.conn.Exec(query, days)
+	rowsAffected, err := cs.deleteConversations(vectorsQuery, query, days)
 	if err != nil {
-		return fmt.Errorf("failed to clean old conversations: %w", err)
+		return err
 	}
-
-	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected > 0 {
 		fmt.Printf("🧹 Cleaned %d old conversation records\n", rowsAffected)
 	}
 
 	return nil
 }
+
+// deleteConversations runs vectorsQuery (with the collection and arg) and then query (with arg)
+// in one transaction, so no vector item outlives its conversation. It returns the deleted conversations.
+func (cs *ConversationService) deleteConversations(vectorsQuery, query string, arg any) (int64, error) {
+	tx, err := cs.db.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(vectorsQuery, ConversationVectors, arg); err != nil {
+		return 0, fmt.Errorf("failed to delete conversation vectors: %w", err)
+	}
+	result, err := tx.Exec(query, arg)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete conversations: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to delete conversations: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected, nil
+}
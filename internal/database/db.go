@@ -78,6 +78,73 @@ func (db *DB) createTables() error {
 	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
 	`
 
+	usageTable := `
+	CREATE TABLE IF NOT EXISTS token_usage (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		user_id VARCHAR(255) NOT NULL,
+		purpose VARCHAR(50) NOT NULL DEFAULT '',
+		model VARCHAR(100) NOT NULL DEFAULT '',
+		prompt_tokens INT NOT NULL DEFAULT 0,
+		completion_tokens INT NOT NULL DEFAULT 0,
+		reasoning_tokens INT NOT NULL DEFAULT 0,
+		total_tokens INT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		INDEX idx_user_created (user_id, created_at),
+		INDEX idx_created_at (created_at)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+
+	glossaryTable := `
+	CREATE TABLE IF NOT EXISTS translation_glossary (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		user_id VARCHAR(255) NOT NULL,
+		source_term VARCHAR(255) NOT NULL,
+		target_term VARCHAR(255) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		UNIQUE KEY unique_user_term (user_id, source_term)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+
+	cacheTable := `
+	CREATE TABLE IF NOT EXISTS response_cache (
+		cache_key CHAR(64) PRIMARY KEY,
+		model VARCHAR(100) NOT NULL DEFAULT '',
+		answer MEDIUMTEXT NOT NULL,
+		reasoning MEDIUMTEXT NOT NULL,
+		expires_at DATETIME(3) NOT NULL,
+		last_used_at DATETIME(3) NOT NULL,
+		INDEX idx_expires_at (expires_at),
+		INDEX idx_last_used_at (last_used_at)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+
+	vectorsTable := `
+	CREATE TABLE IF NOT EXISTS vector_items (
+		collection VARCHAR(100) NOT NULL,
+		item_id VARCHAR(255) NOT NULL,
+		dims INT NOT NULL,
+		embedding MEDIUMBLOB NOT NULL,
+		text TEXT NOT NULL,
+		meta JSON,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		PRIMARY KEY (collection, item_id)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+
+	documentsTable := `
+	CREATE TABLE IF NOT EXISTS documents (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		user_id VARCHAR(255) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		format VARCHAR(20) NOT NULL,
+		chars INT NOT NULL,
+		chunks INT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		INDEX idx_user_id (user_id)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+
 	if _, err := db.conn.Exec(conversationsTable); err != nil {
 		return fmt.Errorf("failed to create conversations table: %w", err)
 	}
@@ -90,6 +157,26 @@ func (db *DB) createTables() error {
 		return fmt.Errorf("failed to create user_memories table: %w", err)
 	}
 
+	if _, err := db.conn.Exec(usageTable); err != nil {
+		return fmt.Errorf("failed to create token_usage table: %w", err)
+	}
+
+	if _, err := db.conn.Exec(glossaryTable); err != nil {
+		return fmt.Errorf("failed to create translation_glossary table: %w", err)
+	}
+
+	if _, err := db.conn.Exec(cacheTable); err != nil {
+		return fmt.Errorf("failed to create response_cache table: %w", err)
+	}
+
+	if _, err := db.conn.Exec(vectorsTable); err != nil {
+		return fmt.Errorf("failed to create vector_items table: %w", err)
+	}
+
+	if _, err := db.conn.Exec(documentsTable); err != nil {
+		return fmt.Errorf("failed to create documents table: %w", err)
+	}
+
 	log.Println("✅ Database tables created/verified successfully")
 	return nil
 }
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"Qwen/internal/knowledge"
+	"database/sql"
+	"fmt"
+)
+
+// DocumentService stores the document list of each user's knowledge base.
+// The chunks of the documents are stored as vectors (see VectorService).
+type DocumentService struct {
+	db *DB
+}
+
+func NewDocumentService(db *DB) *DocumentService {
+	return &DocumentService{db: db}
+}
+
+var _ knowledge.Store = (*DocumentService)(nil)
+
+// SaveDocument records a document and returns its ID
+func (ds *DocumentService) SaveDocument(doc knowledge.Document) (int64, error) {
+	query := `
+		INSERT INTO documents (user_id, name, format, chars, chunks)
+		VALUES (?, ?, ?, ?, ?)
+	`
+
+	result, err := ds.db.conn.Exec(query, doc.UserID, doc.Name, doc.Format, doc.Chars, doc.Chunks)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save document: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get document ID: %w", err)
+	}
+	return id, nil
+}
+
+// ListDocuments returns the user's documents, oldest first
+func (ds *DocumentService) ListDocuments(userID string) ([]knowledge.Document, error) {
+	query := `
+		SELECT id, user_id, name, format, chars, chunks, created_at
+		FROM documents
+		WHERE user_id = ?
+		ORDER BY id
+	`
+
+	rows, err := ds.db.conn.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get documents: %w", err)
+	}
+	defer rows.Close()
+
+	var docs []knowledge.Document
+	for rows.Next() {
+		var doc knowledge.Document
+		err := rows.Scan(&doc.ID, &doc.UserID, &doc.Name, &doc.Format, &doc.Chars, &doc.Chunks, &doc.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan document: %w", err)
+		}
+		docs = append(docs, doc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get documents: %w", err)
+	}
+
+	return docs, nil
+}
+
+// DeleteDocument removes one of the user's documents and returns it (nil if there is none)
+func (ds *DocumentService) DeleteDocument(userID string, id int64) (*knowledge.Document, error) {
+	query := `
+		SELECT id, user_id, name, format, chars, chunks, created_at
+		FROM documents
+		WHERE id = ? AND user_id = ?
+	`
+
+	var doc knowledge.Document
+	err := ds.db.conn.QueryRow(query, id, userID).Scan(
+		&doc.ID, &doc.UserID, &doc.Name, &doc.Format, &doc.Chars, &doc.Chunks, &doc.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+
+	if _, err := ds.db.conn.Exec(`DELETE FROM documents WHERE id = ? AND user_id = ?`, id, userID); err != nil {
+		return nil, fmt.Errorf("failed to delete document: %w", err)
+	}
+	return &doc, nil
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"Qwen/internal/ai"
+	"fmt"
+	"strings"
+)
+
+// maxGlossaryTerms bounds the glossary sent along with every translation
+const maxGlossaryTerms = 100
+
+// GlossaryService stores per-user translation glossaries
+type GlossaryService struct {
+	db *DB
+}
+
+func NewGlossaryService(db *DB) *GlossaryService {
+	return &GlossaryService{db: db}
+}
+
+// SetTerm adds or replaces the translation of a term in the user's glossary
+func (gs *GlossaryService) SetTerm(userID, source, target string) error {
+	source, target = strings.TrimSpace(source), strings.TrimSpace(target)
+	if source == "" || target == "" {
+		return fmt.Errorf("glossary term and translation must not be empty")
+	}
+
+	// Replacing an existing term is always allowed, adding one only below the limit
+	var count, exists int
+	err := gs.db.conn.QueryRow(
+		`SELECT COUNT(*), COALESCE(SUM(source_term = ?), 0) FROM translation_glossary WHERE user_id = ?`,
+		source, userID,
+	).Scan(&count, &exists)
+	if err != nil {
+		return fmt.Errorf("failed to count glossary terms: %w", err)
+	}
+	if count >= maxGlossaryTerms && exists == 0 {
+		return fmt.Errorf("glossary is full (%d terms)", maxGlossaryTerms)
+	}
+
+	query := `
+		INSERT INTO translation_glossary (user_id, source_term, target_term)
+		VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE target_term = VALUES(target_term)
+	`
+	if _, err := gs.db.conn.Exec(query, userID, source, target); err != nil {
+		return fmt.Errorf("failed to save glossary term: %w", err)
+	}
+	return nil
+}
+
+// RemoveTerm deletes a term from the user's glossary and reports whether it existed
+func (gs *GlossaryService) RemoveTerm(userID, source string) (bool, error) {
+	result, err := gs.db.conn.Exec(`DELETE FROM translation_glossary WHERE user_id = ? AND source_term = ?`, userID, strings.TrimSpace(source))
+	if err != nil {
+		return false, fmt.Errorf("failed to remove glossary term: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// GetTerms returns the user's glossary ordered by term
+func (gs *GlossaryService) GetTerms(userID string) ([]ai.TranslationTerm, error) {
+	query := `
+		SELECT source_term, target_term
+		FROM translation_glossary
+		WHERE user_id = ?
+		ORDER BY source_term
+		LIMIT ?
+	`
+
+	rows, err := gs.db.conn.Query(query, userID, maxGlossaryTerms)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get glossary: %w", err)
+	}
+	defer rows.Close()
+
+	var terms []ai.TranslationTerm
+	for rows.Next() {
+		var term ai.TranslationTerm
+		if err := rows.Scan(&term.Source, &term.Target); err != nil {
+			return nil, fmt.Errorf("failed to scan glossary term: %w", err)
+		}
+		terms = append(terms, term)
+	}
+	return terms, rows.Err()
+}
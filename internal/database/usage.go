@@ -0,0 +1,191 @@
+package database
+
+import (
+	"Qwen/internal/ai"
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// UsageRecord is the token usage of a single upstream call
+type UsageRecord struct {
+	ID               int64     `json:"id"`
+	UserID           string    `json:"user_id"`
+	Purpose          string    `json:"purpose"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	ReasoningTokens  int       `json:"reasoning_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// UsageSummary aggregates usage of one user over a period (a day "2006-01-02" or a month "2006-01")
+type UsageSummary struct {
+	Period           string `json:"period,omitempty"`
+	UserID           string `json:"user_id"`
+	Requests         int    `json:"requests"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	ReasoningTokens  int    `json:"reasoning_tokens"`
+	TotalTokens      int    `json:"total_tokens"`
+}
+
+// unattributedUser is stored for calls made without an ai.UsageTag
+const unattributedUser = "system"
+
+// usageQueueSize bounds the records waiting to be written; further records are dropped and logged
+const usageQueueSize = 1024
+
+// UsageService stores token usage. Records are queued by RecordUsage and written by a
+// background worker, so streams never wait on the database.
+type UsageService struct {
+	db *DB
+
+	mu      sync.RWMutex
+	closed  bool
+	records chan UsageRecord
+	done    chan struct{}
+}
+
+// NewUsageService starts the worker writing recorded usage; Close stops it
+func NewUsageService(db *DB) *UsageService {
+	us := &UsageService{
+		db:      db,
+		records: make(chan UsageRecord, usageQueueSize),
+		done:    make(chan struct{}),
+	}
+	go us.run()
+	return us
+}
+
+func (us *UsageService) run() {
+	defer close(us.done)
+	for record := range us.records {
+		if err := us.SaveUsage(record); err != nil {
+			log.Printf("Failed to record token usage for %s: %v", record.UserID, err)
+		}
+	}
+}
+
+// Close writes the queued records and stops the worker. Usage recorded afterwards is dropped.
+func (us *UsageService) Close() {
+	us.mu.Lock()
+	if !us.closed {
+		us.closed = true
+		close(us.records)
+	}
+	us.mu.Unlock()
+	<-us.done
+}
+
+var _ ai.UsageRecorder = (*UsageService)(nil)
+
+// RecordUsage queues usage attributed to the ai.UsageTag carried by ctx without blocking.
+// Failures are logged so accounting never breaks a reply.
+func (us *UsageService) RecordUsage(ctx context.Context, usage ai.Usage) {
+	tag, _ := ai.UsageTagFrom(ctx)
+	record := UsageRecord{
+		UserID:           tag.UserID,
+		Purpose:          tag.Purpose,
+		Model:            usage.Model,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		ReasoningTokens:  usage.ReasoningTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+	if record.UserID == "" {
+		record.UserID = unattributedUser
+	}
+
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+	if us.closed {
+		return
+	}
+	select {
+	case us.records <- record:
+	default:
+		log.Printf("Dropped token usage of %s: %d records are waiting to be written", record.UserID, usageQueueSize)
+	}
+}
+
+// SaveUsage inserts a usage record
+func (us *UsageService) SaveUsage(record UsageRecord) error {
+	query := `
+		INSERT INTO token_usage (user_id, purpose, model, prompt_tokens, completion_tokens, reasoning_tokens, total_tokens)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := us.db.conn.Exec(query, record.UserID, record.Purpose, record.Model,
+		record.PromptTokens, record.CompletionTokens, record.ReasoningTokens, record.TotalTokens)
+	if err != nil {
+		return fmt.Errorf("failed to save token usage: %w", err)
+	}
+
+	return nil
+}
+
+// GetDailyUsage returns per-user totals for every day in [from, to), heaviest users first within a day
+func (us *UsageService) GetDailyUsage(from, to time.Time) ([]UsageSummary, error) {
+	return us.aggregate("DATE_FORMAT(created_at, '%Y-%m-%d')", from, to)
+}
+
+// GetMonthlyUsage returns per-user totals for every month in [from, to), heaviest users first within a month
+func (us *UsageService) GetMonthlyUsage(from, to time.Time) ([]UsageSummary, error) {
+	return us.aggregate("DATE_FORMAT(created_at, '%Y-%m')", from, to)
+}
+
+// GetUserUsage returns the totals of a single user since the given time
+func (us *UsageService) GetUserUsage(userID string, since time.Time) (UsageSummary, error) {
+	query := `
+		SELECT COUNT(*), COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0),
+			COALESCE(SUM(reasoning_tokens), 0), COALESCE(SUM(total_tokens), 0)
+		FROM token_usage
+		WHERE user_id = ? AND created_at >= ?
+	`
+
+	summary := UsageSummary{UserID: userID}
+	err := us.db.conn.QueryRow(query, userID, since).Scan(
+		&summary.Requests, &summary.PromptTokens, &summary.CompletionTokens,
+		&summary.ReasoningTokens, &summary.TotalTokens,
+	)
+	if err != nil {
+		return UsageSummary{}, fmt.Errorf("failed to get token usage: %w", err)
+	}
+
+	return summary, nil
+}
+
+// aggregate groups usage in [from, to) by period and user. period is a fixed SQL expression, never user input.
+func (us *UsageService) aggregate(period string, from, to time.Time) ([]UsageSummary, error) {
+	query := `
+		SELECT ` + period + ` AS period, user_id, COUNT(*), SUM(prompt_tokens), SUM(completion_tokens),
+			SUM(reasoning_tokens), SUM(total_tokens)
+		FROM token_usage
+		WHERE created_at >= ? AND created_at < ?
+		GROUP BY period, user_id
+		ORDER BY period, SUM(total_tokens) DESC
+	`
+
+	rows, err := us.db.conn.Query(query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate token usage: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []UsageSummary
+	for rows.Next() {
+		var s UsageSummary
+		err := rows.Scan(&s.Period, &s.UserID, &s.Requests, &s.PromptTokens, &s.CompletionTokens,
+			&s.ReasoningTokens, &s.TotalTokens)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan token usage: %w", err)
+		}
+		summaries = append(summaries, s)
+	}
+
+	return summaries, rows.Err()
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"Qwen/internal/vector"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// VectorService stores the items of vector collections in MySQL
+type VectorService struct {
+	db *DB
+}
+
+func NewVectorService(db *DB) *VectorService {
+	return &VectorService{db: db}
+}
+
+var _ vector.Store = (*VectorService)(nil)
+
+// LoadVectors returns every item of a collection
+func (vs *VectorService) LoadVectors(collection string) ([]vector.Item, error) {
+	query := `
+		SELECT item_id, embedding, text, meta
+		FROM vector_items
+		WHERE collection = ?
+	`
+
+	rows, err := vs.db.conn.Query(query, collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load vectors: %w", err)
+	}
+	defer rows.Close()
+
+	var items []vector.Item
+	for rows.Next() {
+		var item vector.Item
+		var embedding []byte
+		var meta []byte
+		if err := rows.Scan(&item.ID, &embedding, &item.Text, &meta); err != nil {
+			return nil, fmt.Errorf("failed to scan vector: %w", err)
+		}
+		if item.Vector, err = vector.DecodeVector(embedding); err != nil {
+			return nil, fmt.Errorf("failed to decode vector %s: %w", item.ID, err)
+		}
+		if len(meta) > 0 {
+			if err := json.Unmarshal(meta, &item.Meta); err != nil {
+				return nil, fmt.Errorf("failed to parse metadata of vector %s: %w", item.ID, err)
+			}
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load vectors: %w", err)
+	}
+
+	return items, nil
+}
+
+// SaveVectors inserts or replaces items of a collection in one transaction
+func (vs *VectorService) SaveVectors(collection string, items []vector.Item) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO vector_items (collection, item_id, dims, embedding, text, meta)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+		dims = VALUES(dims),
+		embedding = VALUES(embedding),
+		text = VALUES(text),
+		meta = VALUES(meta)
+	`
+
+	tx, err := vs.db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, item := range items {
+		var meta []byte
+		if len(item.Meta) > 0 {
+			if meta, err = json.Marshal(item.Meta); err != nil {
+				return fmt.Errorf("failed to marshal metadata of vector %s: %w", item.ID, err)
+			}
+		}
+		if _, err := tx.Exec(query, collection, item.ID, len(item.Vector), vector.EncodeVector(item.Vector), item.Text, meta); err != nil {
+			return fmt.Errorf("failed to save vector %s: %w", item.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save vectors: %w", err)
+	}
+	return nil
+}
+
+// DeleteVectors removes items of a collection
+func (vs *VectorService) DeleteVectors(collection string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := make([]any, 0, len(ids)+1)
+	args = append(args, collection)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	query := `DELETE FROM vector_items WHERE collection = ? AND item_id IN (?` + strings.Repeat(", ?", len(ids)-1) + `)`
+	if _, err := vs.db.conn.Exec(query, args...); err != nil {
+		return fmt.Errorf("failed to delete vectors: %w", err)
+	}
+	return nil
+}
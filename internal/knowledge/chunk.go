@@ -0,0 +1,148 @@
+package knowledge
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Chunk splits text into pieces of at most size characters. Paragraphs are kept together
+// when they fit, then sentences, then words. Each chunk after the first repeats up to
+// overlap characters from the end of the previous one, so a sentence cut at a boundary
+// is still found with its context.
+func Chunk(text string, size, overlap int) []string {
+	if size <= 0 {
+		return nil
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	var chunks []string
+	var current []string
+	length := 0
+	for _, piece := range pieces(text, size) {
+		n := runeLen(piece)
+		if length > 0 && length+1+n > size {
+			chunk := strings.Join(current, "\n")
+			chunks = append(chunks, chunk)
+			current, length = nil, 0
+			// The overlap is dropped when it leaves no room for the piece
+			if tail := overlapTail(chunk, overlap); tail != "" && runeLen(tail)+1+n <= size {
+				current, length = []string{tail}, runeLen(tail)
+			}
+		}
+		if length > 0 {
+			length++
+		}
+		current = append(current, piece)
+		length += n
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, strings.Join(current, "\n"))
+	}
+	return chunks
+}
+
+// pieces splits text into paragraphs of at most size characters, splitting longer ones
+// into sentences and words
+func pieces(text string, size int) []string {
+	var out []string
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		if runeLen(paragraph) <= size {
+			out = append(out, paragraph)
+			continue
+		}
+		for _, sentence := range sentences(paragraph) {
+			if runeLen(sentence) <= size {
+				out = append(out, sentence)
+				continue
+			}
+			out = append(out, splitWords(sentence, size)...)
+		}
+	}
+	return out
+}
+
+// sentences splits a paragraph after sentence punctuation and at line breaks
+func sentences(paragraph string) []string {
+	var out []string
+	runes := []rune(paragraph)
+	start := 0
+	for i, r := range runes {
+		end := r == '\n' ||
+			((r == '.' || r == '!' || r == '?' || r == '。') && (i+1 == len(runes) || unicode.IsSpace(runes[i+1])))
+		if end {
+			if s := strings.TrimSpace(string(runes[start : i+1])); s != "" {
+				out = append(out, s)
+			}
+			start = i + 1
+		}
+	}
+	if s := strings.TrimSpace(string(runes[start:])); s != "" {
+		out = append(out, s)
+	}
+	return out
+}
+
+// splitWords cuts text at word boundaries into parts of at most size characters;
+// words longer than size are cut anywhere
+func splitWords(text string, size int) []string {
+	var out []string
+	var b strings.Builder
+	n := 0
+	for _, word := range strings.Fields(text) {
+		for runeLen(word) > size {
+			if n > 0 {
+				out = append(out, b.String())
+				b.Reset()
+				n = 0
+			}
+			r := []rune(word)
+			out = append(out, string(r[:size]))
+			word = string(r[size:])
+		}
+		w := runeLen(word)
+		if n > 0 && n+1+w > size {
+			out = append(out, b.String())
+			b.Reset()
+			n = 0
+		}
+		if n > 0 {
+			b.WriteByte(' ')
+			n++
+		}
+		b.WriteString(word)
+		n += w
+	}
+	if n > 0 {
+		out = append(out, b.String())
+	}
+	return out
+}
+
+// overlapTail returns the last words of chunk, at most n characters
+func overlapTail(chunk string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	runes := []rune(chunk)
+	if len(runes) <= n {
+		return ""
+	}
+	tail := runes[len(runes)-n:]
+	// Start at a word boundary
+	for i, r := range tail {
+		if unicode.IsSpace(r) {
+			return strings.TrimSpace(string(tail[i:]))
+		}
+	}
+	return ""
+}
+
+func runeLen(s string) int {
+	return len([]rune(s))
+}
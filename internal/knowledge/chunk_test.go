@@ -0,0 +1,57 @@
+package knowledge
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChunkKeepsParagraphs(t *testing.T) {
+	text := "Paragraf satu.\n\nParagraf dua.\n\nParagraf tiga yang lebih panjang."
+	chunks := Chunk(text, 40, 0)
+	want := []string{"Paragraf satu.\nParagraf dua.", "Paragraf tiga yang lebih panjang."}
+	if strings.Join(chunks, "|") != strings.Join(want, "|") {
+		t.Errorf("Chunk() = %q, want %q", chunks, want)
+	}
+}
+
+func TestChunkBoundsAndOverlap(t *testing.T) {
+	var sentences []string
+	for i := 0; i < 40; i++ {
+		sentences = append(sentences, "Kalimat nomor "+strings.Repeat("x", i%7)+" selesai.")
+	}
+	text := strings.Join(sentences, " ") + "\n\n" + strings.Repeat("panjangsekali", 20)
+
+	chunks := Chunk(text, 100, 30)
+	if len(chunks) < 5 {
+		t.Fatalf("Expected several chunks, got %d", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if n := len([]rune(chunk)); n > 100 || n == 0 {
+			t.Errorf("chunks[%d] has %d characters", i, n)
+		}
+	}
+
+	// Each chunk of the sentences starts with the last words of the previous one
+	for i := 1; i < 3; i++ {
+		first := strings.SplitN(chunks[i], "\n", 2)[0]
+		if !strings.HasSuffix(chunks[i-1], first) {
+			t.Errorf("chunks[%d] does not start with the end of chunks[%d]: %q", i, i-1, chunks[i])
+		}
+	}
+
+	// Words longer than a chunk are cut
+	long := strings.Repeat("panjangsekali", 20)
+	found := false
+	for _, chunk := range chunks {
+		found = found || chunk == long[:100]
+	}
+	if !found {
+		t.Errorf("Expected the long word to be cut at 100 characters, got %q", chunks[len(chunks)-3:])
+	}
+}
+
+func TestChunkEmpty(t *testing.T) {
+	if chunks := Chunk(" \n\n ", 100, 10); len(chunks) != 0 {
+		t.Errorf("Chunk() = %q", chunks)
+	}
+}
@@ -0,0 +1,135 @@
+package knowledge
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var (
+	// ErrUnsupportedFormat is returned for files other than plain text, Markdown and unencrypted PDFs
+	ErrUnsupportedFormat = errors.New("unsupported document format")
+	// ErrNoText is returned when a document has no extractable text, such as a scanned PDF
+	ErrNoText = errors.New("document has no extractable text")
+)
+
+// Document formats
+const (
+	FormatText     = "text"
+	FormatMarkdown = "markdown"
+	FormatPDF      = "pdf"
+)
+
+// DetectFormat picks the format of a file from its name, then its MIME type, then its content
+func DetectFormat(name, mime string, data []byte) (string, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".txt", ".text", ".log":
+		return FormatText, nil
+	case ".md", ".markdown":
+		return FormatMarkdown, nil
+	case ".pdf":
+		return FormatPDF, nil
+	}
+
+	mime, _, _ = strings.Cut(strings.ToLower(mime), ";")
+	switch strings.TrimSpace(mime) {
+	case "text/plain":
+		return FormatText, nil
+	case "text/markdown", "text/x-markdown":
+		return FormatMarkdown, nil
+	case "application/pdf":
+		return FormatPDF, nil
+	}
+
+	if bytes.HasPrefix(data, []byte("%PDF-")) {
+		return FormatPDF, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, name)
+}
+
+// Extract returns the text of a document in the given format with whitespace normalized
+func Extract(format string, data []byte) (string, error) {
+	var text string
+	switch format {
+	case FormatText, FormatMarkdown:
+		var err error
+		if text, err = decodeText(data); err != nil {
+			return "", err
+		}
+	case FormatPDF:
+		var err error
+		if text, err = extractPDF(data); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+
+	text = normalizeText(text)
+	if !hasWords(text) {
+		return "", ErrNoText
+	}
+	return text, nil
+}
+
+// decodeText decodes UTF-8 (with or without a byte order mark) or UTF-16 with a byte order mark.
+// Other bytes are read as Windows-1252, the usual encoding of older text files.
+func decodeText(data []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return string(data[3:]), nil
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}), bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		bigEndian := data[0] == 0xFE
+		units := make([]uint16, 0, len(data)/2)
+		for i := 2; i+1 < len(data); i += 2 {
+			if bigEndian {
+				units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+			} else {
+				units = append(units, uint16(data[i+1])<<8|uint16(data[i]))
+			}
+		}
+		return string(utf16.Decode(units)), nil
+	case bytes.IndexByte(data, 0) >= 0:
+		return "", fmt.Errorf("%w: binary file", ErrUnsupportedFormat)
+	case utf8.Valid(data):
+		return string(data), nil
+	default:
+		return decodePDFText(data), nil
+	}
+}
+
+// normalizeText trims lines, collapses runs of spaces and blank lines, and drops control characters
+func normalizeText(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	var out []string
+	blank := false
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.Join(strings.FieldsFunc(line, func(r rune) bool {
+			return unicode.IsSpace(r) || unicode.IsControl(r)
+		}), " ")
+		if line == "" {
+			blank = len(out) > 0
+			continue
+		}
+		if blank {
+			out = append(out, "")
+			blank = false
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// hasWords reports whether text contains letters or digits
+func hasWords(text string) bool {
+	return strings.IndexFunc(text, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}) >= 0
+}
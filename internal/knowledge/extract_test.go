@@ -0,0 +1,163 @@
+package knowledge
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildPDF writes objects numbered from 1 in order, with a trailer rooted at object 1
+func buildPDF(objects ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	for i, obj := range objects {
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	b.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return b.Bytes()
+}
+
+func stream(dict, data string) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+func flateStream(dict, data string) string {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write([]byte(data))
+	w.Close()
+	return stream(dict+" /Filter /FlateDecode", buf.String())
+}
+
+func TestExtractPDF(t *testing.T) {
+	cmap := `/CIDInit /ProcSet findresource begin
+1 begincodespacerange <0000> <FFFF> endcodespacerange
+1 beginbfchar <0001> <0048> endbfchar
+1 beginbfrange <0002> <0003> <0069> endbfrange
+endcmap`
+	pdf := buildPDF(
+		`<< /Type /Catalog /Pages 2 0 R >>`,
+		`<< /Type /Pages /Kids [3 0 R 6 0 R] /Count 2 /Resources << /Font << /F1 4 0 R >> >> >>`,
+		`<< /Type /Page /Parent 2 0 R /Contents 5 0 R >>`,
+		`<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>`,
+		stream("", `BT /F1 12 Tf 72 700 Td (Hello, PDF \(world\)!) Tj 0 -14 Td [(Caf) 120 (\351) -300 (ok)] TJ ET`),
+		`<< /Type /Page /Parent 2 0 R /Contents 7 0 R /Resources << /Font << /F2 8 0 R >> >> >>`,
+		flateStream("", `BT /F2 11 Tf 1 0 0 1 72 700 Tm <00010002> Tj ET`),
+		`<< /Type /Font /Subtype /Type0 /BaseFont /Noto /ToUnicode 9 0 R >>`,
+		flateStream("", cmap),
+	)
+
+	text, err := Extract(FormatPDF, pdf)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	want := "Hello, PDF (world)!\nCafé ok\n\nHi"
+	if text != want {
+		t.Errorf("Extract() = %q, want %q", text, want)
+	}
+}
+
+func TestExtractPDFWithoutPageTree(t *testing.T) {
+	pdf := buildPDF(stream("", `BT /F1 12 Tf (Loose text) Tj ET`))
+	if text, err := Extract(FormatPDF, pdf); err != nil || text != "Loose text" {
+		t.Errorf("Extract() = %q, %v", text, err)
+	}
+}
+
+func TestExtractPDFRejects(t *testing.T) {
+	scanned := buildPDF(
+		`<< /Type /Catalog /Pages 2 0 R >>`,
+		`<< /Type /Pages /Kids [3 0 R] /Count 1 >>`,
+		`<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>`,
+		stream("", `q 600 0 0 800 0 0 cm /Im0 Do Q`),
+	)
+	if _, err := Extract(FormatPDF, scanned); !errors.Is(err, ErrNoText) {
+		t.Errorf("Expected ErrNoText for a scanned PDF, got %v", err)
+	}
+
+	encrypted := buildPDF(`<< /Type /Catalog >>`)
+	encrypted = bytes.Replace(encrypted, []byte("/Root 1 0 R"), []byte("/Root 1 0 R /Encrypt 2 0 R"), 1)
+	if _, err := Extract(FormatPDF, encrypted); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat for an encrypted PDF, got %v", err)
+	}
+
+	if _, err := Extract(FormatPDF, []byte("hello")); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat for a non-PDF, got %v", err)
+	}
+}
+
+func TestExtractPDFMalformed(t *testing.T) {
+	// Object streams with offsets outside their data are ignored rather than sliced
+	for _, dict := range []string{"/Type /ObjStm /N 1 /First -1", "/Type /ObjStm /N 1 /First 4", "/Type /ObjStm /N 1 /First 1e300"} {
+		pdf := buildPDF(stream(dict, "3 -9 (x)"), stream("", `BT (Still here) Tj ET`))
+		if text, err := Extract(FormatPDF, pdf); err != nil || text != "Still here" {
+			t.Errorf("%s: Extract() = %q, %v", dict, text, err)
+		}
+	}
+
+	pdf := buildPDF(`<< /Type /Catalog >>`, "<< /Length 1e300 >>\nstream\nBT (Long) Tj ET\nendstream")
+	if text, err := Extract(FormatPDF, pdf); err != nil || text != "Long" {
+		t.Errorf("Huge /Length: Extract() = %q, %v", text, err)
+	}
+}
+
+func TestExtractPDFDecompressionBomb(t *testing.T) {
+	bomb := flateStream("", "BT "+strings.Repeat(" ", maxPDFDecodedBytes)+"(boom) Tj ET")
+	if _, err := Extract(FormatPDF, buildPDF(bomb)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge, got %v", err)
+	}
+}
+
+func TestExtractText(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"utf8", []byte("  Halo   dunia \r\n\r\n\r\n\tbaris dua  "), "Halo dunia\n\nbaris dua"},
+		{"bom", []byte("\xEF\xBB\xBFteks"), "teks"},
+		{"utf16", []byte{0xFF, 0xFE, 'h', 0, 'i', 0}, "hi"},
+		{"windows-1252", []byte("caf\xe9 \x93ok\x94"), "café “ok”"},
+	}
+	for _, tt := range tests {
+		got, err := Extract(FormatText, tt.data)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: Extract() = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+
+	if _, err := Extract(FormatText, []byte("\x00\x01\x02")); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat for binary data, got %v", err)
+	}
+	if _, err := Extract(FormatMarkdown, []byte(" \n --- \n")); !errors.Is(err, ErrNoText) {
+		t.Errorf("Expected ErrNoText, got %v", err)
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name, mime string
+		data       string
+		want       string
+	}{
+		{"notes.TXT", "", "", FormatText},
+		{"README.md", "application/octet-stream", "", FormatMarkdown},
+		{"paper.pdf", "", "", FormatPDF},
+		{"upload", "text/plain; charset=utf-8", "", FormatText},
+		{"upload", "text/markdown", "", FormatMarkdown},
+		{"upload.bin", "", "%PDF-1.4", FormatPDF},
+	}
+	for _, tt := range tests {
+		if got, err := DetectFormat(tt.name, tt.mime, []byte(tt.data)); err != nil || got != tt.want {
+			t.Errorf("DetectFormat(%q, %q) = %q, %v, want %q", tt.name, tt.mime, got, err, tt.want)
+		}
+	}
+
+	_, err := DetectFormat("photo.jpg", "image/jpeg", []byte("\xff\xd8"))
+	if !errors.Is(err, ErrUnsupportedFormat) || !strings.Contains(err.Error(), "photo.jpg") {
+		t.Errorf("Expected ErrUnsupportedFormat naming the file, got %v", err)
+	}
+}
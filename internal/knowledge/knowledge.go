@@ -0,0 +1,325 @@
+// Package knowledge keeps a personal knowledge base of uploaded documents per user.
+// Documents are converted to text (plain text, Markdown or PDF, in pure Go), split
+// into overlapping chunks and embedded into a vector index; the chunks most similar
+// to a question are added to the prompt so answers are grounded in them.
+package knowledge
+
+import (
+	"Qwen/internal/ai"
+	"Qwen/internal/vector"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTooLarge is returned for files over Options.MaxBytes or with more than Options.MaxChunks chunks
+	ErrTooLarge = errors.New("document is too large")
+	// ErrLimitReached is returned when the user already has Options.MaxDocuments documents
+	ErrLimitReached = errors.New("knowledge base is full")
+	// ErrDisabled is returned by Ingest on a nil *Base
+	ErrDisabled = errors.New("knowledge base is not configured")
+)
+
+// Document describes an ingested document. Its chunks live in the vector index.
+type Document struct {
+	ID        int64     `json:"id"`
+	UserID    string    `json:"user_id"`
+	Name      string    `json:"name"`
+	Format    string    `json:"format"`
+	Chars     int       `json:"chars"`
+	Chunks    int       `json:"chunks"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Store keeps the document list of each user
+type Store interface {
+	SaveDocument(doc Document) (int64, error)
+	ListDocuments(userID string) ([]Document, error)
+	// DeleteDocument removes a document of the user and returns it, or nil if there is none
+	DeleteDocument(userID string, id int64) (*Document, error)
+}
+
+// Options bounds ingestion and tunes search
+type Options struct {
+	// ChunkChars and OverlapChars size the chunks, in characters
+	ChunkChars   int
+	OverlapChars int
+	// TopK is the most chunks added to a prompt, MinScore the lowest cosine similarity worth adding
+	TopK     int
+	MinScore float32
+	// MaxBytes, MaxChunks and MaxDocuments bound the files and documents of each user
+	MaxBytes     int
+	MaxChunks    int
+	MaxDocuments int
+}
+
+// DefaultOptions suits documents of up to a few hundred pages
+var DefaultOptions = Options{
+	ChunkChars:   1200,
+	OverlapChars: 150,
+	TopK:         4,
+	MinScore:     0.35,
+	MaxBytes:     10 << 20,
+	MaxChunks:    500,
+	MaxDocuments: 50,
+}
+
+// maxNameChars bounds stored document names
+const maxNameChars = 255
+
+// Index metadata keys of a chunk; the item ID is "<document ID>:<chunk number>" and the text the chunk
+const (
+	metaUser     = "user"
+	metaDocument = "document"
+	metaName     = "name"
+)
+
+// passagesHeader introduces the retrieved chunks in the system prompt
+const passagesHeader = "\n\nExcerpts from documents the user uploaded. When the message is about them, answer from these excerpts only, cite them as [name, part n], and say so if they do not contain the answer:\n"
+
+// Passage is a chunk of a document retrieved for a question
+type Passage struct {
+	DocumentID int64
+	Name       string
+	// Part is the 1-based number of the chunk in its document
+	Part  int
+	Text  string
+	Score float32
+}
+
+// Base is the knowledge base of all users. A nil *Base has no documents.
+type Base struct {
+	llm   ai.LLM
+	index vector.Index
+	store Store
+	opts  Options
+
+	mu sync.Mutex
+	// chunks counts the indexed chunks per user, so users without documents skip the embedding
+	chunks map[string]int
+}
+
+// New creates a knowledge base embedding with llm into index, usually a vector.Collection
+// so the chunks are kept in the database. Zero options take DefaultOptions.
+func New(llm ai.LLM, index vector.Index, store Store, opts Options) *Base {
+	if opts.ChunkChars <= 0 {
+		opts.ChunkChars = DefaultOptions.ChunkChars
+	}
+	if opts.OverlapChars <= 0 {
+		opts.OverlapChars = DefaultOptions.OverlapChars
+	}
+	if opts.TopK <= 0 {
+		opts.TopK = DefaultOptions.TopK
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultOptions.MaxBytes
+	}
+	if opts.MaxChunks <= 0 {
+		opts.MaxChunks = DefaultOptions.MaxChunks
+	}
+	if opts.MaxDocuments <= 0 {
+		opts.MaxDocuments = DefaultOptions.MaxDocuments
+	}
+
+	b := &Base{llm: llm, index: index, store: store, opts: opts, chunks: make(map[string]int)}
+	for _, item := range index.Items() {
+		b.chunks[item.Meta[metaUser]]++
+	}
+	return b
+}
+
+// Ingest extracts, chunks and indexes a file for userID and records it as a document
+func (b *Base) Ingest(ctx context.Context, userID, name, mime string, data []byte) (*Document, error) {
+	if b == nil {
+		return nil, ErrDisabled
+	}
+	if len(data) > b.opts.MaxBytes {
+		return nil, fmt.Errorf("%w: %d bytes, the limit is %d", ErrTooLarge, len(data), b.opts.MaxBytes)
+	}
+
+	docs, err := b.store.ListDocuments(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) >= b.opts.MaxDocuments {
+		return nil, fmt.Errorf("%w: %d documents", ErrLimitReached, len(docs))
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "document"
+	}
+	if r := []rune(name); len(r) > maxNameChars {
+		name = string(r[:maxNameChars])
+	}
+
+	format, err := DetectFormat(name, mime, data)
+	if err != nil {
+		return nil, err
+	}
+	text, err := safeExtract(format, data)
+	if err != nil {
+		return nil, err
+	}
+	chunks := Chunk(text, b.opts.ChunkChars, b.opts.OverlapChars)
+	if len(chunks) > b.opts.MaxChunks {
+		return nil, fmt.Errorf("%w: %d chunks, the limit is %d", ErrTooLarge, len(chunks), b.opts.MaxChunks)
+	}
+
+	ctx = ai.WithUsageTag(ctx, ai.UsageTag{UserID: userID, Purpose: ai.PurposeEmbedding})
+	vectors, err := b.llm.Embed(ctx, chunks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed document: %w", err)
+	}
+
+	doc := Document{
+		UserID:    userID,
+		Name:      name,
+		Format:    format,
+		Chars:     len([]rune(text)),
+		Chunks:    len(chunks),
+		CreatedAt: time.Now(),
+	}
+	if doc.ID, err = b.store.SaveDocument(doc); err != nil {
+		return nil, err
+	}
+
+	items := make([]vector.Item, len(chunks))
+	for i, chunk := range chunks {
+		items[i] = vector.Item{
+			ID:     chunkID(doc.ID, i),
+			Vector: vectors[i],
+			Text:   chunk,
+			Meta: map[string]string{
+				metaUser:     userID,
+				metaDocument: strconv.FormatInt(doc.ID, 10),
+				metaName:     name,
+			},
+		}
+	}
+	if err := b.index.Add(items...); err != nil {
+		if _, derr := b.store.DeleteDocument(userID, doc.ID); derr != nil {
+			log.Printf("⚠️ Failed to remove document %d after indexing failed: %v", doc.ID, derr)
+		}
+		return nil, fmt.Errorf("failed to index document: %w", err)
+	}
+
+	b.mu.Lock()
+	b.chunks[userID] += len(chunks)
+	b.mu.Unlock()
+
+	log.Printf("📚 Ingested %s for %s (%d chunks)", name, userID, len(chunks))
+	return &doc, nil
+}
+
+// Documents lists the documents of userID
+func (b *Base) Documents(userID string) ([]Document, error) {
+	if b == nil {
+		return nil, nil
+	}
+	return b.store.ListDocuments(userID)
+}
+
+// Delete removes a document of userID with its chunks and reports whether it existed
+func (b *Base) Delete(userID string, id int64) (bool, error) {
+	if b == nil {
+		return false, nil
+	}
+
+	doc, err := b.store.DeleteDocument(userID, id)
+	if err != nil || doc == nil {
+		return false, err
+	}
+
+	ids := make([]string, doc.Chunks)
+	for i := range ids {
+		ids[i] = chunkID(doc.ID, i)
+	}
+	b.index.Remove(ids...)
+
+	b.mu.Lock()
+	b.chunks[userID] -= doc.Chunks
+	if b.chunks[userID] <= 0 {
+		delete(b.chunks, userID)
+	}
+	b.mu.Unlock()
+	return true, nil
+}
+
+// Search returns up to TopK chunks of the documents of userID most similar to question, best first
+func (b *Base) Search(ctx context.Context, userID, question string) ([]Passage, error) {
+	if b == nil || strings.TrimSpace(question) == "" {
+		return nil, nil
+	}
+	b.mu.Lock()
+	has := b.chunks[userID] > 0
+	b.mu.Unlock()
+	if !has {
+		return nil, nil
+	}
+
+	ctx = ai.WithUsageTag(ctx, ai.UsageTag{UserID: userID, Purpose: ai.PurposeEmbedding})
+	vectors, err := b.llm.Embed(ctx, []string{question})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed question: %w", err)
+	}
+
+	results, err := b.index.Search(vectors[0], b.opts.TopK, vector.MetaFilter(map[string]string{metaUser: userID}))
+	if err != nil {
+		return nil, err
+	}
+
+	var passages []Passage
+	for _, result := range results {
+		if result.Score < b.opts.MinScore {
+			break
+		}
+		docID, part, _ := strings.Cut(result.ID, ":")
+		id, _ := strconv.ParseInt(docID, 10, 64)
+		n, _ := strconv.Atoi(part)
+		passages = append(passages, Passage{
+			DocumentID: id,
+			Name:       result.Meta[metaName],
+			Part:       n + 1,
+			Text:       result.Text,
+			Score:      result.Score,
+		})
+	}
+	return passages, nil
+}
+
+// WithPassages appends passages to the system prompt, labelled for citation
+func (b *Base) WithPassages(systemPrompt string, passages []Passage) string {
+	if b == nil || len(passages) == 0 {
+		return systemPrompt
+	}
+
+	var sb strings.Builder
+	sb.WriteString(systemPrompt)
+	sb.WriteString(passagesHeader)
+	for _, p := range passages {
+		fmt.Fprintf(&sb, "\n[%s, part %d]\n%s\n", p.Name, p.Part, p.Text)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// safeExtract runs Extract, turning a panic on a malformed upload into an error so it cannot crash the bot
+func safeExtract(format string, data []byte) (text string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("⚠️ Recovered from extracting a %s document: %v", format, r)
+			err = fmt.Errorf("%w: malformed %s file", ErrUnsupportedFormat, format)
+		}
+	}()
+	return Extract(format, data)
+}
+
+func chunkID(docID int64, n int) string {
+	return strconv.FormatInt(docID, 10) + ":" + strconv.Itoa(n)
+}
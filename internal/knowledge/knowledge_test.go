@@ -0,0 +1,151 @@
+package knowledge
+
+import (
+	"Qwen/internal/ai"
+	"Qwen/internal/vector"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type memoryStore struct {
+	docs   []Document
+	nextID int64
+}
+
+func (m *memoryStore) SaveDocument(doc Document) (int64, error) {
+	m.nextID++
+	doc.ID = m.nextID
+	m.docs = append(m.docs, doc)
+	return doc.ID, nil
+}
+
+func (m *memoryStore) ListDocuments(userID string) ([]Document, error) {
+	var docs []Document
+	for _, doc := range m.docs {
+		if doc.UserID == userID {
+			docs = append(docs, doc)
+		}
+	}
+	return docs, nil
+}
+
+func (m *memoryStore) DeleteDocument(userID string, id int64) (*Document, error) {
+	for i, doc := range m.docs {
+		if doc.ID == id && doc.UserID == userID {
+			m.docs = append(m.docs[:i], m.docs[i+1:]...)
+			return &doc, nil
+		}
+	}
+	return nil, nil
+}
+
+const recipes = `# Resep
+
+Rendang daging sapi dimasak dengan santan dan cabai selama empat jam.
+
+Soto ayam memakai kunyit, serai dan koya.
+
+Es teh manis cukup gula dan es batu.`
+
+func TestIngestAndSearch(t *testing.T) {
+	ctx := context.Background()
+	index := vector.NewFlat()
+	b := New(ai.NewFakeClient(), index, &memoryStore{}, Options{ChunkChars: 80, TopK: 2, MinScore: 0.1})
+
+	doc, err := b.Ingest(ctx, "42", "resep.md", "", []byte(recipes))
+	if err != nil {
+		t.Fatalf("Ingest() error = %v", err)
+	}
+	if doc.ID != 1 || doc.Format != FormatMarkdown || doc.Chunks != index.Len() || doc.Chunks < 2 {
+		t.Errorf("Unexpected document %+v with %d indexed chunks", doc, index.Len())
+	}
+	b.Ingest(ctx, "7", "lain.txt", "", []byte("Rendang ayam dengan santan."))
+
+	passages, err := b.Search(ctx, "42", "berapa lama masak rendang santan")
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(passages) == 0 || passages[0].DocumentID != 1 || passages[0].Name != "resep.md" || !strings.Contains(passages[0].Text, "Rendang daging") {
+		t.Fatalf("Search() = %+v", passages)
+	}
+
+	prompt := b.WithPassages("System", passages[:1])
+	want := fmt.Sprintf("System%s\n[resep.md, part %d]\n%s", passagesHeader, passages[0].Part, passages[0].Text)
+	if prompt != want {
+		t.Errorf("WithPassages() = %q, want %q", prompt, want)
+	}
+
+	if passages, _ := b.Search(ctx, "99", "rendang"); passages != nil {
+		t.Errorf("Users without documents should get nothing, got %+v", passages)
+	}
+}
+
+func TestDeleteRemovesChunks(t *testing.T) {
+	ctx := context.Background()
+	index := vector.NewFlat()
+	store := &memoryStore{}
+	b := New(ai.NewFakeClient(), index, store, Options{ChunkChars: 80})
+
+	doc, _ := b.Ingest(ctx, "42", "resep.md", "", []byte(recipes))
+	if removed, err := b.Delete("7", doc.ID); removed || err != nil {
+		t.Errorf("Delete() by another user = %v, %v", removed, err)
+	}
+	if removed, err := b.Delete("42", doc.ID); !removed || err != nil {
+		t.Fatalf("Delete() = %v, %v", removed, err)
+	}
+	if index.Len() != 0 || len(store.docs) != 0 {
+		t.Errorf("Expected no chunks and documents left, got %d and %d", index.Len(), len(store.docs))
+	}
+	if passages, _ := b.Search(ctx, "42", "rendang"); passages != nil {
+		t.Errorf("Search() after delete = %+v", passages)
+	}
+}
+
+func TestIngestLimits(t *testing.T) {
+	ctx := context.Background()
+	fake := ai.NewFakeClient()
+	b := New(fake, vector.NewFlat(), &memoryStore{}, Options{MaxBytes: 100, MaxDocuments: 1, ChunkChars: 20, MaxChunks: 2})
+
+	if _, err := b.Ingest(ctx, "42", "big.txt", "", make([]byte, 101)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge for the size, got %v", err)
+	}
+	if _, err := b.Ingest(ctx, "42", "long.txt", "", []byte(strings.Repeat("kata ", 19))); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge for the chunks, got %v", err)
+	}
+	if _, err := b.Ingest(ctx, "42", "foto.jpg", "image/jpeg", []byte{0xff, 0xd8}); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat, got %v", err)
+	}
+
+	fake.Err = ai.ErrRateLimited
+	if _, err := b.Ingest(ctx, "42", "a.txt", "", []byte("satu")); !errors.Is(err, ai.ErrRateLimited) {
+		t.Errorf("Expected the embedding error, got %v", err)
+	}
+	fake.Err = nil
+
+	if _, err := b.Ingest(ctx, "42", "a.txt", "", []byte("satu")); err != nil {
+		t.Fatalf("Ingest() error = %v", err)
+	}
+	if _, err := b.Ingest(ctx, "42", "b.txt", "", []byte("dua")); !errors.Is(err, ErrLimitReached) {
+		t.Errorf("Expected ErrLimitReached, got %v", err)
+	}
+
+	var disabled *Base
+	if _, err := disabled.Ingest(ctx, "42", "a.txt", "", []byte("satu")); !errors.Is(err, ErrDisabled) {
+		t.Errorf("Expected ErrDisabled, got %v", err)
+	}
+}
+
+func TestNewCountsStoredChunks(t *testing.T) {
+	ctx := context.Background()
+	index := vector.NewFlat()
+	New(ai.NewFakeClient(), index, &memoryStore{}, DefaultOptions).Ingest(ctx, "42", "a.txt", "", []byte("kopi susu"))
+
+	// A restarted base finds the user's chunks in the index
+	b := New(ai.NewFakeClient(), index, &memoryStore{}, Options{MinScore: 0.1})
+	if passages, err := b.Search(ctx, "42", "kopi"); err != nil || len(passages) != 1 {
+		t.Errorf("Search() = %+v, %v", passages, err)
+	}
+}
@@ -0,0 +1,880 @@
+package knowledge
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// PDF objects as parsed by pdfLexer. Numbers are float64, booleans bool and null nil.
+type (
+	pdfName    string
+	pdfKeyword string
+	pdfString  []byte
+	pdfArray   []any
+	pdfDict    map[pdfName]any
+	pdfRef     struct{ num, gen int }
+	pdfStream  struct {
+		dict pdfDict
+		raw  []byte
+	}
+)
+
+// maxPDFDepth bounds nesting in objects and the page tree, against malformed files
+const maxPDFDepth = 64
+
+// maxPDFDecodedBytes bounds the decompressed stream data of a document, against decompression bombs
+const maxPDFDecodedBytes = 64 << 20
+
+// pdfLexer reads PDF objects and content stream tokens
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case isPDFSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// next returns the next object or keyword; "]" and ">>" are returned as keywords
+func (l *pdfLexer) next(depth int) (any, error) {
+	if depth > maxPDFDepth {
+		return nil, errors.New("pdf objects nested too deeply")
+	}
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, io.EOF
+	}
+
+	c := l.data[l.pos]
+	switch {
+	case c == '/':
+		l.pos++
+		return pdfName(l.regular()), nil
+	case c == '(':
+		return l.literalString(), nil
+	case c == '<' && l.peek(1) == '<':
+		l.pos += 2
+		return l.dict(depth)
+	case c == '<':
+		return l.hexString(), nil
+	case c == '>' && l.peek(1) == '>':
+		l.pos += 2
+		return pdfKeyword(">>"), nil
+	case c == '[':
+		l.pos++
+		var arr pdfArray
+		for {
+			obj, err := l.next(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if obj == pdfKeyword("]") {
+				return arr, nil
+			}
+			arr = append(arr, obj)
+		}
+	case c == ']':
+		l.pos++
+		return pdfKeyword("]"), nil
+	case c == '{' || c == '}' || c == ')' || c == '>':
+		l.pos++
+		return pdfKeyword(string(c)), nil
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return l.number(), nil
+	}
+
+	word := l.regular()
+	switch word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	return pdfKeyword(word), nil
+}
+
+func (l *pdfLexer) peek(offset int) byte {
+	if l.pos+offset < len(l.data) {
+		return l.data[l.pos+offset]
+	}
+	return 0
+}
+
+// regular reads a run of regular characters, decoding #xx escapes in names
+func (l *pdfLexer) regular() string {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	word := string(l.data[start:l.pos])
+	if strings.Contains(word, "#") {
+		var b strings.Builder
+		for i := 0; i < len(word); i++ {
+			if word[i] == '#' && i+2 < len(word) {
+				if v, err := strconv.ParseUint(word[i+1:i+3], 16, 8); err == nil {
+					b.WriteByte(byte(v))
+					i += 2
+					continue
+				}
+			}
+			b.WriteByte(word[i])
+		}
+		word = b.String()
+	}
+	if l.pos == start {
+		// Not a token at all; skip the byte so parsing always advances
+		l.pos++
+	}
+	return word
+}
+
+// number reads a number, or an indirect reference "num gen R"
+func (l *pdfLexer) number() any {
+	start := l.pos
+	l.pos++
+	for l.pos < len(l.data) && (l.data[l.pos] == '.' || (l.data[l.pos] >= '0' && l.data[l.pos] <= '9')) {
+		l.pos++
+	}
+	text := string(l.data[start:l.pos])
+	value, _ := strconv.ParseFloat(text, 64)
+
+	// Look ahead for "gen R" after an integer
+	if !strings.ContainsAny(text, ".+-") {
+		save := l.pos
+		l.skipSpace()
+		genStart := l.pos
+		for l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '9' {
+			l.pos++
+		}
+		if l.pos > genStart {
+			gen, _ := strconv.Atoi(string(l.data[genStart:l.pos]))
+			l.skipSpace()
+			if l.peek(0) == 'R' && (l.pos+1 >= len(l.data) || isPDFSpace(l.peek(1)) || isPDFDelimiter(l.peek(1))) {
+				l.pos++
+				return pdfRef{num: int(value), gen: gen}
+			}
+		}
+		l.pos = save
+	}
+	return value
+}
+
+func (l *pdfLexer) literalString() pdfString {
+	l.pos++ // (
+	var out []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.peek(0) == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.peek(0) >= '0' && l.peek(0) <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func (l *pdfLexer) hexString() pdfString {
+	l.pos++ // <
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if c := l.data[l.pos]; !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++ // >
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, 0, len(digits)/2)
+	for i := 0; i < len(digits); i += 2 {
+		v, _ := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		out = append(out, byte(v))
+	}
+	return out
+}
+
+func (l *pdfLexer) dict(depth int) (pdfDict, error) {
+	d := make(pdfDict)
+	for {
+		key, err := l.next(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if key == pdfKeyword(">>") {
+			return d, nil
+		}
+		name, ok := key.(pdfName)
+		if !ok {
+			continue
+		}
+		value, err := l.next(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if value == pdfKeyword(">>") {
+			return d, nil
+		}
+		d[name] = value
+	}
+}
+
+// pdfDocument is the object table of a PDF file
+type pdfDocument struct {
+	objects map[int]any
+	// decoded counts the bytes inflated so far; err is set once it passes maxPDFDecodedBytes
+	decoded int
+	err     error
+}
+
+var (
+	pdfObjectHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	pdfEncrypt      = regexp.MustCompile(`/Encrypt\s*(\d+\s+\d+\s+R|<<)`)
+	pdfEndStream    = []byte("endstream")
+)
+
+// parsePDF reads every object of data. The cross-reference table is not needed: objects are
+// found by scanning for "n g obj", later definitions replacing earlier ones as in incremental updates.
+func parsePDF(data []byte) (*pdfDocument, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \r\n\t"), []byte("%PDF-")) {
+		return nil, fmt.Errorf("%w: not a PDF file", ErrUnsupportedFormat)
+	}
+	if pdfEncrypt.Match(data) {
+		return nil, fmt.Errorf("%w: encrypted PDF", ErrUnsupportedFormat)
+	}
+
+	doc := &pdfDocument{objects: make(map[int]any)}
+	// skipUntil is the end of the last stream, whose bytes may look like object headers
+	skipUntil := 0
+	for _, m := range pdfObjectHeader.FindAllSubmatchIndex(data, -1) {
+		if m[0] < skipUntil {
+			continue
+		}
+		num, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+		l := &pdfLexer{data: data, pos: m[1]}
+		obj, err := l.next(0)
+		if err != nil {
+			continue
+		}
+		if dict, ok := obj.(pdfDict); ok {
+			if kw, err := l.next(0); err == nil && kw == pdfKeyword("stream") {
+				var raw []byte
+				raw, skipUntil = streamData(data, l.pos, dict)
+				obj = pdfStream{dict: dict, raw: raw}
+			}
+		}
+		doc.objects[num] = obj
+	}
+
+	// Objects inside object streams (PDF 1.5+) are only defined there
+	for _, obj := range doc.objects {
+		if s, ok := obj.(pdfStream); ok && s.dict["Type"] == pdfName("ObjStm") {
+			doc.expandObjectStream(s)
+		}
+	}
+	if doc.err != nil {
+		return nil, doc.err
+	}
+	return doc, nil
+}
+
+// streamData returns the raw bytes of a stream starting after the "stream" keyword at pos,
+// and the offset where they end
+func streamData(data []byte, pos int, dict pdfDict) ([]byte, int) {
+	if pos < len(data) && data[pos] == '\r' {
+		pos++
+	}
+	if pos < len(data) && data[pos] == '\n' {
+		pos++
+	}
+	if length, ok := dict["Length"].(float64); ok && length >= 0 && length <= float64(len(data)-pos) {
+		end := pos + int(length)
+		if end <= len(data) && bytes.HasPrefix(bytes.TrimLeft(data[end:], " \r\n\t"), pdfEndStream) {
+			return data[pos:end], end
+		}
+	}
+	// The length is an indirect object or wrong: stop at endstream
+	end := bytes.Index(data[pos:], pdfEndStream)
+	if end < 0 {
+		return data[pos:], len(data)
+	}
+	return bytes.TrimRight(data[pos:pos+end], "\r\n"), pos + end
+}
+
+func (doc *pdfDocument) expandObjectStream(s pdfStream) {
+	data, err := doc.decode(s)
+	if err != nil {
+		return
+	}
+	n, _ := s.dict["N"].(float64)
+	first, ok := s.dict["First"].(float64)
+	if !ok || first < 0 || first > float64(len(data)) {
+		return
+	}
+
+	header := &pdfLexer{data: data[:int(first)]}
+	for i := 0; i < int(n); i++ {
+		objNum, err1 := header.next(0)
+		offset, err2 := header.next(0)
+		if err1 != nil || err2 != nil {
+			return
+		}
+		on, ok1 := objNum.(float64)
+		off, ok2 := offset.(float64)
+		if !ok1 || !ok2 || off < 0 || first+off >= float64(len(data)) {
+			return
+		}
+		if _, defined := doc.objects[int(on)]; defined {
+			continue
+		}
+		l := &pdfLexer{data: data, pos: int(first + off)}
+		if obj, err := l.next(0); err == nil {
+			doc.objects[int(on)] = obj
+		}
+	}
+}
+
+// resolve follows indirect references
+func (doc *pdfDocument) resolve(obj any) any {
+	for i := 0; i < maxPDFDepth; i++ {
+		ref, ok := obj.(pdfRef)
+		if !ok {
+			return obj
+		}
+		obj = doc.objects[ref.num]
+	}
+	return nil
+}
+
+func (doc *pdfDocument) dict(obj any) pdfDict {
+	switch v := doc.resolve(obj).(type) {
+	case pdfDict:
+		return v
+	case pdfStream:
+		return v.dict
+	}
+	return nil
+}
+
+// decode returns the decoded data of a stream; only FlateDecode and unfiltered streams are supported
+func (doc *pdfDocument) decode(s pdfStream) ([]byte, error) {
+	var filters []any
+	switch f := doc.resolve(s.dict["Filter"]).(type) {
+	case pdfName:
+		filters = []any{f}
+	case pdfArray:
+		filters = f
+	}
+
+	data := s.raw
+	for _, f := range filters {
+		switch doc.resolve(f) {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			out, err := inflate(data, maxPDFDecodedBytes-doc.decoded)
+			if err != nil {
+				if errors.Is(err, ErrTooLarge) {
+					doc.err = err
+				}
+				return nil, err
+			}
+			doc.decoded += len(out)
+			data = out
+		default:
+			return nil, fmt.Errorf("unsupported stream filter %v", f)
+		}
+	}
+	return data, nil
+}
+
+// inflate decompresses zlib data, tolerating missing checksums and raw deflate streams.
+// Output over limit bytes fails with ErrTooLarge.
+func inflate(data []byte, limit int) ([]byte, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("%w: over %d bytes of decompressed PDF data", ErrTooLarge, maxPDFDecodedBytes)
+	}
+	var r io.Reader
+	if zr, err := zlib.NewReader(bytes.NewReader(data)); err == nil {
+		r = zr
+	} else {
+		r = flate.NewReader(bytes.NewReader(data))
+	}
+	out, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if len(out) > limit {
+		return nil, fmt.Errorf("%w: over %d bytes of decompressed PDF data", ErrTooLarge, maxPDFDecodedBytes)
+	}
+	if len(out) > 0 {
+		return out, nil
+	}
+	return nil, err
+}
+
+// pages returns the page dictionaries in order with their inherited resources
+func (doc *pdfDocument) pages() []pdfPage {
+	var root pdfDict
+	for _, obj := range doc.objects {
+		if d, ok := obj.(pdfDict); ok && d["Type"] == pdfName("Catalog") {
+			root = d
+			break
+		}
+	}
+	if root == nil {
+		return nil
+	}
+
+	var pages []pdfPage
+	visited := make(map[int]bool)
+	var walk func(node any, resources pdfDict, depth int)
+	walk = func(node any, resources pdfDict, depth int) {
+		if ref, ok := node.(pdfRef); ok {
+			if visited[ref.num] {
+				return
+			}
+			visited[ref.num] = true
+		}
+		d := doc.dict(node)
+		if d == nil || depth > maxPDFDepth {
+			return
+		}
+		if r := doc.dict(d["Resources"]); r != nil {
+			resources = r
+		}
+		if kids, ok := doc.resolve(d["Kids"]).(pdfArray); ok {
+			for _, kid := range kids {
+				walk(kid, resources, depth+1)
+			}
+			return
+		}
+		pages = append(pages, pdfPage{dict: d, resources: resources})
+	}
+	walk(root["Pages"], nil, 0)
+	return pages
+}
+
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+// contents returns the decoded content streams of a page, concatenated
+func (doc *pdfDocument) contents(page pdfPage) []byte {
+	var parts []any
+	switch c := doc.resolve(page.dict["Contents"]).(type) {
+	case pdfArray:
+		parts = c
+	case pdfStream:
+		parts = []any{c}
+	}
+
+	var out []byte
+	for _, part := range parts {
+		if s, ok := doc.resolve(part).(pdfStream); ok {
+			if data, err := doc.decode(s); err == nil {
+				out = append(out, data...)
+				out = append(out, '\n')
+			}
+		}
+	}
+	return out
+}
+
+// fonts returns the text decoders of the fonts in resources, by resource name
+func (doc *pdfDocument) fonts(resources pdfDict) map[pdfName]*pdfFont {
+	fonts := make(map[pdfName]*pdfFont)
+	for name, ref := range doc.dict(resources["Font"]) {
+		font := doc.dict(ref)
+		if font == nil {
+			continue
+		}
+		f := &pdfFont{composite: font["Subtype"] == pdfName("Type0")}
+		if s, ok := doc.resolve(font["ToUnicode"]).(pdfStream); ok {
+			if data, err := doc.decode(s); err == nil {
+				f.cmap, f.codeBytes = parseToUnicode(data)
+			}
+		}
+		fonts[name] = f
+	}
+	return fonts
+}
+
+// pdfFont decodes the strings shown with a font
+type pdfFont struct {
+	// cmap maps character codes to text, from the font's ToUnicode map
+	cmap      map[uint32]string
+	codeBytes int
+	// composite fonts use multi-byte codes that mean nothing without a ToUnicode map
+	composite bool
+}
+
+func (f *pdfFont) decode(s []byte) string {
+	if f == nil || (f.cmap == nil && !f.composite) {
+		return decodePDFText(s)
+	}
+	if f.cmap == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	n := f.codeBytes
+	if n <= 0 {
+		n = 1
+		if f.composite {
+			n = 2
+		}
+	}
+	for i := 0; i+n <= len(s); i += n {
+		var code uint32
+		for _, c := range s[i : i+n] {
+			code = code<<8 | uint32(c)
+		}
+		if text, ok := f.cmap[code]; ok {
+			b.WriteString(text)
+		} else if !f.composite {
+			b.WriteString(decodePDFText(s[i : i+n]))
+		}
+	}
+	return b.String()
+}
+
+// parseToUnicode reads the bfchar and bfrange mappings of a ToUnicode CMap and
+// the length of its character codes
+func parseToUnicode(data []byte) (map[uint32]string, int) {
+	cmap := make(map[uint32]string)
+	codeBytes := 0
+	l := &pdfLexer{data: data}
+
+	var operands []any
+	var section string
+	for {
+		tok, err := l.next(0)
+		if err != nil {
+			break
+		}
+		kw, ok := tok.(pdfKeyword)
+		if !ok {
+			operands = append(operands, tok)
+			continue
+		}
+		switch kw {
+		case "begincodespacerange", "beginbfchar", "beginbfrange":
+			section = string(kw)
+		case "endcodespacerange":
+			if len(operands) >= 1 {
+				if lo, ok := operands[0].(pdfString); ok {
+					codeBytes = len(lo)
+				}
+			}
+			section = ""
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(pdfString)
+				dst, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 {
+					cmap[codeOf(src)] = utf16Text(dst)
+				}
+			}
+			section = ""
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if !ok1 || !ok2 || codeOf(hi) < codeOf(lo) || codeOf(hi)-codeOf(lo) > 0xFFFF {
+					continue
+				}
+				switch dst := operands[i+2].(type) {
+				case pdfString:
+					base := []rune(utf16Text(dst))
+					if len(base) == 0 {
+						continue
+					}
+					for code := codeOf(lo); code <= codeOf(hi); code++ {
+						r := append([]rune{}, base...)
+						r[len(r)-1] += rune(code - codeOf(lo))
+						cmap[code] = string(r)
+					}
+				case pdfArray:
+					for j, d := range dst {
+						if s, ok := d.(pdfString); ok {
+							cmap[codeOf(lo)+uint32(j)] = utf16Text(s)
+						}
+					}
+				}
+			}
+			section = ""
+		}
+		if section == "" || kw == "begincodespacerange" || kw == "beginbfchar" || kw == "beginbfrange" {
+			operands = operands[:0]
+		}
+	}
+	return cmap, codeBytes
+}
+
+func codeOf(s []byte) uint32 {
+	var code uint32
+	for _, c := range s {
+		code = code<<8 | uint32(c)
+	}
+	return code
+}
+
+func utf16Text(s []byte) string {
+	units := make([]uint16, 0, len(s)/2)
+	for i := 0; i+1 < len(s); i += 2 {
+		units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// winAnsiHigh maps the WinAnsiEncoding bytes 0x80-0x9F that differ from Latin-1
+var winAnsiHigh = map[byte]rune{
+	0x80: '€', 0x85: '…', 0x91: '‘', 0x92: '’', 0x93: '“', 0x94: '”',
+	0x95: '•', 0x96: '–', 0x97: '—', 0x99: '™',
+}
+
+// decodePDFText decodes a string of a simple font as WinAnsi, or UTF-16 when it has a byte order mark
+func decodePDFText(s []byte) string {
+	if len(s) >= 2 && s[0] == 0xFE && s[1] == 0xFF {
+		return utf16Text(s[2:])
+	}
+	runes := make([]rune, 0, len(s))
+	for _, c := range s {
+		if r, ok := winAnsiHigh[c]; ok {
+			runes = append(runes, r)
+		} else {
+			runes = append(runes, rune(c))
+		}
+	}
+	return string(runes)
+}
+
+// pdfTextWriter collects the text shown by content stream operators
+type pdfTextWriter struct {
+	b     strings.Builder
+	lastY float64
+}
+
+func (w *pdfTextWriter) newline() {
+	if w.b.Len() > 0 {
+		w.b.WriteByte('\n')
+	}
+}
+
+func (w *pdfTextWriter) space() {
+	if w.b.Len() > 0 {
+		w.b.WriteByte(' ')
+	}
+}
+
+// extractContent appends the text of a content stream, using fonts to decode strings
+func (w *pdfTextWriter) extractContent(content []byte, fonts map[pdfName]*pdfFont) {
+	l := &pdfLexer{data: content}
+	var operands []any
+	var font *pdfFont
+
+	for {
+		tok, err := l.next(0)
+		if err != nil {
+			return
+		}
+		op, ok := tok.(pdfKeyword)
+		if !ok {
+			operands = append(operands, tok)
+			continue
+		}
+
+		switch op {
+		case "BT":
+			w.lastY = 0
+		case "ET":
+			w.newline()
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[len(operands)-2].(pdfName); ok {
+					font = fonts[name]
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				if ty, _ := operands[len(operands)-1].(float64); ty != 0 {
+					w.newline()
+				} else {
+					w.space()
+				}
+			}
+		case "Tm":
+			if len(operands) >= 6 {
+				y, _ := operands[len(operands)-1].(float64)
+				if y != w.lastY {
+					w.newline()
+				} else {
+					w.space()
+				}
+				w.lastY = y
+			}
+		case "T*":
+			w.newline()
+		case "Tj", "'", "\"":
+			if op != "Tj" {
+				w.newline()
+			}
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].(pdfString); ok {
+					w.b.WriteString(font.decode(s))
+				}
+			}
+		case "TJ":
+			if len(operands) > 0 {
+				if arr, ok := operands[len(operands)-1].(pdfArray); ok {
+					for _, item := range arr {
+						switch v := item.(type) {
+						case pdfString:
+							w.b.WriteString(font.decode(v))
+						case float64:
+							// Large negative adjustments (in thousandths of an em) separate words
+							if v < -250 {
+								w.b.WriteByte(' ')
+							}
+						}
+					}
+				}
+			}
+		case "ID":
+			// Skip inline image data up to the EI operator
+			end := inlineImageEnd(l.data, l.pos)
+			if end < 0 {
+				return
+			}
+			l.pos = end
+		}
+		operands = operands[:0]
+	}
+}
+
+// inlineImageEnd returns the position after the EI operator ending inline image data at pos, or -1
+func inlineImageEnd(data []byte, pos int) int {
+	for pos < len(data) {
+		i := bytes.Index(data[pos:], []byte("EI"))
+		if i < 0 {
+			return -1
+		}
+		at := pos + i
+		if at > 0 && isPDFSpace(data[at-1]) && (at+2 == len(data) || isPDFSpace(data[at+2])) {
+			return at + 2
+		}
+		pos = at + 2
+	}
+	return -1
+}
+
+// extractPDF returns the text of a PDF, page by page. Text drawn with fonts that have a
+// ToUnicode map or a single-byte encoding is extracted; scanned pages yield nothing.
+func extractPDF(data []byte) (string, error) {
+	doc, err := parsePDF(data)
+	if err != nil {
+		return "", err
+	}
+
+	w := &pdfTextWriter{}
+	pages := doc.pages()
+	for _, page := range pages {
+		w.extractContent(doc.contents(page), doc.fonts(page.resources))
+		w.newline()
+		w.newline()
+		if doc.err != nil {
+			return "", doc.err
+		}
+	}
+	if len(pages) == 0 {
+		// No usable page tree: read every stream that looks like page content
+		nums := make([]int, 0, len(doc.objects))
+		for num := range doc.objects {
+			nums = append(nums, num)
+		}
+		sort.Ints(nums)
+		for _, num := range nums {
+			if s, ok := doc.objects[num].(pdfStream); ok {
+				if content, err := doc.decode(s); err == nil && bytes.Contains(content, []byte("BT")) {
+					w.extractContent(content, nil)
+					w.newline()
+				}
+			}
+			if doc.err != nil {
+				return "", doc.err
+			}
+		}
+	}
+	return w.b.String(), nil
+}
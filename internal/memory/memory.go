@@ -2,18 +2,22 @@ package memory
 
 import (
 	"Qwen/internal/ai"
+	"Qwen/internal/prompt"
 	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // MemoryService mengelola memory permanen user dengan LLM
 type MemoryService struct {
 	db       *sql.DB
-	aiClient *ai.Client
+	aiClient ai.LLM
+	prompts  *prompt.Library
 }
 
 // LLMResponse represents the response from LLM for memory management
@@ -22,49 +26,18 @@ type LLMResponse struct {
 	Reply        string          `json:"reply"`
 }
 
-// NewMemoryService membuat instance baru MemoryService
-func NewMemoryService(db *sql.DB, aiClient *ai.Client) *MemoryService {
+// NewMemoryService membuat instance baru MemoryService; prompts boleh nil untuk memakai template bawaan
+func NewMemoryService(db *sql.DB, aiClient ai.LLM, prompts *prompt.Library) *MemoryService {
 	return &MemoryService{
 		db:       db,
 		aiClient: aiClient,
+		prompts:  prompts,
 	}
 }
 
-// buildPrompt membuat prompt untuk LLM dengan instruksi memory management
+// buildPrompt membuat prompt untuk LLM dengan instruksi memory management dari template memory
 func (m *MemoryService) buildPrompt(currentMemory string, userMessage string) string {
-	systemPrompt := `You are an AI assistant connected to a persistent memory database.
-
-For every user message, you will:
-1. Read the current stored memory JSON (if any)
-2. Analyze the latest user message
-3. Decide if there is new or updated information to store
-4. Merge new information with existing memory
-5. Output the updated memory and a natural reply to the user
-
-Memory Rules:
-- Memory can contain: User profile (name, age, gender, location, language), Interests and preferences, Current goals or tasks, Past conversation summaries, Promises/commitments/unfinished discussions, Any unique facts the user shared
-- Replace old values if contradicted or updated
-- Avoid storing trivial or irrelevant details
-- Keep JSON concise (max 2KB)
-- Never invent facts — only store explicitly shared or strongly implied info
-
-Output Format (must always follow exactly):
-{
-  "memory_update": { ...merged updated memory JSON... },
-  "reply": "Natural, contextual reply to the user"
-}
-
-IMPORTANT: Always respond with valid JSON in the exact format above. Never include markdown formatting or explanations outside the JSON.`
-
-	userPrompt := fmt.Sprintf(`Current Memory:
-%s
-
-User Message:
-%s
-
-Please analyze and respond with the JSON format specified.`, currentMemory, userMessage)
-
-	return fmt.Sprintf("System: %s\n\nUser: %s", systemPrompt, userPrompt)
+	return m.prompts.Memory(prompt.Data{Memory: currentMemory, Message: userMessage, Now: time.Now()})
 }
 
 // SaveMemory menyimpan memory JSON ke database
@@ -138,7 +111,7 @@ func (m *MemoryService) ProcessMessage(userID int64, message string) (string, bo
 	}
 
 	// Build prompt untuk LLM
-	prompt := m.buildPrompt(currentMemory, message)
+	memoryPrompt := m.buildPrompt(currentMemory, message)
 
 	// Kirim ke LLM untuk analisis dan update memory
 	messages := []ai.Message{
@@ -148,11 +121,14 @@ func (m *MemoryService) ProcessMessage(userID int64, message string) (string, bo
 		},
 		{
 			Role:    "user",
-			Content: prompt,
+			Content: memoryPrompt,
 		},
 	}
 
-	ctx := context.Background()
+	ctx := ai.WithUsageTag(context.Background(), ai.UsageTag{
+		UserID:  strconv.FormatInt(userID, 10),
+		Purpose: ai.PurposeMemory,
+	})
 	response, err := m.aiClient.Chat(ctx, messages)
 	if err != nil {
 		log.Printf("❌ Error getting LLM response: %v", err)
@@ -0,0 +1,322 @@
+// Package prompt renders system prompts from text/template files. Each template named
+// <persona>.tmpl is a persona users can pick; memory.tmpl is the memory management prompt
+// and files starting with "_" are partials shared by the others. Built-in templates are
+// embedded in the binary; a directory of templates can override or extend them and is
+// reloaded while the bot runs.
+package prompt
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+//go:embed templates/*.tmpl
+var builtinFS embed.FS
+
+// DefaultPersona is the persona of users who have not picked one
+const DefaultPersona = "casual"
+
+// memoryTemplate is the name of the memory management prompt; it is not a persona
+const memoryTemplate = "memory"
+
+// Data holds the variables available to templates
+type Data struct {
+	// Memory is the JSON of what is known about the user, empty if nothing is
+	Memory string
+	// Message is the user message, set for the memory prompt
+	Message string
+	// Language is the name of the user's language ("Indonesian"), empty if unknown
+	Language string
+	// ChatType is "private", "group", "supergroup" or "channel" on Telegram and "web" on the WebSocket
+	ChatType string
+	Now      time.Time
+}
+
+// Date returns the date of Now, such as "Friday, 16 October 2026"
+func (d Data) Date() string {
+	return d.Now.Format("Monday, 2 January 2006")
+}
+
+// Time returns the time of Now with its zone, such as "14:05 WIB". The response cache
+// hashes the system prompt, so built-in templates only use Date to keep it stable all day.
+func (d Data) Time() string {
+	return d.Now.Format("15:04 MST")
+}
+
+// Persona is a selectable system prompt
+type Persona struct {
+	Name string `json:"name"`
+	// Description comes from a leading {{/* ... */}} comment of the template
+	Description string `json:"description"`
+}
+
+// descriptionPattern matches the leading comment of a persona template
+var descriptionPattern = regexp.MustCompile(`^\{\{-?\s*/\*\s*(.*?)\s*\*/\s*-?\}\}`)
+
+// set is one parsed generation of templates
+type set struct {
+	tmpl     *template.Template
+	personas map[string]Persona
+}
+
+// Library holds the prompt templates. A nil *Library renders the built-in templates.
+type Library struct {
+	dir            string
+	defaultPersona string
+
+	mu      sync.RWMutex
+	current *set
+	// checked identifies the directory contents Watch last loaded or failed to load; only Watch uses it
+	checked string
+}
+
+var (
+	builtin     *Library
+	builtinOnce sync.Once
+)
+
+// Default returns the library of built-in templates
+func Default() *Library {
+	builtinOnce.Do(func() {
+		s, _, err := load("")
+		if err != nil {
+			panic(fmt.Sprintf("prompt: invalid built-in templates: %v", err))
+		}
+		builtin = &Library{defaultPersona: DefaultPersona, current: s}
+	})
+	return builtin
+}
+
+// New loads the built-in templates and then the *.tmpl files of dir, which replace built-ins
+// of the same name. An empty dir uses the built-ins only. defaultPersona is the persona of
+// users who have not picked one; empty means DefaultPersona.
+func New(dir, defaultPersona string) (*Library, error) {
+	s, stamp, err := load(dir)
+	if err != nil {
+		return nil, err
+	}
+	if defaultPersona == "" {
+		defaultPersona = DefaultPersona
+	}
+	defaultPersona = strings.ToLower(defaultPersona)
+	if _, ok := s.personas[defaultPersona]; !ok {
+		return nil, fmt.Errorf("unknown default persona %q", defaultPersona)
+	}
+	return &Library{dir: dir, defaultPersona: defaultPersona, current: s, checked: stamp}, nil
+}
+
+// load parses the built-in templates followed by those of dir, returning the stamp of dir (see scan)
+func load(dir string) (*set, string, error) {
+	s := &set{tmpl: template.New(""), personas: make(map[string]Persona)}
+
+	builtins, _ := fs.Glob(builtinFS, "templates/*.tmpl")
+	for _, path := range builtins {
+		content, err := builtinFS.ReadFile(path)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := s.add(filepath.Base(path), string(content)); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if dir == "" {
+		return s, "", nil
+	}
+	paths, stamp, err := scan(dir)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read prompt template: %w", err)
+		}
+		if err := s.add(filepath.Base(path), string(content)); err != nil {
+			return nil, "", err
+		}
+	}
+	return s, stamp, nil
+}
+
+// add parses a template file, replacing any template of the same file name. Names are
+// lowercased, so templates include partials as {{template "_name.tmpl" .}}.
+func (s *set) add(file, content string) error {
+	file = strings.ToLower(file)
+	if _, err := s.tmpl.New(file).Parse(content); err != nil {
+		return fmt.Errorf("failed to parse prompt template %s: %w", file, err)
+	}
+
+	name := strings.TrimSuffix(file, ".tmpl")
+	if name == memoryTemplate || strings.HasPrefix(name, "_") {
+		return nil
+	}
+	persona := Persona{Name: name}
+	if m := descriptionPattern.FindStringSubmatch(content); m != nil {
+		persona.Description = m[1]
+	}
+	s.personas[name] = persona
+	return nil
+}
+
+// scan lists the templates of dir with a stamp that changes whenever one is added, removed or modified
+func scan(dir string) ([]string, string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return nil, "", fmt.Errorf("failed to read prompt directory: %w", err)
+	}
+	sort.Strings(paths)
+
+	var stamp strings.Builder
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read prompt template: %w", err)
+		}
+		fmt.Fprintf(&stamp, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+	}
+	return paths, stamp.String(), nil
+}
+
+func (l *Library) templates() *set {
+	if l == nil {
+		return Default().current
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.current
+}
+
+// Personas lists the selectable personas by name
+func (l *Library) Personas() []Persona {
+	s := l.templates()
+	personas := make([]Persona, 0, len(s.personas))
+	for _, p := range s.personas {
+		personas = append(personas, p)
+	}
+	sort.Slice(personas, func(i, j int) bool { return personas[i].Name < personas[j].Name })
+	return personas
+}
+
+// Persona looks up a persona by name, ignoring case
+func (l *Library) Persona(name string) (Persona, bool) {
+	p, ok := l.templates().personas[strings.ToLower(strings.TrimSpace(name))]
+	return p, ok
+}
+
+// DefaultPersona returns the persona of users who have not picked one
+func (l *Library) DefaultPersona() string {
+	if l == nil {
+		return DefaultPersona
+	}
+	return l.defaultPersona
+}
+
+// System renders the system prompt of a persona. Unknown or empty personas use the default
+// persona; if a template fails to render, the built-in default persona is used instead.
+func (l *Library) System(persona string, data Data) string {
+	name := strings.ToLower(strings.TrimSpace(persona))
+	if _, ok := l.Persona(name); !ok {
+		name = l.DefaultPersona()
+	}
+	return l.render(name, data)
+}
+
+// Memory renders the memory management prompt for data.Memory and data.Message
+func (l *Library) Memory(data Data) string {
+	return l.render(memoryTemplate, data)
+}
+
+func (l *Library) render(name string, data Data) string {
+	out, err := execute(l.templates(), name, data)
+	if err == nil {
+		return out
+	}
+	log.Printf("⚠️ Failed to render prompt %s: %v", name, err)
+
+	if name != memoryTemplate {
+		name = DefaultPersona
+	}
+	out, err = execute(Default().current, name, data)
+	if err != nil {
+		// Built-in templates are covered by tests, so this only happens on a broken build
+		panic(fmt.Sprintf("prompt: built-in template %s failed: %v", name, err))
+	}
+	return out
+}
+
+func execute(s *set, name string, data Data) (string, error) {
+	var buf bytes.Buffer
+	if err := s.tmpl.ExecuteTemplate(&buf, name+".tmpl", data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// Reload re-reads the template directory. On error the current templates are kept.
+func (l *Library) Reload() error {
+	if l == nil || l.dir == "" {
+		return nil
+	}
+	s, _, err := load(l.dir)
+	if err != nil {
+		return err
+	}
+	if _, ok := s.personas[l.defaultPersona]; !ok {
+		return fmt.Errorf("default persona %q is missing", l.defaultPersona)
+	}
+
+	l.mu.Lock()
+	l.current = s
+	l.mu.Unlock()
+	return nil
+}
+
+// Watch checks the template directory every interval and reloads it when files change,
+// until ctx is done. Templates that fail to parse are logged and the previous ones kept.
+func (l *Library) Watch(ctx context.Context, interval time.Duration) {
+	if l == nil || l.dir == "" || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		_, stamp, err := scan(l.dir)
+		if err != nil {
+			log.Printf("⚠️ Failed to check prompt templates: %v", err)
+			continue
+		}
+		if stamp == l.checked {
+			continue
+		}
+		// A broken change is reported once, not on every tick
+		l.checked = stamp
+		if err := l.Reload(); err != nil {
+			log.Printf("⚠️ Keeping previous prompt templates: %v", err)
+			continue
+		}
+		log.Printf("📝 Reloaded prompt templates from %s (%d personas)", l.dir, len(l.Personas()))
+	}
+}
@@ -0,0 +1,147 @@
+package prompt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testData = Data{
+	Memory:   `{"name":"Budi"}`,
+	Language: "Indonesian",
+	ChatType: "group",
+	Now:      time.Date(2026, 10, 16, 14, 5, 0, 0, time.FixedZone("WIB", 7*60*60)),
+}
+
+func TestBuiltinPersonas(t *testing.T) {
+	var l *Library
+	personas := l.Personas()
+	var names []string
+	for _, p := range personas {
+		names = append(names, p.Name)
+		if p.Description == "" {
+			t.Errorf("Persona %s has no description", p.Name)
+		}
+	}
+	if got := strings.Join(names, ","); got != "casual,coder,translator,tutor" {
+		t.Fatalf("Personas() = %s", got)
+	}
+
+	for _, p := range personas {
+		prompt := l.System(p.Name, testData)
+		for _, want := range []string{"Current date: Friday, 16 October 2026.", "Indonesian", "group chat", `{"name":"Budi"}`} {
+			if !strings.Contains(prompt, want) {
+				t.Errorf("System(%q) is missing %q:\n%s", p.Name, want, prompt)
+			}
+		}
+		if strings.Contains(prompt, "14:05") {
+			t.Errorf("System(%q) has the time of day, which changes the prompt every minute:\n%s", p.Name, prompt)
+		}
+		if strings.Contains(prompt, "{{") || strings.Contains(prompt, "<no value>") {
+			t.Errorf("System(%q) has unrendered template text:\n%s", p.Name, prompt)
+		}
+	}
+
+	private := l.System("casual", Data{ChatType: "private", Now: testData.Now})
+	if strings.Contains(private, "group chat") || strings.Contains(private, "Known information") || strings.Contains(private, "app language") {
+		t.Errorf("Private chats without memory or language should not mention them:\n%s", private)
+	}
+}
+
+func TestSystemFallsBackToDefaultPersona(t *testing.T) {
+	l, err := New("", "tutor")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if got, want := l.System("pirate", testData), l.System("TUTOR", testData); got != want {
+		t.Errorf("Unknown persona should use the default persona")
+	}
+	if _, err := New("", "pirate"); err == nil {
+		t.Error("Expected an error for an unknown default persona")
+	}
+}
+
+func TestMemoryPrompt(t *testing.T) {
+	prompt := Default().Memory(Data{Memory: "{}", Message: "Aku suka kopi"})
+	if !strings.HasPrefix(prompt, "System: You are an AI assistant connected to a persistent memory database.") ||
+		!strings.HasSuffix(prompt, "User: Current Memory:\n{}\n\nUser Message:\nAku suka kopi\n\nPlease analyze and respond with the JSON format specified.") {
+		t.Errorf("Memory() = %q", prompt)
+	}
+	if _, ok := Default().Persona("memory"); ok {
+		t.Error("The memory prompt should not be a persona")
+	}
+}
+
+func TestDirectoryOverridesAndReload(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("Casual.tmpl", `{{/* Custom */ -}} Hi {{.Language}}{{template "_context.tmpl" .}}`)
+	write("pirate.tmpl", `{{- /* Bajak laut */ -}} Arr, it is {{.Time}}`)
+
+	l, err := New(dir, "")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if p, ok := l.Persona("pirate"); !ok || p.Description != "Bajak laut" {
+		t.Errorf("Persona(pirate) = %+v, %v", p, ok)
+	}
+	if got := l.System("", testData); !strings.HasPrefix(got, "Hi Indonesian\nCurrent date:") {
+		t.Errorf("Override not used: %q", got)
+	}
+	if got := l.System("pirate", testData); got != "Arr, it is 14:05 WIB" {
+		t.Errorf("System(pirate) = %q", got)
+	}
+
+	// A template that fails to parse keeps the previous ones
+	write("pirate.tmpl", `Arr {{.Time`)
+	if err := l.Reload(); err == nil {
+		t.Error("Expected a parse error")
+	}
+	if got := l.System("pirate", testData); got != "Arr, it is 14:05 WIB" {
+		t.Errorf("Previous template not kept: %q", got)
+	}
+
+	// A template that fails to render falls back to the built-in default persona
+	write("pirate.tmpl", `Arr {{.Missing}}`)
+	if err := l.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got := l.System("pirate", testData); got != Default().System("casual", testData) {
+		t.Errorf("Expected the built-in casual prompt, got %q", got)
+	}
+
+	if _, err := New(filepath.Join(dir, "missing"), ""); err == nil {
+		t.Error("Expected an error for a missing directory")
+	}
+}
+
+func TestWatchReloadsChanges(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pirate.tmpl")
+	os.WriteFile(path, []byte("Arr"), 0o644)
+
+	l, err := New(dir, "")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go l.Watch(ctx, 5*time.Millisecond)
+
+	os.WriteFile(path, []byte("Ahoy, matey"), 0o644)
+	deadline := time.Now().Add(2 * time.Second)
+	for l.System("pirate", testData) != "Ahoy, matey" {
+		if time.Now().After(deadline) {
+			t.Fatalf("Template not reloaded, got %q", l.System("pirate", testData))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
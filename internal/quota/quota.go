@@ -0,0 +1,303 @@
+package quota
+
+import (
+	"Qwen/internal/ai"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrExceeded is matched by every *ExceededError
+var ErrExceeded = errors.New("quota exceeded")
+
+// Scopes of a limit
+const (
+	ScopeUser   = "user"
+	ScopeGlobal = "global"
+)
+
+// Resources a limit applies to
+const (
+	ResourceMessages = "messages"
+	ResourceTokens   = "tokens"
+)
+
+// Limit caps messages and tokens within a rolling window. Zero values mean unlimited.
+type Limit struct {
+	Messages int
+	Tokens   int
+	Window   time.Duration
+}
+
+func (l Limit) enabled() bool {
+	return l.Window > 0 && (l.Messages > 0 || l.Tokens > 0)
+}
+
+// Config holds the per-user and global limits
+type Config struct {
+	PerUser Limit
+	Global  Limit
+	// Allowlist contains user IDs exempt from all limits
+	Allowlist []string
+}
+
+// ExceededError reports which limit was hit and when it frees up again
+type ExceededError struct {
+	Scope      string
+	Resource   string
+	Limit      int
+	RetryAfter time.Duration
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("%s %s quota of %d exceeded, retry in %s", e.Scope, e.Resource, e.Limit, e.RetryAfter.Round(time.Second))
+}
+
+// Is makes errors.Is(err, ErrExceeded) true
+func (e *ExceededError) Is(target error) bool {
+	return target == ErrExceeded
+}
+
+type entry struct {
+	at       time.Time
+	messages int
+	tokens   int
+}
+
+// window is the list of recent entries of a user or of everyone
+type window struct {
+	entries []entry
+}
+
+// prune drops entries that left the window
+func (w *window) prune(cutoff time.Time) {
+	i := 0
+	for i < len(w.entries) && !w.entries[i].at.After(cutoff) {
+		i++
+	}
+	w.entries = w.entries[i:]
+}
+
+func (w *window) totals() (messages, tokens int) {
+	for _, e := range w.entries {
+		messages += e.messages
+		tokens += e.tokens
+	}
+	return messages, tokens
+}
+
+// retryAfter returns how long until enough of resource leaves the window to get under limit
+func (w *window) retryAfter(now time.Time, span time.Duration, resource string, limit int) time.Duration {
+	messages, tokens := w.totals()
+	used := messages
+	if resource == ResourceTokens {
+		used = tokens
+	}
+
+	for _, e := range w.entries {
+		if resource == ResourceTokens {
+			used -= e.tokens
+		} else {
+			used -= e.messages
+		}
+		if used < limit {
+			return e.at.Add(span).Sub(now)
+		}
+	}
+	return span
+}
+
+// Limiter enforces rolling-window quotas. Messages are counted by Allow;
+// tokens are reported through RecordUsage, so the limiter is also an ai.UsageRecorder.
+// State is kept in memory and starts empty on restart. A nil *Limiter allows everything.
+type Limiter struct {
+	mu        sync.Mutex
+	cfg       Config
+	allowlist map[string]bool
+	users     map[string]*window
+	global    window
+	now       func() time.Time
+	// swept is when idle user windows were last evicted
+	swept time.Time
+}
+
+var _ ai.UsageRecorder = (*Limiter)(nil)
+
+// New creates a limiter for cfg
+func New(cfg Config) *Limiter {
+	allowlist := make(map[string]bool)
+	for _, id := range cfg.Allowlist {
+		if id = strings.TrimSpace(id); id != "" {
+			allowlist[id] = true
+		}
+	}
+
+	return &Limiter{
+		cfg:       cfg,
+		allowlist: allowlist,
+		users:     make(map[string]*window),
+		now:       time.Now,
+	}
+}
+
+// Enabled reports whether any limit is configured
+func (l *Limiter) Enabled() bool {
+	return l != nil && (l.cfg.PerUser.enabled() || l.cfg.Global.enabled())
+}
+
+// Exempt reports whether userID is on the allowlist
+func (l *Limiter) Exempt(userID string) bool {
+	return l != nil && l.allowlist[userID]
+}
+
+// Allow checks the user's and the global quota and, when both have room, counts one message.
+// It returns an *ExceededError otherwise.
+func (l *Limiter) Allow(userID string) error {
+	if !l.Enabled() || l.Exempt(userID) {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	user := l.userWindow(userID, now)
+	if err := check(user, l.cfg.PerUser, ScopeUser, now); err != nil {
+		return err
+	}
+	if err := check(&l.global, l.cfg.Global, ScopeGlobal, now); err != nil {
+		return err
+	}
+
+	if l.cfg.PerUser.enabled() {
+		user.entries = append(user.entries, entry{at: now, messages: 1})
+	}
+	if l.cfg.Global.enabled() {
+		l.global.entries = append(l.global.entries, entry{at: now, messages: 1})
+	}
+	return nil
+}
+
+// RecordUsage counts the tokens of a finished call against the user in ctx's ai.UsageTag.
+// Calls without a tag only count towards the global quota.
+func (l *Limiter) RecordUsage(ctx context.Context, usage ai.Usage) {
+	if !l.Enabled() || usage.TotalTokens <= 0 {
+		return
+	}
+
+	tag, _ := ai.UsageTagFrom(ctx)
+	if l.Exempt(tag.UserID) {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if tag.UserID != "" && l.cfg.PerUser.enabled() {
+		user := l.userWindow(tag.UserID, now)
+		user.entries = append(user.entries, entry{at: now, tokens: usage.TotalTokens})
+	}
+	if l.cfg.Global.enabled() {
+		l.global.entries = append(l.global.entries, entry{at: now, tokens: usage.TotalTokens})
+	}
+}
+
+// userWindow returns the window of userID, creating it if needed. l.mu must be held.
+func (l *Limiter) userWindow(userID string, now time.Time) *window {
+	l.evictIdle(now)
+	w, ok := l.users[userID]
+	if !ok {
+		w = &window{}
+		l.users[userID] = w
+	}
+	return w
+}
+
+// evictIdle drops the windows of users whose entries all left the per-user window, so the
+// map only holds recently active users. It sweeps at most once per window. l.mu must be held.
+func (l *Limiter) evictIdle(now time.Time) {
+	span := l.cfg.PerUser.Window
+	if span <= 0 || now.Sub(l.swept) < span {
+		return
+	}
+	l.swept = now
+
+	cutoff := now.Add(-span)
+	for userID, w := range l.users {
+		if w.prune(cutoff); len(w.entries) == 0 {
+			delete(l.users, userID)
+		}
+	}
+}
+
+// check prunes w and reports whether limit is exhausted
+func check(w *window, limit Limit, scope string, now time.Time) error {
+	if !limit.enabled() {
+		return nil
+	}
+
+	w.prune(now.Add(-limit.Window))
+	messages, tokens := w.totals()
+
+	if limit.Messages > 0 && messages >= limit.Messages {
+		return &ExceededError{
+			Scope:      scope,
+			Resource:   ResourceMessages,
+			Limit:      limit.Messages,
+			RetryAfter: w.retryAfter(now, limit.Window, ResourceMessages, limit.Messages),
+		}
+	}
+	if limit.Tokens > 0 && tokens >= limit.Tokens {
+		return &ExceededError{
+			Scope:      scope,
+			Resource:   ResourceTokens,
+			Limit:      limit.Tokens,
+			RetryAfter: w.retryAfter(now, limit.Window, ResourceTokens, limit.Tokens),
+		}
+	}
+	return nil
+}
+
+// Reply returns a friendly message for an *ExceededError in the user's language.
+// languageCode is an IETF tag such as Telegram's language_code; Indonesian is the default.
+func Reply(err error, languageCode string) string {
+	var exceeded *ExceededError
+	if !errors.As(err, &exceeded) {
+		return ""
+	}
+
+	wait := humanDuration(exceeded.RetryAfter)
+	english := strings.HasPrefix(strings.ToLower(languageCode), "en")
+
+	switch {
+	case exceeded.Scope == ScopeGlobal && english:
+		return fmt.Sprintf("😴 I've been chatting a lot today and need a short break. Please try again in %s.", wait)
+	case exceeded.Scope == ScopeGlobal:
+		return fmt.Sprintf("😴 Aku lagi kebanyakan ngobrol hari ini dan perlu istirahat sebentar. Coba lagi dalam %s ya.", wait)
+	case english:
+		return fmt.Sprintf("⏳ You've reached your chat limit for now. Let's continue in %s!", wait)
+	default:
+		return fmt.Sprintf("⏳ Kamu sudah mencapai batas chat untuk saat ini. Kita lanjut lagi dalam %s ya!", wait)
+	}
+}
+
+// humanDuration formats d rounded to the minute, such as "3m" or "2h15m"
+func humanDuration(d time.Duration) string {
+	if d < time.Minute {
+		return "1m"
+	}
+	d = d.Round(time.Minute)
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	hours := int(d.Hours())
+	minutes := int(d.Minutes()) % 60
+	if minutes == 0 {
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dh%dm", hours, minutes)
+}
@@ -0,0 +1,151 @@
+package quota
+
+import (
+	"Qwen/internal/ai"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock lets tests move the limiter's time forward
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+func newTestLimiter(cfg Config) (*Limiter, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	l := New(cfg)
+	l.now = clock.now
+	return l, clock
+}
+
+func TestMessageQuotaRollingWindow(t *testing.T) {
+	l, clock := newTestLimiter(Config{PerUser: Limit{Messages: 2, Window: time.Hour}})
+
+	for i := 0; i < 2; i++ {
+		if err := l.Allow("alice"); err != nil {
+			t.Fatalf("Allow() #%d error = %v", i+1, err)
+		}
+		clock.advance(10 * time.Minute)
+	}
+
+	err := l.Allow("alice")
+	var exceeded *ExceededError
+	if !errors.As(err, &exceeded) || !errors.Is(err, ErrExceeded) {
+		t.Fatalf("Expected ExceededError, got %v", err)
+	}
+	if exceeded.Scope != ScopeUser || exceeded.Resource != ResourceMessages {
+		t.Errorf("Unexpected limit %+v", exceeded)
+	}
+	// The first message was sent 20 minutes ago and leaves the window in 40 minutes
+	if exceeded.RetryAfter != 40*time.Minute {
+		t.Errorf("Expected RetryAfter 40m, got %s", exceeded.RetryAfter)
+	}
+
+	if err := l.Allow("bob"); err != nil {
+		t.Errorf("Other users must not be limited: %v", err)
+	}
+
+	clock.advance(41 * time.Minute)
+	if err := l.Allow("alice"); err != nil {
+		t.Errorf("Expected room after the first message left the window, got %v", err)
+	}
+}
+
+func TestTokenQuotaUsesRecordedUsage(t *testing.T) {
+	l, clock := newTestLimiter(Config{PerUser: Limit{Tokens: 1000, Window: 24 * time.Hour}})
+	ctx := ai.WithUsageTag(context.Background(), ai.UsageTag{UserID: "alice", Purpose: ai.PurposeChat})
+
+	if err := l.Allow("alice"); err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	l.RecordUsage(ctx, ai.Usage{TotalTokens: 600})
+	clock.advance(time.Hour)
+	l.RecordUsage(ai.WithUsageTag(ctx, ai.UsageTag{UserID: "alice", Purpose: ai.PurposeMemory}), ai.Usage{TotalTokens: 500})
+
+	err := l.Allow("alice")
+	var exceeded *ExceededError
+	if !errors.As(err, &exceeded) || exceeded.Resource != ResourceTokens {
+		t.Fatalf("Expected token quota error, got %v", err)
+	}
+	if exceeded.RetryAfter != 23*time.Hour {
+		t.Errorf("Expected RetryAfter 23h, got %s", exceeded.RetryAfter)
+	}
+}
+
+func TestIdleUsersAreEvicted(t *testing.T) {
+	l, clock := newTestLimiter(Config{PerUser: Limit{Messages: 5, Window: time.Hour}})
+
+	l.Allow("alice")
+	l.Allow("bob")
+	clock.advance(40 * time.Minute)
+	l.Allow("bob")
+	if len(l.users) != 2 {
+		t.Fatalf("Expected 2 user windows, got %d", len(l.users))
+	}
+
+	// Alice's only message left the window; Bob's second one has not
+	clock.advance(30 * time.Minute)
+	l.Allow("carol")
+	if _, ok := l.users["alice"]; ok || len(l.users) != 2 {
+		t.Errorf("Expected only alice to be evicted, got %d windows", len(l.users))
+	}
+	if messages, _ := l.users["bob"].totals(); messages != 1 {
+		t.Errorf("Expected bob to keep 1 message, got %d", messages)
+	}
+}
+
+func TestGlobalQuotaAndAllowlist(t *testing.T) {
+	l, _ := newTestLimiter(Config{
+		Global:    Limit{Messages: 2, Window: time.Hour},
+		Allowlist: []string{"admin"},
+	})
+
+	if err := l.Allow("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Allow("bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := l.Allow("carol")
+	var exceeded *ExceededError
+	if !errors.As(err, &exceeded) || exceeded.Scope != ScopeGlobal {
+		t.Fatalf("Expected global quota error, got %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := l.Allow("admin"); err != nil {
+			t.Fatalf("Allowlisted user was limited: %v", err)
+		}
+	}
+}
+
+func TestNilAndDisabledLimiter(t *testing.T) {
+	var l *Limiter
+	if err := l.Allow("alice"); err != nil {
+		t.Errorf("nil limiter must allow everything, got %v", err)
+	}
+
+	disabled := New(Config{PerUser: Limit{Messages: 1}})
+	for i := 0; i < 3; i++ {
+		if err := disabled.Allow("alice"); err != nil {
+			t.Errorf("Limit without a window must be ignored, got %v", err)
+		}
+	}
+}
+
+func TestReply(t *testing.T) {
+	err := &ExceededError{Scope: ScopeUser, Resource: ResourceMessages, Limit: 5, RetryAfter: 90 * time.Minute}
+
+	if got, want := Reply(err, "id"), "⏳ Kamu sudah mencapai batas chat untuk saat ini. Kita lanjut lagi dalam 1h30m ya!"; got != want {
+		t.Errorf("Reply(id) = %q, want %q", got, want)
+	}
+	if got, want := Reply(err, "en-US"), "⏳ You've reached your chat limit for now. Let's continue in 1h30m!"; got != want {
+		t.Errorf("Reply(en) = %q, want %q", got, want)
+	}
+	if got := Reply(errors.New("other"), "id"); got != "" {
+		t.Errorf("Expected empty reply for other errors, got %q", got)
+	}
+}
@@ -2,22 +2,37 @@ package server
 
 import (
 	"Qwen/internal/ai"
+	"Qwen/internal/database"
+	"Qwen/internal/knowledge"
+	"Qwen/internal/prompt"
+	"Qwen/internal/quota"
 	"Qwen/internal/websocket"
+	"crypto/subtle"
+	"encoding/json"
 	"log"
 	"net/http"
+	"strings"
 )
 
 type Server struct {
-	hub  *websocket.Hub
-	port string
+	hub      *websocket.Hub
+	aiClient ai.LLM
+	port     string
+	// authSecret also unlocks the full /status; empty serves only the public one
+	authSecret string
 }
 
-func NewServer(aiClient *ai.Client, port string) *Server {
-	hub := websocket.NewHub(aiClient)
+// NewServer creates the HTTP server; convService may be nil when no database is configured.
+// authSecret signs the tokens of authenticated WebSocket clients (see websocket.Token)
+// and, sent as "Authorization: Bearer <authSecret>", shows upstream hosts and errors in /status.
+func NewServer(aiClient ai.LLM, convService *database.ConversationService, glossary *database.GlossaryService, limiter *quota.Limiter, contextBuilder *ai.ContextBuilder, retriever *ai.Retriever, knowledgeBase *knowledge.Base, prompts *prompt.Library, authSecret, port string) *Server {
+	hub := websocket.NewHub(aiClient, convService, glossary, limiter, contextBuilder, retriever, knowledgeBase, prompts, authSecret)
 
 	return &Server{
-		hub:  hub,
-		port: port,
+		hub:        hub,
+		aiClient:   aiClient,
+		port:       port,
+		authSecret: authSecret,
 	}
 }
 
@@ -29,6 +44,7 @@ func (s *Server) Start() error {
 	http.HandleFunc("/ws", s.hub.ServeWS)
 	http.HandleFunc("/", s.serveHome)
 	http.HandleFunc("/health", s.healthCheck)
+	http.HandleFunc("/status", s.status)
 
 	log.Printf("HTTP server starting on port %s", s.port)
 	return http.ListenAndServe(":"+s.port, nil)
@@ -54,6 +70,37 @@ func (s *Server) healthCheck(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`{"status": "healthy"}`))
 }
 
+// status reports the model router and the circuit breakers of the fallback chain.
+// Base URLs and upstream errors are only shown to authorized callers.
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	client, ok := s.aiClient.(*ai.Client)
+	if !ok {
+		http.Error(w, "Status is only available for the dashscope and openai providers", http.StatusNotImplemented)
+		return
+	}
+
+	status := client.Status()
+	if !s.authorized(r) {
+		status = status.Public()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		log.Printf("Failed to write status: %v", err)
+	}
+}
+
+// authorized reports whether r carries the auth secret as a bearer token
+func (s *Server) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && s.authSecret != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.authSecret)) == 1
+}
+
 const homeHTML = `<!DOCTYPE html>
 <html>
 <head>
@@ -106,6 +153,7 @@ const homeHTML = `<!DOCTYPE html>
         .streaming { background: #e2e3e5; color: #383d41; }
         .complete { background: #d1ecf1; color: #0c5460; font-weight: bold; }
         .error { background: #f8d7da; color: #721c24; }
+        .cancelled { background: #fff3cd; color: #856404; }
         
         .input-container {
             display: flex;
@@ -134,6 +182,19 @@ const homeHTML = `<!DOCTYPE html>
             background: #6c757d;
             cursor: not-allowed;
         }
+        #stopButton {
+            display: none;
+            padding: 12px 24px;
+            background: #dc3545;
+            color: white;
+            border: none;
+            border-radius: 6px;
+            cursor: pointer;
+            font-size: 14px;
+        }
+        #stopButton.show {
+            display: block;
+        }
         .status {
             text-align: center;
             margin-bottom: 20px;
@@ -172,6 +233,7 @@ const homeHTML = `<!DOCTYPE html>
             <input type="text" id="messageInput" placeholder="Type your message here..." 
                    onkeypress="if(event.key==='Enter') sendMessage()">
             <button id="sendButton" onclick="sendMessage()">Send</button>
+            <button id="stopButton" onclick="stopGeneration()">Stop</button>
         </div>
     </div>
 
@@ -183,12 +245,15 @@ const homeHTML = `<!DOCTYPE html>
         const sendButton = document.getElementById('sendButton');
         const status = document.getElementById('status');
         const typingIndicator = document.getElementById('typingIndicator');
+        const stopButton = document.getElementById('stopButton');
         
         let currentStreamingMessage = null;
+        let currentGenerationId = null;
+        let generationCounter = 0;
         
         function connect() {
             const wsProtocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-            const wsUrl = wsProtocol + '//' + window.location.host + '/ws?user_id=web_user';
+            const wsUrl = wsProtocol + '//' + window.location.host + '/ws?' + (window.location.search.slice(1) || 'user_id=web_user');
             
             ws = new WebSocket(wsUrl);
             
@@ -201,6 +266,7 @@ const homeHTML = `<!DOCTYPE html>
             
             ws.onclose = function() {
                 isConnected = false;
+                finishGeneration();
                 status.textContent = 'Disconnected';
                 status.className = 'status disconnected';
                 sendButton.disabled = true;
@@ -227,13 +293,31 @@ const homeHTML = `<!DOCTYPE html>
             addMessage('user', text);
             
             // Send to WebSocket
+            currentGenerationId = 'web-' + (++generationCounter);
             ws.send(JSON.stringify({
                 type: 'user_message',
-                content: text
+                content: text,
+                id: currentGenerationId
             }));
             
             messageInput.value = '';
             currentStreamingMessage = null;
+            stopButton.classList.add('show');
+        }
+        
+        function stopGeneration() {
+            if (!currentGenerationId || !isConnected) return;
+            ws.send(JSON.stringify({
+                type: 'cancel',
+                id: currentGenerationId
+            }));
+        }
+        
+        function finishGeneration() {
+            hideTypingIndicator();
+            stopButton.classList.remove('show');
+            currentStreamingMessage = null;
+            currentGenerationId = null;
         }
         
         function handleAIMessage(message) {
@@ -250,15 +334,20 @@ const homeHTML = `<!DOCTYPE html>
                     scrollToBottom();
                 } else if (stage === 'complete') {
                     // Response complete
-                    hideTypingIndicator();
                     if (currentStreamingMessage) {
                         currentStreamingMessage.className = 'message ai-message complete';
                     }
-                    currentStreamingMessage = null;
+                    finishGeneration();
+                } else if (stage === 'cancelled') {
+                    if (currentStreamingMessage) {
+                        currentStreamingMessage.className = 'message ai-message cancelled';
+                    } else {
+                        addMessage('ai', '⏹ Stopped', 'cancelled');
+                    }
+                    finishGeneration();
                 } else if (stage === 'error') {
-                    hideTypingIndicator();
                     addMessage('ai', message.content, 'error');
-                    currentStreamingMessage = null;
+                    finishGeneration();
                 }
             }
         }
@@ -0,0 +1,89 @@
+package vector
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+)
+
+// Store persists the items of named collections, such as "conversations" or "memory"
+type Store interface {
+	LoadVectors(collection string) ([]Item, error)
+	SaveVectors(collection string, items []Item) error
+	DeleteVectors(collection string, ids []string) error
+}
+
+// Collection is an Index backed by a Store: changes are written through and
+// Open loads the stored items. A nil store keeps the collection in memory.
+type Collection struct {
+	Index
+	name  string
+	store Store
+}
+
+// Open loads the named collection from store into index
+func Open(store Store, name string, index Index) (*Collection, error) {
+	c := &Collection{Index: index, name: name, store: store}
+	if store == nil {
+		return c, nil
+	}
+
+	items, err := store.LoadVectors(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := index.Add(items...); err != nil {
+		return nil, fmt.Errorf("failed to index collection %s: %w", name, err)
+	}
+	return c, nil
+}
+
+// Name returns the collection name
+func (c *Collection) Name() string {
+	return c.name
+}
+
+// Add indexes items, then stores them. Items the index rejects are not stored; on a store
+// failure the items stay searchable until restart.
+func (c *Collection) Add(items ...Item) error {
+	if err := c.Index.Add(items...); err != nil {
+		return err
+	}
+	if c.store != nil {
+		return c.store.SaveVectors(c.name, items)
+	}
+	return nil
+}
+
+// Remove deletes items from the index and the store. Store failures are logged,
+// since Index.Remove cannot report them.
+func (c *Collection) Remove(ids ...string) {
+	c.Index.Remove(ids...)
+	if c.store != nil {
+		if err := c.store.DeleteVectors(c.name, ids); err != nil {
+			log.Printf("Failed to delete vectors from %s: %v", c.name, err)
+		}
+	}
+}
+
+// EncodeVector serializes v as little-endian float32s, 4 bytes per dimension
+func EncodeVector(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(x))
+	}
+	return buf
+}
+
+// DecodeVector parses the output of EncodeVector
+func DecodeVector(buf []byte) ([]float32, error) {
+	if len(buf)%4 != 0 {
+		return nil, fmt.Errorf("invalid vector encoding of %d bytes", len(buf))
+	}
+	v := make([]float32, len(buf)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return v, nil
+}
@@ -0,0 +1,268 @@
+// Package vector is a small in-process vector index for semantic search over
+// embeddings (see ai.Client.Embed). Indexes rank by cosine similarity, either
+// exactly (Flat) or approximately (LSH), and a Collection persists one to a Store.
+package vector
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// ErrDimensionMismatch is returned when a vector's size differs from the index's
+var ErrDimensionMismatch = errors.New("vector dimension mismatch")
+
+// Item is an indexed vector with the text it embeds and optional metadata
+type Item struct {
+	ID     string            `json:"id"`
+	Vector []float32         `json:"vector"`
+	Text   string            `json:"text,omitempty"`
+	Meta   map[string]string `json:"meta,omitempty"`
+}
+
+// Result is a search hit; Score is the cosine similarity in [-1, 1]
+type Result struct {
+	Item
+	Score float32 `json:"score"`
+}
+
+// Filter restricts a search to matching items; nil matches everything
+type Filter func(item Item) bool
+
+// MetaFilter matches items whose metadata has every key/value pair of meta
+func MetaFilter(meta map[string]string) Filter {
+	return func(item Item) bool {
+		for key, value := range meta {
+			if item.Meta[key] != value {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Index stores vectors and finds the ones most similar to a query.
+// Implementations are safe for concurrent use.
+type Index interface {
+	// Add inserts or replaces items by ID
+	Add(items ...Item) error
+	// Remove deletes items by ID; unknown IDs are ignored
+	Remove(ids ...string)
+	// Search returns up to k items matching filter, most similar first
+	Search(query []float32, k int, filter Filter) ([]Result, error)
+	// Items returns every stored item
+	Items() []Item
+	Len() int
+}
+
+// Cosine returns the cosine similarity of a and b (0 if either is zero or the sizes differ)
+func Cosine(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return float32(dot / math.Sqrt(na*nb))
+}
+
+// normalize returns v scaled to unit length, so cosine similarity becomes a dot product
+func normalize(v []float32) []float32 {
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	out := make([]float32, len(v))
+	if norm == 0 {
+		return out
+	}
+	scale := 1 / math.Sqrt(norm)
+	for i, x := range v {
+		out[i] = float32(float64(x) * scale)
+	}
+	return out
+}
+
+func dot(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// entry is a stored item with its unit-length vector
+type entry struct {
+	item Item
+	unit []float32
+}
+
+// store holds the entries of an index; the zero value is empty
+type store struct {
+	dims    int
+	entries []entry
+	// positions maps IDs to indexes in entries
+	positions map[string]int
+}
+
+// check reports whether item can be stored
+func (s *store) check(item Item) error {
+	if len(item.Vector) == 0 {
+		return fmt.Errorf("item %q has no vector", item.ID)
+	}
+	if s.dims != 0 && len(item.Vector) != s.dims {
+		return fmt.Errorf("%w: item %q has %d dimensions, index has %d", ErrDimensionMismatch, item.ID, len(item.Vector), s.dims)
+	}
+	return nil
+}
+
+// put inserts or replaces an item and returns its position
+func (s *store) put(item Item) (int, error) {
+	if err := s.check(item); err != nil {
+		return 0, err
+	}
+	if s.dims == 0 {
+		s.dims = len(item.Vector)
+	}
+	if s.positions == nil {
+		s.positions = make(map[string]int)
+	}
+
+	e := entry{item: item, unit: normalize(item.Vector)}
+	if pos, ok := s.positions[item.ID]; ok {
+		s.entries[pos] = e
+		return pos, nil
+	}
+	s.positions[item.ID] = len(s.entries)
+	s.entries = append(s.entries, e)
+	return len(s.entries) - 1, nil
+}
+
+// delete removes an item by moving the last entry into its place
+func (s *store) delete(id string) {
+	pos, ok := s.positions[id]
+	if !ok {
+		return
+	}
+	last := len(s.entries) - 1
+	if pos != last {
+		s.entries[pos] = s.entries[last]
+		s.positions[s.entries[pos].item.ID] = pos
+	}
+	s.entries = s.entries[:last]
+	delete(s.positions, id)
+	if len(s.entries) == 0 {
+		s.dims = 0
+	}
+}
+
+func (s *store) checkQuery(query []float32) error {
+	if s.dims != 0 && len(query) != s.dims {
+		return fmt.Errorf("%w: query has %d dimensions, index has %d", ErrDimensionMismatch, len(query), s.dims)
+	}
+	return nil
+}
+
+// rank scores the entries at positions (all entries if positions is nil) and returns the top k
+func (s *store) rank(query []float32, k int, filter Filter, positions []int) []Result {
+	if k <= 0 || len(s.entries) == 0 {
+		return nil
+	}
+	unit := normalize(query)
+
+	var results []Result
+	score := func(e entry) {
+		if filter != nil && !filter(e.item) {
+			return
+		}
+		results = append(results, Result{Item: e.item, Score: dot(unit, e.unit)})
+	}
+	if positions == nil {
+		for _, e := range s.entries {
+			score(e)
+		}
+	} else {
+		for _, pos := range positions {
+			score(s.entries[pos])
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+func (s *store) items() []Item {
+	items := make([]Item, len(s.entries))
+	for i, e := range s.entries {
+		items[i] = e.item
+	}
+	return items
+}
+
+// Flat is an exact index that compares the query with every vector.
+// It is fast enough for tens of thousands of vectors.
+type Flat struct {
+	mu sync.RWMutex
+	s  store
+}
+
+// NewFlat creates an empty exact index
+func NewFlat() *Flat {
+	return &Flat{}
+}
+
+// Add inserts or replaces items. All vectors must have the size of the first one.
+func (f *Flat) Add(items ...Item) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, item := range items {
+		if _, err := f.s.put(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove deletes items by ID
+func (f *Flat) Remove(ids ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, id := range ids {
+		f.s.delete(id)
+	}
+}
+
+// Search returns the k items most similar to query
+func (f *Flat) Search(query []float32, k int, filter Filter) ([]Result, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if err := f.s.checkQuery(query); err != nil {
+		return nil, err
+	}
+	return f.s.rank(query, k, filter, nil), nil
+}
+
+// Items returns every stored item
+func (f *Flat) Items() []Item {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.s.items()
+}
+
+// Len returns the number of stored items
+func (f *Flat) Len() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.s.entries)
+}
@@ -0,0 +1,175 @@
+package vector
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestCosine(t *testing.T) {
+	tests := []struct {
+		a, b []float32
+		want float32
+	}{
+		{[]float32{1, 0}, []float32{2, 0}, 1},
+		{[]float32{1, 0}, []float32{0, 3}, 0},
+		{[]float32{1, 1}, []float32{-1, -1}, -1},
+		{[]float32{0, 0}, []float32{1, 0}, 0},
+		{[]float32{1}, []float32{1, 0}, 0},
+	}
+	for _, tt := range tests {
+		if got := Cosine(tt.a, tt.b); got < tt.want-1e-6 || got > tt.want+1e-6 {
+			t.Errorf("Cosine(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func testIndex(t *testing.T, index Index) {
+	t.Helper()
+
+	err := index.Add(
+		Item{ID: "coffee", Vector: []float32{1, 0.1, 0}, Meta: map[string]string{"user": "42"}},
+		Item{ID: "tea", Vector: []float32{0.8, 0.6, 0}, Meta: map[string]string{"user": "7"}},
+		Item{ID: "train", Vector: []float32{0, 0, 1}, Meta: map[string]string{"user": "42"}},
+	)
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	results, err := index.Search([]float32{1, 0, 0}, 2, nil)
+	if err != nil || len(results) != 2 || results[0].ID != "coffee" || results[1].ID != "tea" {
+		t.Fatalf("Search() = %+v, %v", results, err)
+	}
+	if results[0].Score <= results[1].Score {
+		t.Error("Results should be ordered by score")
+	}
+
+	results, _ = index.Search([]float32{1, 0, 0}, 5, MetaFilter(map[string]string{"user": "42"}))
+	if len(results) != 2 || results[0].ID != "coffee" || results[1].ID != "train" {
+		t.Errorf("Filtered Search() = %+v", results)
+	}
+
+	// Replacing keeps one item per ID
+	index.Add(Item{ID: "train", Vector: []float32{1, 0, 0}})
+	if results, _ := index.Search([]float32{1, 0, 0}, 1, nil); index.Len() != 3 || results[0].ID != "train" {
+		t.Errorf("Expected the replaced vector to win, got %+v (%d items)", results, index.Len())
+	}
+
+	index.Remove("train", "unknown")
+	if results, _ := index.Search([]float32{1, 0, 0}, 3, nil); index.Len() != 2 || len(results) != 2 || results[0].ID != "coffee" {
+		t.Errorf("Expected removal, got %+v", results)
+	}
+
+	if err := index.Add(Item{ID: "bad", Vector: []float32{1, 2}}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch, got %v", err)
+	}
+	if _, err := index.Search([]float32{1}, 1, nil); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch for the query, got %v", err)
+	}
+}
+
+func TestFlat(t *testing.T) {
+	testIndex(t, NewFlat())
+}
+
+func TestLSH(t *testing.T) {
+	testIndex(t, NewLSH(LSHOptions{}))
+}
+
+func TestLSHRecall(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	randomVector := func() []float32 {
+		v := make([]float32, 64)
+		for i := range v {
+			v[i] = float32(rng.NormFloat64())
+		}
+		return v
+	}
+
+	flat, lsh := NewFlat(), NewLSH(DefaultLSHOptions)
+	for i := 0; i < 2000; i++ {
+		item := Item{ID: fmt.Sprint(i), Vector: randomVector()}
+		flat.Add(item)
+		lsh.Add(item)
+	}
+
+	found := 0
+	for q := 0; q < 50; q++ {
+		// Queries near a stored vector, as for a repeated question
+		query := flat.Items()[rng.Intn(flat.Len())].Vector
+		noisy := make([]float32, len(query))
+		for i := range query {
+			noisy[i] = query[i] + float32(rng.NormFloat64())*0.3
+		}
+
+		want, _ := flat.Search(noisy, 1, nil)
+		got, _ := lsh.Search(noisy, 1, nil)
+		if len(got) == 1 && got[0].ID == want[0].ID {
+			found++
+		}
+	}
+	if found < 45 {
+		t.Errorf("LSH found the nearest neighbour %d/50 times, want at least 45", found)
+	}
+}
+
+type memoryStore map[string]map[string]Item
+
+func (m memoryStore) LoadVectors(collection string) ([]Item, error) {
+	var items []Item
+	for _, item := range m[collection] {
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (m memoryStore) SaveVectors(collection string, items []Item) error {
+	if m[collection] == nil {
+		m[collection] = make(map[string]Item)
+	}
+	for _, item := range items {
+		m[collection][item.ID] = item
+	}
+	return nil
+}
+
+func (m memoryStore) DeleteVectors(collection string, ids []string) error {
+	for _, id := range ids {
+		delete(m[collection], id)
+	}
+	return nil
+}
+
+func TestCollectionPersists(t *testing.T) {
+	store := memoryStore{}
+	c, err := Open(store, "memory", NewFlat())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	c.Add(Item{ID: "a", Vector: []float32{1, 0}, Text: "kopi"}, Item{ID: "b", Vector: []float32{0, 1}, Text: "teh"})
+	c.Remove("b")
+
+	reopened, err := Open(store, "memory", NewLSH(LSHOptions{}))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if !reflect.DeepEqual(reopened.Items(), []Item{{ID: "a", Vector: []float32{1, 0}, Text: "kopi"}}) {
+		t.Errorf("Reopened collection has %+v", reopened.Items())
+	}
+	if other, _ := Open(store, "conversations", NewFlat()); other.Len() != 0 {
+		t.Error("Collections must be separate")
+	}
+}
+
+func TestEncodeVector(t *testing.T) {
+	v := []float32{0, -1.5, 3.25, 1e-7}
+	got, err := DecodeVector(EncodeVector(v))
+	if err != nil || !reflect.DeepEqual(got, v) {
+		t.Errorf("DecodeVector(EncodeVector(%v)) = %v, %v", v, got, err)
+	}
+	if _, err := DecodeVector([]byte{1, 2, 3}); err == nil {
+		t.Error("Expected an error for a truncated vector")
+	}
+}
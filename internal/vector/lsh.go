@@ -0,0 +1,199 @@
+package vector
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// LSHOptions tunes an LSH index. More tables raise recall, more bits make buckets
+// smaller (faster searches, lower recall).
+type LSHOptions struct {
+	Tables int
+	Bits   int
+	// Seed makes the random hyperplanes reproducible
+	Seed int64
+}
+
+// DefaultLSHOptions suits indexes of up to a few hundred thousand vectors
+var DefaultLSHOptions = LSHOptions{
+	Tables: 8,
+	Bits:   12,
+	Seed:   1,
+}
+
+// LSH is an approximate index using random-hyperplane locality-sensitive hashing.
+// Vectors pointing in similar directions share buckets; a search ranks only the items
+// in the query's buckets and their one-bit neighbours. When those hold fewer than k
+// matching items the whole index is scanned, so small or heavily filtered searches
+// are exact.
+type LSH struct {
+	mu   sync.RWMutex
+	opts LSHOptions
+	s    store
+	// planes[t][b] is the normal of hyperplane b of table t
+	planes [][][]float32
+	// buckets[t] maps a signature to the IDs hashed there
+	buckets []map[uint64]map[string]struct{}
+	// signatures keeps each item's signature per table, for removal
+	signatures map[string][]uint64
+}
+
+// NewLSH creates an empty approximate index; zero options take DefaultLSHOptions
+func NewLSH(opts LSHOptions) *LSH {
+	if opts.Tables <= 0 {
+		opts.Tables = DefaultLSHOptions.Tables
+	}
+	if opts.Bits <= 0 || opts.Bits > 64 {
+		opts.Bits = DefaultLSHOptions.Bits
+	}
+	return &LSH{opts: opts, signatures: make(map[string][]uint64)}
+}
+
+// init draws the hyperplanes once the dimension is known
+func (l *LSH) init(dims int) {
+	rng := rand.New(rand.NewSource(l.opts.Seed))
+	l.planes = make([][][]float32, l.opts.Tables)
+	l.buckets = make([]map[uint64]map[string]struct{}, l.opts.Tables)
+	for t := range l.planes {
+		l.planes[t] = make([][]float32, l.opts.Bits)
+		for b := range l.planes[t] {
+			plane := make([]float32, dims)
+			for i := range plane {
+				plane[i] = float32(rng.NormFloat64())
+			}
+			l.planes[t][b] = plane
+		}
+		l.buckets[t] = make(map[uint64]map[string]struct{})
+	}
+}
+
+func (l *LSH) signature(table int, v []float32) uint64 {
+	var sig uint64
+	for b, plane := range l.planes[table] {
+		if dot(plane, v) >= 0 {
+			sig |= 1 << b
+		}
+	}
+	return sig
+}
+
+// Add inserts or replaces items. All vectors must have the size of the first one.
+func (l *LSH) Add(items ...Item) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, item := range items {
+		if err := l.s.check(item); err != nil {
+			return err
+		}
+		l.unhash(item.ID)
+		pos, err := l.s.put(item)
+		if err != nil {
+			return err
+		}
+		if l.planes == nil {
+			l.init(l.s.dims)
+		}
+
+		unit := l.s.entries[pos].unit
+		sigs := make([]uint64, l.opts.Tables)
+		for t := range sigs {
+			sigs[t] = l.signature(t, unit)
+			bucket := l.buckets[t][sigs[t]]
+			if bucket == nil {
+				bucket = make(map[string]struct{})
+				l.buckets[t][sigs[t]] = bucket
+			}
+			bucket[item.ID] = struct{}{}
+		}
+		l.signatures[item.ID] = sigs
+	}
+	return nil
+}
+
+// unhash removes an ID from its buckets
+func (l *LSH) unhash(id string) {
+	sigs, ok := l.signatures[id]
+	if !ok {
+		return
+	}
+	for t, sig := range sigs {
+		delete(l.buckets[t][sig], id)
+		if len(l.buckets[t][sig]) == 0 {
+			delete(l.buckets[t], sig)
+		}
+	}
+	delete(l.signatures, id)
+}
+
+// Remove deletes items by ID
+func (l *LSH) Remove(ids ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, id := range ids {
+		l.unhash(id)
+		l.s.delete(id)
+	}
+	if len(l.s.entries) == 0 {
+		// The next item may have another dimension
+		l.planes, l.buckets = nil, nil
+	}
+}
+
+// Search returns about the k items most similar to query
+func (l *LSH) Search(query []float32, k int, filter Filter) ([]Result, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if err := l.s.checkQuery(query); err != nil {
+		return nil, err
+	}
+	if l.planes == nil || k <= 0 {
+		return nil, nil
+	}
+
+	unit := normalize(query)
+	seen := make(map[int]bool)
+	var candidates []int
+	matching := 0
+	collect := func(t int, sig uint64) {
+		for id := range l.buckets[t][sig] {
+			pos := l.s.positions[id]
+			if seen[pos] {
+				continue
+			}
+			seen[pos] = true
+			candidates = append(candidates, pos)
+			if filter == nil || filter(l.s.entries[pos].item) {
+				matching++
+			}
+		}
+	}
+	for t := range l.planes {
+		sig := l.signature(t, unit)
+		collect(t, sig)
+		for b := 0; b < l.opts.Bits; b++ {
+			collect(t, sig^(1<<b))
+		}
+	}
+
+	if matching < k {
+		return l.s.rank(query, k, filter, nil), nil
+	}
+	return l.s.rank(query, k, filter, candidates), nil
+}
+
+// Items returns every stored item
+func (l *LSH) Items() []Item {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.s.items()
+}
+
+// Len returns the number of stored items
+func (l *LSH) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.s.entries)
+}
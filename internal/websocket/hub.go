@@ -2,10 +2,23 @@ package websocket
 
 import (
 	"Qwen/internal/ai"
+	"Qwen/internal/database"
+	"Qwen/internal/knowledge"
+	"Qwen/internal/prompt"
+	"Qwen/internal/quota"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -16,21 +29,96 @@ type Hub struct {
 	unregister chan *Client
 	broadcast  chan []byte
 	mutex      sync.RWMutex
-	aiClient   *ai.Client
+	aiClient   ai.LLM
+	// convService is optional; when set, transcripts are loaded from and saved to the database
+	convService *database.ConversationService
+	// glossary is optional; when set, the user's terms are applied to translations
+	glossary *database.GlossaryService
+	// limiter is optional; when set, messages over quota are rejected before calling the AI
+	limiter *quota.Limiter
+	// contextBuilder is optional; when set, the transcript is trimmed to the model's token budget
+	contextBuilder *ai.ContextBuilder
+	// retriever is optional; when set, relevant earlier turns of the user are added to the prompt
+	retriever *ai.Retriever
+	// knowledge is optional; when set, users can upload documents and questions are answered from them
+	knowledge *knowledge.Base
+	// prompts renders the system prompt of each user's persona; nil uses the built-in templates
+	prompts *prompt.Library
+	// authSecret signs user IDs (see Token); without it no connection is authenticated
+	authSecret []byte
 }
 
+// maxHistoryTurns bounds the per-connection transcript; the context builder trims it further
+const maxHistoryTurns = 20
+
+// maxUploadBytes is the largest audio, video or document a client may send, as on Telegram
+const maxUploadBytes = 20 << 20
+
+// maxMessageBytes bounds one incoming WebSocket message: a base64 upload plus the JSON around it
+const maxMessageBytes = maxUploadBytes/3*4 + 64<<10
+
 type Client struct {
 	hub    *Hub
 	conn   *websocket.Conn
 	send   chan []byte
 	userID string
+	// authenticated is set when the client proved its user ID with a token. Unauthenticated
+	// connections chose their own ID, so they never read or write stored per-user data.
+	authenticated bool
+
+	// ctx is cancelled when the connection closes, aborting all in-flight generations
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu          sync.Mutex
+	closed      bool
+	generations map[string]context.CancelFunc
+	nextID      int
+	// history holds the completed turns of this connection, oldest first
+	history []ai.Turn
+	// persona is the persona picked by the user, empty for the default one
+	persona string
 }
 
 type Message struct {
 	Type    string `json:"type"`
 	Content string `json:"content"`
 	UserID  string `json:"user_id,omitempty"`
-	Stage   string `json:"stage,omitempty"` // thinking, reasoning, responding
+	Stage   string `json:"stage,omitempty"` // thinking, thinking_complete, streaming, tool_call, usage, complete, cancelled, error
+	// ID identifies a generation; user_message may set it, cancel uses it to pick the generation to abort
+	ID string `json:"id,omitempty"`
+
+	// Audio is a base64 recording sent with user_message instead of Content; it is transcribed
+	// (reported back as a "transcript" message) and answered like text
+	Audio string `json:"audio,omitempty"`
+	// Video is a base64 upload analyzed together with Content
+	Video string `json:"video,omitempty"`
+	// Mime is the type of Audio or Video
+	Mime     string `json:"mime,omitempty"`
+	Language string `json:"language,omitempty"`
+
+	// SourceLang (optional, detected if empty) and TargetLang select the languages of a translate message
+	SourceLang string `json:"source_lang,omitempty"`
+	TargetLang string `json:"target_lang,omitempty"`
+
+	ToolCall     *ai.ToolCallDelta `json:"tool_call,omitempty"`
+	Usage        *ai.Usage         `json:"usage,omitempty"`
+	FinishReason string            `json:"finish_reason,omitempty"`
+	// Cached marks a "complete" answer replayed from the response cache
+	Cached bool `json:"cached,omitempty"`
+
+	// Document is a base64 file (with Name and Mime) added to the knowledge base by a document message;
+	// DocumentID picks the document removed by delete_document
+	Document   string `json:"document,omitempty"`
+	Name       string `json:"name,omitempty"`
+	DocumentID int64  `json:"document_id,omitempty"`
+	// Documents answers document (the added document) and documents (all of the user's documents)
+	Documents []knowledge.Document `json:"documents,omitempty"`
+
+	// Persona picks the user's persona in a persona message; without it the reply lists Personas
+	// and names the current one
+	Persona  string           `json:"persona,omitempty"`
+	Personas []prompt.Persona `json:"personas,omitempty"`
 }
 
 var upgrader = websocket.Upgrader{
@@ -40,14 +128,65 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-func NewHub(aiClient *ai.Client) *Hub {
+// NewHub creates a hub; convService may be nil to keep transcripts in memory only,
+// glossary may be nil to translate without glossaries, limiter may be nil to disable quotas
+// contextBuilder may be nil to send the whole transcript, retriever may be nil to recall nothing,
+// knowledgeBase may be nil to disable documents and prompts may be nil to use the built-in templates.
+// authSecret signs the tokens of authenticated connections; empty leaves every connection unauthenticated.
+func NewHub(aiClient ai.LLM, convService *database.ConversationService, glossary *database.GlossaryService, limiter *quota.Limiter, contextBuilder *ai.ContextBuilder, retriever *ai.Retriever, knowledgeBase *knowledge.Base, prompts *prompt.Library, authSecret string) *Hub {
 	return &Hub{
-		clients:    make(map[*Client]bool),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
-		broadcast:  make(chan []byte),
-		aiClient:   aiClient,
+		clients:        make(map[*Client]bool),
+		register:       make(chan *Client),
+		unregister:     make(chan *Client),
+		broadcast:      make(chan []byte),
+		aiClient:       aiClient,
+		glossary:       glossary,
+		convService:    convService,
+		limiter:        limiter,
+		contextBuilder: contextBuilder,
+		retriever:      retriever,
+		knowledge:      knowledgeBase,
+		prompts:        prompts,
+		authSecret:     []byte(authSecret),
+	}
+}
+
+// webUserPrefix namespaces web user IDs so they never collide with Telegram user IDs
+const webUserPrefix = "web:"
+
+// anonymousUser is the quota and usage key shared by all unauthenticated connections
+const anonymousUser = webUserPrefix + "anonymous"
+
+// Token returns the token that authenticates userID on /ws?user_id=<userID>&token=<token>:
+// the hex HMAC-SHA256 of userID keyed with secret
+func Token(secret, userID string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(userID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// authenticate returns the namespaced user ID of a connection request and whether its token proves it
+func (h *Hub) authenticate(r *http.Request) (string, bool) {
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		userID = "anonymous"
+	}
+
+	authenticated := false
+	if len(h.authSecret) > 0 {
+		token := r.URL.Query().Get("token")
+		authenticated = hmac.Equal([]byte(token), []byte(Token(string(h.authSecret), userID)))
 	}
+	return webUserPrefix + userID, authenticated
+}
+
+// quotaID returns the key of the client's quota and token usage. Unauthenticated connections
+// choose their own user ID, so they share one key instead of getting a fresh quota per ID.
+func (c *Client) quotaID() string {
+	if c.authenticated {
+		return c.userID
+	}
+	return anonymousUser
 }
 
 func (h *Hub) Run() {
@@ -63,22 +202,20 @@ func (h *Hub) Run() {
 			h.mutex.Lock()
 			if _, ok := h.clients[client]; ok {
 				delete(h.clients, client)
-				close(client.send)
+				client.close()
 				log.Printf("Client unregistered: %s", client.userID)
 			}
 			h.mutex.Unlock()
 
 		case message := <-h.broadcast:
-			h.mutex.RLock()
+			h.mutex.Lock()
 			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
+				if !client.trySend(message) {
 					delete(h.clients, client)
-					close(client.send)
+					client.close()
 				}
 			}
-			h.mutex.RUnlock()
+			h.mutex.Unlock()
 		}
 	}
 }
@@ -90,16 +227,22 @@ func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID := r.URL.Query().Get("user_id")
-	if userID == "" {
-		userID = "anonymous"
-	}
+	userID, authenticated := h.authenticate(r)
 
+	ctx, cancel := context.WithCancel(context.Background())
 	client := &Client{
-		hub:    h,
-		conn:   conn,
-		send:   make(chan []byte, 256),
-		userID: userID,
+		hub:           h,
+		conn:          conn,
+		send:          make(chan []byte, 256),
+		userID:        userID,
+		authenticated: authenticated,
+		ctx:           ctx,
+		cancel:        cancel,
+		generations:   make(map[string]context.CancelFunc),
+	}
+	if authenticated {
+		client.history = h.loadHistory(userID)
+		client.persona = h.loadPersona(userID)
 	}
 
 	client.hub.register <- client
@@ -110,20 +253,30 @@ func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request) {
 
 func (c *Client) readPump() {
 	defer func() {
+		// Stop all upstream streams as soon as the peer is gone
+		c.cancel()
 		c.hub.unregister <- c
 		c.conn.Close()
 	}()
 
+	c.conn.SetReadLimit(maxMessageBytes)
 	for {
 		var msg Message
 		err := c.conn.ReadJSON(&msg)
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+			if errors.Is(err, websocket.ErrReadLimit) {
+				log.Printf("Closing connection of %s: message larger than %d bytes", c.userID, maxMessageBytes)
+			} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
 			}
 			break
 		}
 
+		if msg.Type == "cancel" {
+			c.cancelGeneration(msg.ID)
+			continue
+		}
+
 		// Handle incoming message and stream AI response
 		go c.handleMessage(msg)
 	}
@@ -148,20 +301,394 @@ func (c *Client) writePump() {
 }
 
 func (c *Client) handleMessage(msg Message) {
-	if msg.Type != "user_message" {
+	switch msg.Type {
+	case "user_message":
+	case "translate":
+		c.handleTranslate(msg)
+		return
+	case "document", "documents", "delete_document":
+		c.handleDocuments(msg)
+		return
+	case "persona":
+		c.handlePersona(msg)
+		return
+	default:
+		return
+	}
+
+	ctx, id := c.startGeneration(msg.ID)
+	defer c.finishGeneration(id)
+	ctx = ai.WithUsageTag(ctx, ai.UsageTag{UserID: c.quotaID(), Purpose: ai.PurposeChat})
+
+	for _, upload := range []struct{ name, data string }{{"audio", msg.Audio}, {"video", msg.Video}} {
+		if err := checkUpload(upload.name, upload.data); err != nil {
+			c.sendMessage(Message{Type: "ai_response", ID: id, Stage: "error", Content: fmt.Sprintf("Error: %v", err)})
+			return
+		}
+	}
+	if err := c.hub.limiter.Allow(c.quotaID()); err != nil {
+		log.Printf("Quota exceeded for %s: %v", c.userID, err)
+		c.sendMessage(Message{Type: "ai_response", ID: id, Stage: "error", Content: quota.Reply(err, "")})
 		return
 	}
 
-	// Use simple streaming
-	c.hub.aiClient.ChatStreamWithThinking(msg.Content, func(stage string, content string, isComplete bool) {
-		c.sendMessage(Message{
-			Type:    "ai_response",
-			Content: content,
-			Stage:   stage,
-		})
+	if msg.Audio != "" {
+		text, err := c.transcribe(ctx, msg)
+		if err != nil {
+			log.Printf("Transcription failed for %s: %v", c.userID, err)
+			c.sendMessage(Message{Type: "ai_response", ID: id, Stage: "error", Content: fmt.Sprintf("Error: %v", err)})
+			return
+		}
+		c.sendMessage(Message{Type: "transcript", ID: id, Content: text})
+		msg.Content = text
+	}
+
+	var events <-chan ai.StreamEvent
+	if msg.Video != "" {
+		mime := msg.Mime
+		if mime == "" {
+			mime = "video/mp4"
+		}
+		video := &ai.OmniVideo{Mime: mime, DataBase64: msg.Video}
+		events = c.hub.aiClient.ChatOmniStream(ctx, c.systemPrompt(msg), msg.Content, nil, nil, video, false)
+	} else {
+		history := c.transcript()
+		systemPrompt := c.systemPrompt(msg)
+		// Earlier turns, documents and rolling summaries are stored per user, so only authenticated connections get them
+		summaryID := ""
+		if c.authenticated {
+			summaryID = c.userID
+
+			recollections, err := c.hub.retriever.Recall(ctx, c.userID, msg.Content, history)
+			if err != nil {
+				log.Printf("Failed to recall earlier turns for %s: %v", c.userID, err)
+			}
+			systemPrompt = c.hub.retriever.WithRecollections(systemPrompt, recollections)
+
+			passages, err := c.hub.knowledge.Search(ctx, c.userID, msg.Content)
+			if err != nil {
+				log.Printf("Failed to search documents for %s: %v", c.userID, err)
+			}
+			systemPrompt = c.hub.knowledge.WithPassages(systemPrompt, passages)
+		}
+		messages := c.hub.contextBuilder.Build(ctx, summaryID, systemPrompt, history, msg.Content)
+		events = c.hub.aiClient.ChatStreamWithThinking(ctx, messages)
+	}
+
+	var answer strings.Builder
+	for event := range events {
+		reply := Message{Type: "ai_response", ID: id}
+
+		switch event.Type {
+		case ai.EventReasoning:
+			reply.Stage = "thinking"
+			reply.Content = event.Delta
+		case ai.EventAnswer:
+			if answer.Len() == 0 {
+				c.sendMessage(Message{Type: "ai_response", ID: id, Stage: "thinking_complete"})
+			}
+			answer.WriteString(event.Delta)
+			reply.Stage = "streaming"
+			reply.Content = event.Delta
+		case ai.EventToolCall:
+			reply.Stage = "tool_call"
+			reply.ToolCall = event.ToolCall
+		case ai.EventUsage:
+			reply.Stage = "usage"
+			reply.Usage = event.Usage
+		case ai.EventDone:
+			reply.Stage = "complete"
+			reply.Content = answer.String()
+			reply.FinishReason = event.FinishReason
+			reply.Cached = event.Cached
+			c.recordTurn(msg.Content, answer.String())
+		case ai.EventError:
+			if ctx.Err() != nil {
+				reply.Stage = "cancelled"
+				reply.Content = answer.String()
+				break
+			}
+			reply.Stage = "error"
+			reply.Content = fmt.Sprintf("Error: %v", event.Err)
+		}
+
+		c.sendMessage(reply)
+	}
+}
+
+// handleTranslate translates Content into TargetLang with the user's glossary and
+// answers with a single "translation" message
+func (c *Client) handleTranslate(msg Message) {
+	ctx, id := c.startGeneration(msg.ID)
+	defer c.finishGeneration(id)
+	ctx = ai.WithUsageTag(ctx, ai.UsageTag{UserID: c.quotaID(), Purpose: ai.PurposeTranslation})
+
+	if err := c.hub.limiter.Allow(c.quotaID()); err != nil {
+		log.Printf("Quota exceeded for %s: %v", c.userID, err)
+		c.sendMessage(Message{Type: "translation", ID: id, Stage: "error", Content: quota.Reply(err, "")})
+		return
+	}
+
+	req := ai.TranslateRequest{Text: msg.Content, SourceLang: msg.SourceLang, TargetLang: msg.TargetLang}
+	if c.hub.glossary != nil && c.authenticated {
+		terms, err := c.hub.glossary.GetTerms(c.userID)
+		if err != nil {
+			log.Printf("Failed to load glossary for %s: %v", c.userID, err)
+		}
+		req.Terms = terms
+	}
+
+	translation, err := c.hub.aiClient.Translate(ctx, req)
+	if err != nil {
+		c.sendMessage(Message{Type: "translation", ID: id, Stage: "error", Content: fmt.Sprintf("Error: %v", err)})
+		return
+	}
+	c.sendMessage(Message{
+		Type:       "translation",
+		ID:         id,
+		Stage:      "complete",
+		Content:    translation.Text,
+		SourceLang: translation.SourceLang,
+		TargetLang: translation.TargetLang,
 	})
 }
 
+// handleDocuments adds a document to the user's knowledge base, lists the documents or removes one
+func (c *Client) handleDocuments(msg Message) {
+	reply := Message{Type: msg.Type, ID: msg.ID, Stage: "complete"}
+	if c.hub.knowledge == nil {
+		reply.Stage = "error"
+		reply.Content = "Error: documents need a database"
+		c.sendMessage(reply)
+		return
+	}
+	if !c.authenticated {
+		reply.Stage = "error"
+		reply.Content = "Error: documents need an authenticated connection"
+		c.sendMessage(reply)
+		return
+	}
+
+	var err error
+	switch msg.Type {
+	case "document":
+		err = c.addDocument(msg, &reply)
+	case "documents":
+		reply.Documents, err = c.hub.knowledge.Documents(c.userID)
+	case "delete_document":
+		var removed bool
+		if removed, err = c.hub.knowledge.Delete(c.userID, msg.DocumentID); err == nil && !removed {
+			err = fmt.Errorf("document %d not found", msg.DocumentID)
+		}
+	}
+	if err != nil {
+		reply.Stage = "error"
+		reply.Content = fmt.Sprintf("Error: %v", err)
+	}
+	c.sendMessage(reply)
+}
+
+// addDocument ingests the file of a document message after checking the user's quota
+func (c *Client) addDocument(msg Message, reply *Message) error {
+	if err := checkUpload("document", msg.Document); err != nil {
+		return err
+	}
+	if err := c.hub.limiter.Allow(c.quotaID()); err != nil {
+		log.Printf("Quota exceeded for %s: %v", c.userID, err)
+		return errors.New(quota.Reply(err, ""))
+	}
+
+	data, err := base64.StdEncoding.DecodeString(msg.Document)
+	if err != nil {
+		return fmt.Errorf("invalid base64 document: %w", err)
+	}
+	doc, err := c.hub.knowledge.Ingest(c.ctx, c.userID, msg.Name, msg.Mime, data)
+	if err != nil {
+		return err
+	}
+	reply.Documents = []knowledge.Document{*doc}
+	return nil
+}
+
+// checkUpload rejects base64 data that decodes to more than maxUploadBytes, before decoding it
+func checkUpload(name, data string) error {
+	if base64.StdEncoding.DecodedLen(len(data)) > maxUploadBytes {
+		return fmt.Errorf("%s is larger than %d MB", name, maxUploadBytes>>20)
+	}
+	return nil
+}
+
+// handlePersona switches the user's persona, or lists the personas when msg.Persona is empty
+func (c *Client) handlePersona(msg Message) {
+	reply := Message{Type: "persona", ID: msg.ID, Stage: "complete"}
+
+	if msg.Persona != "" {
+		p, ok := c.hub.prompts.Persona(msg.Persona)
+		if !ok {
+			reply.Stage = "error"
+			reply.Content = fmt.Sprintf("Error: unknown persona %q", msg.Persona)
+			c.sendMessage(reply)
+			return
+		}
+		// Unauthenticated connections keep the persona for the connection only
+		if c.hub.convService != nil && c.authenticated {
+			if err := c.hub.convService.SetPersona(c.userID, p.Name); err != nil {
+				log.Printf("Failed to save persona for %s: %v", c.userID, err)
+			}
+		}
+		c.mu.Lock()
+		c.persona = p.Name
+		c.mu.Unlock()
+	}
+
+	c.mu.Lock()
+	reply.Persona = c.persona
+	c.mu.Unlock()
+	if _, ok := c.hub.prompts.Persona(reply.Persona); !ok {
+		reply.Persona = c.hub.prompts.DefaultPersona()
+	}
+	reply.Personas = c.hub.prompts.Personas()
+	c.sendMessage(reply)
+}
+
+// systemPrompt renders the user's persona for a message of this connection
+func (c *Client) systemPrompt(msg Message) string {
+	c.mu.Lock()
+	persona := c.persona
+	c.mu.Unlock()
+
+	return c.hub.prompts.System(persona, prompt.Data{
+		Language: ai.LanguageName(msg.Language),
+		ChatType: "web",
+		Now:      time.Now(),
+	})
+}
+
+// transcribe returns the text spoken in the audio of msg
+func (c *Client) transcribe(ctx context.Context, msg Message) (string, error) {
+	mime := msg.Mime
+	if mime == "" {
+		mime = "audio/wav"
+	}
+
+	ctx = ai.WithUsageTag(ctx, ai.UsageTag{UserID: c.quotaID(), Purpose: ai.PurposeTranscription})
+	transcript, err := c.hub.aiClient.Transcribe(ctx, ai.OmniMedia{Mime: mime, DataBase64: msg.Audio}, msg.Language)
+	if err != nil {
+		return "", err
+	}
+	if transcript.Text == "" {
+		return "", errors.New("no speech recognized")
+	}
+	return transcript.Text, nil
+}
+
+// loadHistory returns the user's recent turns from the database, if configured
+func (h *Hub) loadHistory(userID string) []ai.Turn {
+	if h.convService == nil {
+		return nil
+	}
+
+	turns, err := h.convService.GetConversationTurns(userID, maxHistoryTurns)
+	if err != nil {
+		log.Printf("Failed to load history for %s: %v", userID, err)
+		return nil
+	}
+	return turns
+}
+
+// loadPersona returns the persona the user picked, if a database is configured
+func (h *Hub) loadPersona(userID string) string {
+	if h.convService == nil {
+		return ""
+	}
+
+	persona, err := h.convService.GetPersona(userID)
+	if err != nil {
+		log.Printf("Failed to load persona for %s: %v", userID, err)
+	}
+	return persona
+}
+
+// transcript returns a copy of the connection's conversation so far
+func (c *Client) transcript() []ai.Turn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	history := make([]ai.Turn, len(c.history))
+	copy(history, c.history)
+	return history
+}
+
+// recordTurn persists a completed exchange and appends it to the transcript
+func (c *Client) recordTurn(userMessage, response string) {
+	if strings.TrimSpace(response) == "" {
+		return
+	}
+
+	turn := ai.Turn{User: userMessage, Assistant: response}
+	if c.hub.convService != nil && c.authenticated {
+		id, err := c.hub.convService.SaveConversation(c.userID, c.userID, userMessage, response)
+		if err != nil {
+			log.Printf("Failed to save conversation for %s: %v; not indexing it", c.userID, err)
+		} else {
+			turn.ID = id
+
+			// Indexing is not awaited, so the reply completes without waiting for the embedding
+			past := ai.PastTurn{Turn: turn, UserID: c.userID, At: time.Now()}
+			go func() {
+				if err := c.hub.retriever.Remember(context.Background(), past); err != nil {
+					log.Printf("Failed to index conversation for %s: %v", c.userID, err)
+				}
+			}()
+		}
+	}
+
+	c.mu.Lock()
+	c.history = append(c.history, turn)
+	if extra := len(c.history) - maxHistoryTurns; extra > 0 {
+		c.history = c.history[extra:]
+	}
+	c.mu.Unlock()
+}
+
+// startGeneration registers a cancellable generation and returns its context and ID
+func (c *Client) startGeneration(id string) (context.Context, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if id == "" || c.generations[id] != nil {
+		c.nextID++
+		id = fmt.Sprintf("gen-%d", c.nextID)
+	}
+
+	ctx, cancel := context.WithCancel(c.ctx)
+	c.generations[id] = cancel
+	return ctx, id
+}
+
+func (c *Client) finishGeneration(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cancel, ok := c.generations[id]; ok {
+		cancel()
+		delete(c.generations, id)
+	}
+}
+
+// cancelGeneration aborts the generation with the given ID, or all generations when id is empty
+func (c *Client) cancelGeneration(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for genID, cancel := range c.generations {
+		if id == "" || genID == id {
+			log.Printf("Cancelling generation %s for %s", genID, c.userID)
+			cancel()
+		}
+	}
+}
+
 func (c *Client) sendMessage(msg Message) {
 	data, err := json.Marshal(msg)
 	if err != nil {
@@ -169,10 +696,38 @@ func (c *Client) sendMessage(msg Message) {
 		return
 	}
 
+	if !c.trySend(data) {
+		// Slow consumer: drop the connection instead of blocking the stream
+		c.cancel()
+		go func() { c.hub.unregister <- c }()
+	}
+}
+
+// trySend queues data without blocking; it reports false when the buffer is full.
+// Messages for a closed client are silently dropped.
+func (c *Client) trySend(data []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return true
+	}
+
 	select {
 	case c.send <- data:
+		return true
 	default:
+		return false
+	}
+}
+
+// close closes the send channel exactly once
+func (c *Client) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.closed {
+		c.closed = true
 		close(c.send)
-		delete(c.hub.clients, c)
 	}
 }
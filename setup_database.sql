@@ -44,6 +44,68 @@ CREATE TABLE IF NOT EXISTS user_memories (
     INDEX idx_memory_key (memory_key)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
 
+-- Tabel untuk mencatat pemakaian token per user
+CREATE TABLE IF NOT EXISTS token_usage (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    purpose VARCHAR(50) NOT NULL DEFAULT '',
+    model VARCHAR(100) NOT NULL DEFAULT '',
+    prompt_tokens INT NOT NULL DEFAULT 0,
+    completion_tokens INT NOT NULL DEFAULT 0,
+    reasoning_tokens INT NOT NULL DEFAULT 0,
+    total_tokens INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_user_created (user_id, created_at),
+    INDEX idx_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Tabel glossary terjemahan per user (/glossary)
+CREATE TABLE IF NOT EXISTS translation_glossary (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    source_term VARCHAR(255) NOT NULL,
+    target_term VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY unique_user_term (user_id, source_term)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Tabel cache jawaban AI untuk pertanyaan yang berulang (AI_CACHE)
+CREATE TABLE IF NOT EXISTS response_cache (
+    cache_key CHAR(64) PRIMARY KEY,
+    model VARCHAR(100) NOT NULL DEFAULT '',
+    answer MEDIUMTEXT NOT NULL,
+    reasoning MEDIUMTEXT NOT NULL,
+    expires_at DATETIME(3) NOT NULL,
+    last_used_at DATETIME(3) NOT NULL,
+    INDEX idx_expires_at (expires_at),
+    INDEX idx_last_used_at (last_used_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Tabel vektor embedding untuk pencarian semantik (internal/vector)
+CREATE TABLE IF NOT EXISTS vector_items (
+    collection VARCHAR(100) NOT NULL,
+    item_id VARCHAR(255) NOT NULL,
+    dims INT NOT NULL,
+    embedding MEDIUMBLOB NOT NULL,
+    text TEXT NOT NULL,
+    meta JSON,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (collection, item_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Tabel dokumen knowledge base per user (potongan teksnya disimpan di vector_items, koleksi "documents")
+CREATE TABLE IF NOT EXISTS documents (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    format VARCHAR(20) NOT NULL,
+    chars INT NOT NULL,
+    chunks INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
 -- Contoh data untuk testing (opsional)
 -- INSERT INTO conversations (user_id, user_name, message, response) VALUES
 -- ('12345', 'TestUser', 'Halo', 'Halo juga! Ada yang bisa saya bantu?'),
@@ -53,3 +115,5 @@ CREATE TABLE IF NOT EXISTS user_memories (
 SHOW TABLES;
 DESCRIBE conversations;
 DESCRIBE chat_sessions;
+DESCRIBE token_usage;
+DESCRIBE translation_glossary;